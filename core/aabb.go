@@ -0,0 +1,82 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// AABB axis aligned bounding box, it is empty if Min is greater than Max
+type AABB struct {
+	Min Vector3
+	Max Vector3
+}
+
+func NewEmptyAABB() AABB {
+	return AABB{Vector3{Infinity32, Infinity32, Infinity32}, Vector3{-Infinity32, -Infinity32, -Infinity32}}
+}
+
+func NewInfiniteAABB() AABB {
+	return AABB{Vector3{-Infinity32, -Infinity32, -Infinity32}, Vector3{Infinity32, Infinity32, Infinity32}}
+}
+
+func minVector3(x0, x1 Vector3) Vector3 {
+	return Vector3{math32.Min(x0.X, x1.X), math32.Min(x0.Y, x1.Y), math32.Min(x0.Z, x1.Z)}
+}
+
+func maxVector3(x0, x1 Vector3) Vector3 {
+	return Vector3{math32.Max(x0.X, x1.X), math32.Max(x0.Y, x1.Y), math32.Max(x0.Z, x1.Z)}
+}
+
+func UnionAABB(box0, box1 AABB) AABB {
+	return AABB{minVector3(box0.Min, box1.Min), maxVector3(box0.Max, box1.Max)}
+}
+
+func ExpandAABB(box AABB, point Vector3) AABB {
+	return AABB{minVector3(box.Min, point), maxVector3(box.Max, point)}
+}
+
+func (box *AABB) IsEmpty() bool {
+	return box.Max.X < box.Min.X || box.Max.Y < box.Min.Y || box.Max.Z < box.Min.Z
+}
+
+func (box *AABB) Center() Vector3 {
+	return MulVector3(0.5, AddVector3(box.Min, box.Max))
+}
+
+func (box *AABB) Extent() Vector3 {
+	return SubVector3(box.Max, box.Min)
+}
+
+func (box *AABB) SurfaceArea() float32 {
+	if box.IsEmpty() {
+		return 0.0
+	}
+	extent := box.Extent()
+	return 2.0 * (extent.X*extent.Y + extent.Y*extent.Z + extent.Z*extent.X)
+}
+
+// Intersect returns the range of the ray in the box by the slab test
+func (box *AABB) Intersect(ray Ray, tmin, tmax float32) (float32, float32, bool) {
+	origin := [3]float32{ray.Origin.X, ray.Origin.Y, ray.Origin.Z}
+	direction := [3]float32{ray.Direction.X, ray.Direction.Y, ray.Direction.Z}
+	min := [3]float32{box.Min.X, box.Min.Y, box.Min.Z}
+	max := [3]float32{box.Max.X, box.Max.Y, box.Max.Z}
+	for i := 0; i < 3; i++ {
+		inv := 1.0 / direction[i]
+		t0 := (min[i] - origin[i]) * inv
+		t1 := (max[i] - origin[i]) * inv
+		if inv < 0.0 {
+			t0, t1 = t1, t0
+		}
+		// NaN from 0 times infinity does not narrow the range
+		if tmin < t0 {
+			tmin = t0
+		}
+		if t1 < tmax {
+			tmax = t1
+		}
+		if tmax < tmin {
+			return tmin, tmax, false
+		}
+	}
+	return tmin, tmax, true
+}
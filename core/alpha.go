@@ -0,0 +1,104 @@
+package core
+
+import (
+	"math/rand"
+)
+
+// AlphaTester is implemented by materials which can reject hits on their surfaces
+type AlphaTester interface {
+	AlphaTest(hitRecord *HitRecord) bool
+}
+
+// alphaTest returns false if the material of a hit rejects the hit
+func alphaTest(hitRecord *HitRecord) bool {
+	material := hitRecord.Material
+	if nil != hitRecord.alphaMaterial {
+		material = hitRecord.alphaMaterial
+	}
+	if tester, ok := material.(AlphaTester); ok {
+		return tester.AlphaTest(hitRecord)
+	}
+	return true
+}
+
+type AlphaMode int32
+
+const (
+	// AlphaThreshold accepts hits whose opacity is greater than or equal to the threshold
+	AlphaThreshold AlphaMode = iota
+	// AlphaStochastic accepts hits with the probability of the opacity
+	AlphaStochastic
+)
+
+// AlphaMasked cuts out the surface of a material by an opacity
+//
+// OpacityTexture multiplies Opacity by its first channel, use AlphaChannelTexture for the alpha channel of an image.
+// The test is evaluated without filtering, because it happens before the differentials are computed.
+type AlphaMasked struct {
+	Material       Material
+	Opacity        float32
+	OpacityTexture Texture
+	Mode           AlphaMode
+	Threshold      float32
+}
+
+func NewAlphaMasked(material Material, opacityTexture Texture) AlphaMasked {
+	return AlphaMasked{material, 1.0, opacityTexture, AlphaThreshold, 0.5}
+}
+
+func (masked *AlphaMasked) GetOpacity(hitRecord *HitRecord) float32 {
+	if nil == masked.OpacityTexture {
+		return masked.Opacity
+	}
+	return masked.Opacity * masked.OpacityTexture.Evaluate(hitRecord.UV, hitRecord.Position).X
+}
+
+func (masked *AlphaMasked) AlphaTest(hitRecord *HitRecord) bool {
+	opacity := masked.GetOpacity(hitRecord)
+	if AlphaStochastic == masked.Mode {
+		return 1.0 <= opacity || rand.Float32() < opacity
+	}
+	return masked.Threshold <= opacity
+}
+
+func (masked *AlphaMasked) PerturbNormal(hitRecord *HitRecord) {
+	if perturber, ok := masked.Material.(NormalPerturber); ok {
+		perturber.PerturbNormal(hitRecord)
+	}
+}
+
+func (masked *AlphaMasked) Unwrap() Material {
+	return masked.Material
+}
+
+func (masked *AlphaMasked) Resolve(hitRecord *HitRecord) Material {
+	return masked.Material.Resolve(hitRecord)
+}
+
+func (masked *AlphaMasked) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	return masked.Material.Sample(wi, eta0, eta1)
+}
+
+func (masked *AlphaMasked) Eval(wi, wo Vector3) Vector3 {
+	return masked.Material.Eval(wi, wo)
+}
+
+func (masked *AlphaMasked) Pdf(wi, wo Vector3) float32 {
+	return masked.Material.Pdf(wi, wo)
+}
+
+func (masked *AlphaMasked) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return masked.Material.Scatter(ray, hitRecord, attenuation, scattered)
+}
+
+func (masked *AlphaMasked) GetRoughness() float32 {
+	return masked.Material.GetRoughness()
+}
+
+func (masked *AlphaMasked) GetMetallic() float32 {
+	return masked.Material.GetMetallic()
+}
+
+func (masked *AlphaMasked) GetAlbedo() Vector3 {
+	return masked.Material.GetAlbedo()
+}
@@ -0,0 +1,62 @@
+package core
+import (
+	"testing"
+	"image"
+	"image/color"
+	"math/rand"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlphaMaskedHit(t *testing.T) {
+	assert := assert.New(t)
+	lambertian := NewLambertian(Vector3{0.5, 0.5, 0.5})
+	// Transparent on the odd cells of the checker
+	halfTexture := &CheckerTexture{NewConstantTexture(Vector3{1.0, 1.0, 1.0}), NewConstantTexture(Vector3{}), 2.0}
+	masked := NewAlphaMasked(&lambertian, halfTexture)
+	sphere := Sphere{Vector3{0.0, 0.0, 0.0}, 1.0, &masked}
+
+	var hitRecord HitRecord
+	ray := Ray{Vector3{0.0, 0.0, 3.0}, Vector3{0.0, 0.0, -1.0}, 0.0}
+	// The front at u=0.25 is odd and the back at u=0.75 is even with v=0.5
+	assert.True(sphere.Hit(ray, 0.0, 10.0, &hitRecord))
+	assert.InDeltaf(4.0, hitRecord.T, 1.0e-5, "Front face should be cut out")
+
+	world := NewHittableList()
+	world.AddHittable(&sphere)
+	assert.True(world.Occluded(ray, 0.0, 10.0))
+	assert.False(world.Occluded(ray, 0.0, 3.5))
+
+	masked.Opacity = 0.0
+	assert.False(sphere.Hit(ray, 0.0, 10.0, &hitRecord))
+	assert.False(world.Occluded(ray, 0.0, 10.0))
+}
+
+func TestAlphaMaskedStochastic(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(5)
+	lambertian := NewLambertian(Vector3{0.5, 0.5, 0.5})
+	masked := AlphaMasked{&lambertian, 0.25, nil, AlphaStochastic, 0.5}
+	hitRecord := HitRecord{Material: &masked}
+	count := 0
+	for i := 0; i < 10000; i++ {
+		if alphaTest(&hitRecord) {
+			count++
+		}
+	}
+	assert.InDelta(0.25, float32(count)/10000.0, 0.02)
+}
+
+func TestImageTextureAlpha(t *testing.T) {
+	assert := assert.New(t)
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 0})
+	img.SetNRGBA(1, 0, color.NRGBA{255, 0, 0, 128})
+	texture := NewImageTextureFromImage(img, false)
+	texture.WrapU = WrapClamp
+	assert.NotNil(texture.Alpha)
+	assert.InDelta(0.0, texture.EvaluateAlpha(Vector2{0.25, 0.5}), 1.0e-5)
+	assert.InDelta(128.0/255.0, texture.EvaluateAlpha(Vector2{0.75, 0.5}), 1.0e-5)
+	assert.InDeltaf(1.0, texture.Evaluate(Vector2{0.75, 0.5}, Vector3{}).X, 1.0e-2, "Color should not be premultiplied")
+	alpha := AlphaChannelTexture{texture}
+	assert.InDelta(0.0, alpha.Evaluate(Vector2{0.25, 0.5}, Vector3{}).Y, 1.0e-5)
+}
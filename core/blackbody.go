@@ -0,0 +1,99 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"math"
+)
+
+const (
+	planckConstant    float64 = 6.62607015e-34
+	speedOfLight      float64 = 2.99792458e8
+	boltzmannConstant float64 = 1.380649e-23
+	blackbodySteps    int32   = 80
+)
+
+// Planck spectral radiance of a blackbody at a wavelength in nanometers and a temperature in Kelvin
+func Planck(lambda, kelvin float32) float32 {
+	if kelvin <= 0.0 {
+		return 0.0
+	}
+	l := float64(lambda) * 1.0e-9
+	c1 := 2.0 * planckConstant * speedOfLight * speedOfLight
+	c2 := planckConstant * speedOfLight / boltzmannConstant
+	return float32(c1 / (l * l * l * l * l * (math.Exp(c2/(l*float64(kelvin))) - 1.0)))
+}
+
+// BlackbodyXYZ CIE XYZ of a blackbody normalized to the luminance Y = 1
+func BlackbodyXYZ(kelvin float32) Vector3 {
+	step := (CIELambdaMax - CIELambdaMin) / float32(blackbodySteps)
+	xyz := Vector3{}
+	for i := int32(0); i < blackbodySteps; i++ {
+		lambda := CIELambdaMin + (float32(i)+0.5)*step
+		xyz = AddVector3(xyz, MulVector3(Planck(lambda, kelvin), CIEColorMatching(lambda)))
+	}
+	if xyz.Y <= 0.0 {
+		return Vector3{}
+	}
+	return DivVector3(xyz, xyz.Y)
+}
+
+// Blackbody linear sRGB color of a blackbody with the luminance 1, which is an emission color for a temperature
+//
+// Colors out of the gamut are clipped to zero, then normalized again.
+func Blackbody(kelvin float32) Vector3 {
+	rgb := XYZToLinearSRGB(BlackbodyXYZ(kelvin))
+	rgb = Vector3{math32.Max(0.0, rgb.X), math32.Max(0.0, rgb.Y), math32.Max(0.0, rgb.Z)}
+	luminance := Luminance(rgb)
+	if luminance <= 0.0 {
+		return Vector3{}
+	}
+	return DivVector3(rgb, luminance)
+}
+
+// Bradford cone response matrix for chromatic adaptation
+var (
+	bradford = [3]Vector3{
+		{0.8951, 0.2664, -0.1614},
+		{-0.7502, 1.7135, 0.0367},
+		{0.0389, -0.0685, 1.0296}}
+	inverseBradford = [3]Vector3{
+		{0.9869929, -0.1470543, 0.1599627},
+		{0.4323053, 0.5183603, 0.0492912},
+		{-0.0085287, 0.0400428, 0.9684867}}
+	linearSRGBToXYZ = [3]Vector3{
+		{0.4124564, 0.3575761, 0.1804375},
+		{0.2126729, 0.7151522, 0.0721750},
+		{0.0193339, 0.1191920, 0.9503041}}
+)
+
+func mulMatrix3Vector3(m *[3]Vector3, x Vector3) Vector3 {
+	return Vector3{DotVector3(m[0], x), DotVector3(m[1], x), DotVector3(m[2], x)}
+}
+
+// LinearSRGBToXYZ converts linear sRGB (Rec.709 primaries, D65 white) to CIE XYZ
+func LinearSRGBToXYZ(rgb Vector3) Vector3 {
+	return mulMatrix3Vector3(&linearSRGBToXYZ, rgb)
+}
+
+// WhiteBalance von Kries adaptation in the Bradford cone space, which maps the white of a source to D65
+type WhiteBalance struct {
+	Scale Vector3
+}
+
+// NewWhiteBalance returns the white balance which neutralizes the illuminant of a correlated color temperature in Kelvin
+//
+// The white point is on the Planckian locus, so 6504 Kelvin is nearly but not exactly identity. Zero or less is identity.
+func NewWhiteBalance(kelvin float32) WhiteBalance {
+	if kelvin <= 0.0 {
+		return WhiteBalance{Vector3{1.0, 1.0, 1.0}}
+	}
+	source := mulMatrix3Vector3(&bradford, BlackbodyXYZ(kelvin))
+	destination := mulMatrix3Vector3(&bradford, LinearSRGBToXYZ(Vector3{1.0, 1.0, 1.0}))
+	return WhiteBalance{Vector3{destination.X / source.X, destination.Y / source.Y, destination.Z / source.Z}}
+}
+
+// Apply adapts a linear sRGB color
+func (whiteBalance *WhiteBalance) Apply(rgb Vector3) Vector3 {
+	lms := HadamardDotVector3(whiteBalance.Scale, mulMatrix3Vector3(&bradford, LinearSRGBToXYZ(rgb)))
+	return XYZToLinearSRGB(mulMatrix3Vector3(&inverseBradford, lms))
+}
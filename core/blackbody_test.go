@@ -0,0 +1,55 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPlanck(t *testing.T) {
+	assert := assert.New(t)
+	// Wien's displacement law, the peak of 5000 Kelvin is at 580 nanometers
+	peak := Planck(580.0, 5000.0)
+	assert.Truef(Planck(500.0, 5000.0) < peak, "Shorter than the peak")
+	assert.Truef(Planck(660.0, 5000.0) < peak, "Longer than the peak")
+	assert.Equal(float32(0.0), Planck(580.0, 0.0))
+}
+
+func TestBlackbody(t *testing.T) {
+	assert := assert.New(t)
+	white := Blackbody(6504.0)
+	assert.InDelta(1.0, Luminance(white), 1.0e-4)
+	assert.InDeltaf(1.0, white.X, 0.1, "6504 Kelvin should be nearly white")
+	assert.InDeltaf(1.0, white.Z, 0.1, "6504 Kelvin should be nearly white")
+	warm := Blackbody(2700.0)
+	assert.InDelta(1.0, Luminance(warm), 1.0e-4)
+	assert.Truef(warm.Z < warm.Y && warm.Y < warm.X, "Low temperatures should be reddish")
+	cool := Blackbody(12000.0)
+	assert.Truef(cool.X < cool.Z, "High temperatures should be bluish")
+}
+
+func TestWhiteBalance(t *testing.T) {
+	assert := assert.New(t)
+	balance := NewWhiteBalance(3200.0)
+	balanced := balance.Apply(MulVector3(0.5, Blackbody(3200.0)))
+	assert.InDeltaf(balanced.X, balanced.Y, 0.01, "The illuminant should become neutral")
+	assert.InDeltaf(balanced.Z, balanced.Y, 0.01, "The illuminant should become neutral")
+
+	identity := NewWhiteBalance(0.0)
+	color := Vector3{0.2, 0.5, 0.8}
+	same := identity.Apply(color)
+	assert.InDelta(color.X, same.X, 1.0e-4)
+	assert.InDelta(color.Y, same.Y, 1.0e-4)
+	assert.InDelta(color.Z, same.Z, 1.0e-4)
+}
+
+func TestEmissive(t *testing.T) {
+	assert := assert.New(t)
+	emissive := NewBlackbodyEmissive(3000.0, 4.0)
+	front := emissive.Emitted(Vector3{0.0, 0.0, 1.0})
+	assert.InDelta(4.0, Luminance(front), 1.0e-3)
+	back := emissive.Emitted(Vector3{0.0, 0.0, -1.0})
+	assert.True(back.IsZero())
+	emissive.TwoSided = true
+	back = emissive.Emitted(Vector3{0.0, 0.0, -1.0})
+	assert.False(back.IsZero())
+	assert.False(emissive.Sample(Vector3{0.0, 0.0, 1.0}, 0.5, 0.5).Continue)
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// Box oriented box of HalfSize along the orthonormal Axes around Center
+//
+// UV spans each face in [0 1].
+type Box struct {
+	Center   Vector3
+	HalfSize Vector3
+	Axes     [3]Vector3
+	Material Material
+}
+
+// NewBox axis aligned box from min to max
+func NewBox(min, max Vector3, material Material) Box {
+	return Box{
+		MulVector3(0.5, AddVector3(min, max)),
+		MulVector3(0.5, SubVector3(max, min)),
+		[3]Vector3{{1.0, 0.0, 0.0}, {0.0, 1.0, 0.0}, {0.0, 0.0, 1.0}},
+		material}
+}
+
+// NewOrientedBox box whose local X and Y follow xAxis and the projection of yAxis
+func NewOrientedBox(center, halfSize, xAxis, yAxis Vector3, material Material) Box {
+	x := NormalizeVector3(xAxis)
+	z := NormalizeVector3(CrossVector3(x, yAxis))
+	y := CrossVector3(z, x)
+	return Box{center, halfSize, [3]Vector3{x, y, z}, material}
+}
+
+func (box *Box) toLocal(v Vector3) Vector3 {
+	return Vector3{DotVector3(v, box.Axes[0]), DotVector3(v, box.Axes[1]), DotVector3(v, box.Axes[2])}
+}
+
+func (box *Box) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	local := Ray{box.toLocal(SubVector3(ray.Origin, box.Center)), box.toLocal(ray.Direction), ray.Time}
+	bounds := AABB{box.HalfSize.Minus(), box.HalfSize}
+	t0, t1, valid := bounds.Intersect(local, -Infinity32, Infinity32)
+	if !valid {
+		return false
+	}
+	for _, t := range [2]float32{t0, t1} {
+		if t <= tmin || tmax <= t {
+			continue
+		}
+		box.setRecord(ray, local.PointAt(t), t, record)
+		if alphaTest(record) {
+			return true
+		}
+	}
+	return false
+}
+
+// Intervals returns the segment of the ray through the box
+func (box *Box) Intervals(ray Ray, intervals []Interval) []Interval {
+	local := Ray{box.toLocal(SubVector3(ray.Origin, box.Center)), box.toLocal(ray.Direction), ray.Time}
+	bounds := AABB{box.HalfSize.Minus(), box.HalfSize}
+	t0, t1, valid := bounds.Intersect(local, -Infinity32, Infinity32)
+	if !valid || t1 <= t0 {
+		return intervals
+	}
+	interval := Interval{}
+	box.setRecord(ray, local.PointAt(t0), t0, &interval.Entry)
+	box.setRecord(ray, local.PointAt(t1), t1, &interval.Exit)
+	return append(intervals, interval)
+}
+
+// face returns the axis and the sign of the face nearest to a local point
+func (box *Box) face(p Vector3) (int, float32) {
+	ratio := [3]float32{p.X / box.HalfSize.X, p.Y / box.HalfSize.Y, p.Z / box.HalfSize.Z}
+	axis := 0
+	for i := 1; i < 3; i++ {
+		if math32.Abs(ratio[axis]) < math32.Abs(ratio[i]) {
+			axis = i
+		}
+	}
+	if ratio[axis] < 0.0 {
+		return axis, -1.0
+	}
+	return axis, 1.0
+}
+
+func (box *Box) setRecord(ray Ray, local Vector3, t float32, record *HitRecord) {
+	axis, sign := box.face(local)
+	coordinates := [3]float32{local.X, local.Y, local.Z}
+	half := [3]float32{box.HalfSize.X, box.HalfSize.Y, box.HalfSize.Z}
+	// The tangents of a face are the next two axes cyclically, flipped on the negative face to keep it right handed
+	ua := (axis + 1) % 3
+	va := (axis + 2) % 3
+	u := 0.5 + 0.5*sign*coordinates[ua]/half[ua]
+	v := 0.5 + 0.5*coordinates[va]/half[va]
+	dpdu := MulVector3(2.0*sign*half[ua], box.Axes[ua])
+	dpdv := MulVector3(2.0*half[va], box.Axes[va])
+	normal := MulVector3(sign, box.Axes[axis])
+	setFlatRecord(record, ray, t, normal, Vector2{u, v}, dpdu, dpdv, box.Material)
+}
+
+func (box *Box) BoundingBox() AABB {
+	extent := Vector3{}
+	half := [3]float32{box.HalfSize.X, box.HalfSize.Y, box.HalfSize.Z}
+	for i := 0; i < 3; i++ {
+		axis := box.Axes[i]
+		extent = AddVector3(extent, MulVector3(half[i], Vector3{math32.Abs(axis.X), math32.Abs(axis.Y), math32.Abs(axis.Z)}))
+	}
+	return AABB{SubVector3(box.Center, extent), AddVector3(box.Center, extent)}
+}
+
+func (box *Box) faceAreas() [3]float32 {
+	return [3]float32{
+		4.0 * box.HalfSize.Y * box.HalfSize.Z,
+		4.0 * box.HalfSize.Z * box.HalfSize.X,
+		4.0 * box.HalfSize.X * box.HalfSize.Y}
+}
+
+func (box *Box) Area() float32 {
+	areas := box.faceAreas()
+	return 2.0 * (areas[0] + areas[1] + areas[2])
+}
+
+// SampleArea selects a face by its area with u0, then reuses u0 for the position on the face
+func (box *Box) SampleArea(u0, u1 float32) (Vector3, Vector3) {
+	areas := box.faceAreas()
+	total := 2.0 * (areas[0] + areas[1] + areas[2])
+	x := u0 * total
+	face := 0
+	for ; face < 5; face++ {
+		area := areas[face/2]
+		if x < area {
+			break
+		}
+		x -= area
+	}
+	axis := face / 2
+	sign := float32(1.0)
+	if 1 == face%2 {
+		sign = -1.0
+	}
+	s := Saturate32(x / areas[axis])
+	half := [3]float32{box.HalfSize.X, box.HalfSize.Y, box.HalfSize.Z}
+	ua := (axis + 1) % 3
+	va := (axis + 2) % 3
+	normal := MulVector3(sign, box.Axes[axis])
+	position := AddVector3(box.Center, MulVector3(half[axis], normal))
+	position = AddVector3(position, MulVector3((2.0*s-1.0)*half[ua], box.Axes[ua]))
+	position = AddVector3(position, MulVector3((2.0*u1-1.0)*half[va], box.Axes[va]))
+	return position, normal
+}
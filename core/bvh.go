@@ -0,0 +1,319 @@
+package core
+
+const (
+	bvhBins          int     = 12
+	bvhLeafSize      int32   = 4
+	bvhTraversalCost float32 = 1.0
+	bvhStackSize     int     = 64
+)
+
+// bvhPrimitive Bounds0 and Bounds1 are the bounds at the ends of the time interval, Bounds is their union
+type bvhPrimitive struct {
+	Hittable   Hittable
+	Visibility Visibility
+	Bounds     AABB
+	Bounds0    AABB
+	Bounds1    AABB
+}
+
+// bvhNode is a leaf if Count is positive, otherwise the children are at Index and Index+1
+type bvhNode struct {
+	Bounds0 AABB
+	Bounds1 AABB
+	Index   int32
+	Count   int32
+}
+
+// bvhFlatNode static node, a leaf if Count is positive, otherwise the children are at Index and Index+1
+type bvhFlatNode struct {
+	Bounds AABB
+	Index  int32
+	Count  int32
+}
+
+// bvhBuilder builds the nodes over the bounds of items, order is permuted so that the items of a leaf are contiguous
+//
+// The builder only sees bounds, so that large meshes keep their triangles as indices rather than one Hittable each.
+type bvhBuilder struct {
+	bounds    []AABB
+	centroids []Vector3
+	order     []int32
+	nodes     []bvhFlatNode
+}
+
+// buildBVH returns the nodes, children following their parent, and the order of the items in the leaves
+func buildBVH(bounds []AABB) ([]bvhFlatNode, []int32) {
+	builder := bvhBuilder{bounds: bounds, centroids: make([]Vector3, len(bounds)), order: make([]int32, len(bounds))}
+	for i := range bounds {
+		builder.centroids[i] = bounds[i].Center()
+		builder.order[i] = int32(i)
+	}
+	if 0 < len(bounds) {
+		builder.nodes = make([]bvhFlatNode, 1, len(bounds)/2+1)
+		builder.build(0, 0, int32(len(bounds)))
+	}
+	return builder.nodes, builder.order
+}
+
+// BVH bounding volume hierarchy built by the surface area heuristic over binned centroids
+//
+// Unbounded surfaces such as planes are kept out of the hierarchy and tested always.
+// A motion BVH bounds the nodes at both ends of the shutter interval and interpolates them at the time of a ray,
+// so that moving objects are tested only around where they are at that time.
+type BVH struct {
+	Time0      float32
+	Time1      float32
+	nodes      []bvhNode
+	primitives []bvhPrimitive
+	unbounded  []bvhPrimitive
+}
+
+// NewBVH builds the hierarchy, visibilities can be nil to make all visible, empty objects are dropped
+//
+// Moving objects are bounded over their whole motion.
+func NewBVH(hittables []Hittable, visibilities []Visibility) *BVH {
+	return newBVH(hittables, visibilities, 0.0, 0.0, false)
+}
+
+// NewMotionBVH builds the hierarchy for rays whose times are in [time0 time1], MotionBounded objects are bounded at both ends
+func NewMotionBVH(hittables []Hittable, visibilities []Visibility, time0, time1 float32) *BVH {
+	return newBVH(hittables, visibilities, time0, time1, time0 < time1)
+}
+
+func newBVH(hittables []Hittable, visibilities []Visibility, time0, time1 float32, motion bool) *BVH {
+	bvh := &BVH{Time0: time0, Time1: time1}
+	primitives := []bvhPrimitive{}
+	for i, hittable := range hittables {
+		visibility := VisibleAll
+		if nil != visibilities {
+			visibility = visibilities[i]
+		}
+		bounds := hittable.BoundingBox()
+		bounds0, bounds1 := bounds, bounds
+		if motion {
+			bounds0, bounds1 = motionBoundsOf(hittable, time0, time1)
+			bounds = UnionAABB(bounds0, bounds1)
+		}
+		if bounds.IsEmpty() {
+			continue
+		}
+		primitive := bvhPrimitive{hittable, visibility, bounds, bounds0, bounds1}
+		extent := bounds.Extent()
+		if Infinity32 <= MaxElementVector3(extent) {
+			bvh.unbounded = append(bvh.unbounded, primitive)
+			continue
+		}
+		primitives = append(primitives, primitive)
+	}
+
+	bounds := make([]AABB, len(primitives))
+	for i := range primitives {
+		bounds[i] = primitives[i].Bounds
+	}
+	nodes, order := buildBVH(bounds)
+	bvh.primitives = make([]bvhPrimitive, len(primitives))
+	for i, item := range order {
+		bvh.primitives[i] = primitives[item]
+	}
+
+	// Refit the bounds at both ends of the interval bottom up, children follow their parent
+	bvh.nodes = make([]bvhNode, len(nodes))
+	for i := len(nodes) - 1; 0 <= i; i-- {
+		node := &bvh.nodes[i]
+		node.Index = nodes[i].Index
+		node.Count = nodes[i].Count
+		node.Bounds0 = NewEmptyAABB()
+		node.Bounds1 = NewEmptyAABB()
+		if 0 < node.Count {
+			for j := node.Index; j < node.Index+node.Count; j++ {
+				node.Bounds0 = UnionAABB(node.Bounds0, bvh.primitives[j].Bounds0)
+				node.Bounds1 = UnionAABB(node.Bounds1, bvh.primitives[j].Bounds1)
+			}
+			continue
+		}
+		for j := node.Index; j < node.Index+2; j++ {
+			node.Bounds0 = UnionAABB(node.Bounds0, bvh.nodes[j].Bounds0)
+			node.Bounds1 = UnionAABB(node.Bounds1, bvh.nodes[j].Bounds1)
+		}
+	}
+	return bvh
+}
+
+func vectorElement(v Vector3, axis int) float32 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	default:
+		return v.Z
+	}
+}
+
+func (builder *bvhBuilder) build(node int32, start, count int32) {
+	bounds := NewEmptyAABB()
+	centroids := NewEmptyAABB()
+	for i := start; i < start+count; i++ {
+		item := builder.order[i]
+		bounds = UnionAABB(bounds, builder.bounds[item])
+		centroids = ExpandAABB(centroids, builder.centroids[item])
+	}
+	builder.nodes[node].Bounds = bounds
+	if count <= bvhLeafSize {
+		builder.makeLeaf(node, start, count)
+		return
+	}
+
+	// Find the best split among the bins of all axes
+	bestCost := bvhTraversalCost * float32(count) * bounds.SurfaceArea()
+	bestAxis := -1
+	bestSplit := 0
+	extent := centroids.Extent()
+	for axis := 0; axis < 3; axis++ {
+		size := vectorElement(extent, axis)
+		if size <= 0.0 {
+			continue
+		}
+		var binBounds [bvhBins]AABB
+		var binCounts [bvhBins]int32
+		for i := range binBounds {
+			binBounds[i] = NewEmptyAABB()
+		}
+		for i := start; i < start+count; i++ {
+			item := builder.order[i]
+			bin := bvhBin(builder.centroids[item], &centroids, axis)
+			binBounds[bin] = UnionAABB(binBounds[bin], builder.bounds[item])
+			binCounts[bin]++
+		}
+		for split := 1; split < bvhBins; split++ {
+			left := NewEmptyAABB()
+			right := NewEmptyAABB()
+			var leftCount, rightCount int32
+			for i := 0; i < split; i++ {
+				left = UnionAABB(left, binBounds[i])
+				leftCount += binCounts[i]
+			}
+			for i := split; i < bvhBins; i++ {
+				right = UnionAABB(right, binBounds[i])
+				rightCount += binCounts[i]
+			}
+			if 0 == leftCount || 0 == rightCount {
+				continue
+			}
+			cost := bvhTraversalCost*bounds.SurfaceArea() + float32(leftCount)*left.SurfaceArea() + float32(rightCount)*right.SurfaceArea()
+			if cost < bestCost {
+				bestCost = cost
+				bestAxis = axis
+				bestSplit = split
+			}
+		}
+	}
+	if bestAxis < 0 {
+		builder.makeLeaf(node, start, count)
+		return
+	}
+
+	// Partition the items by the bin
+	middle := start
+	for i := start; i < start+count; i++ {
+		if bvhBin(builder.centroids[builder.order[i]], &centroids, bestAxis) < bestSplit {
+			builder.order[i], builder.order[middle] = builder.order[middle], builder.order[i]
+			middle++
+		}
+	}
+	left := int32(len(builder.nodes))
+	builder.nodes = append(builder.nodes, bvhFlatNode{}, bvhFlatNode{})
+	builder.nodes[node].Index = left
+	builder.nodes[node].Count = 0
+	builder.build(left, start, middle-start)
+	builder.build(left+1, middle, start+count-middle)
+}
+
+func (builder *bvhBuilder) makeLeaf(node int32, start, count int32) {
+	builder.nodes[node].Index = start
+	builder.nodes[node].Count = count
+}
+
+func bvhBin(centroid Vector3, centroids *AABB, axis int) int {
+	size := vectorElement(centroids.Extent(), axis)
+	offset := vectorElement(centroid, axis) - vectorElement(centroids.Min, axis)
+	bin := int(float32(bvhBins) * offset / size)
+	if bvhBins <= bin {
+		return bvhBins - 1
+	}
+	if bin < 0 {
+		return 0
+	}
+	return bin
+}
+
+func (bvh *BVH) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	return bvh.HitVisible(ray, tmin, tmax, VisibleAll, record)
+}
+
+// interpolation returns the weight of Bounds1 at a time
+func (bvh *BVH) interpolation(time float32) float32 {
+	if bvh.Time1 <= bvh.Time0 {
+		return 0.0
+	}
+	return Clamp0132((time - bvh.Time0) / (bvh.Time1 - bvh.Time0))
+}
+
+// HitVisible finds the closest hit of the surfaces visible to any of the visibility flags
+func (bvh *BVH) HitVisible(ray Ray, tmin float32, tmax float32, visibility Visibility, record *HitRecord) bool {
+	tmp := HitRecord{alphaMaterial: record.alphaMaterial}
+	hitAnything := false
+	closestSoFar := tmax
+	test := func(primitive *bvhPrimitive) {
+		if 0 == (primitive.Visibility & visibility) {
+			return
+		}
+		if primitive.Hittable.Hit(ray, tmin, closestSoFar, &tmp) {
+			hitAnything = true
+			closestSoFar = tmp.T
+			*record = tmp
+		}
+	}
+	for i := range bvh.unbounded {
+		test(&bvh.unbounded[i])
+	}
+	if 0 == len(bvh.nodes) {
+		return hitAnything
+	}
+	u := bvh.interpolation(ray.Time)
+	var stack [bvhStackSize]int32
+	top := 1
+	stack[0] = 0
+	for 0 < top {
+		top--
+		node := &bvh.nodes[stack[top]]
+		bounds := node.Bounds0
+		if 0.0 < u {
+			bounds = lerpAABB(node.Bounds0, node.Bounds1, u)
+		}
+		if _, _, valid := bounds.Intersect(ray, tmin, closestSoFar); !valid {
+			continue
+		}
+		if 0 < node.Count {
+			for i := node.Index; i < node.Index+node.Count; i++ {
+				test(&bvh.primitives[i])
+			}
+			continue
+		}
+		stack[top] = node.Index
+		stack[top+1] = node.Index + 1
+		top += 2
+	}
+	return hitAnything
+}
+
+func (bvh *BVH) BoundingBox() AABB {
+	box := NewEmptyAABB()
+	if 0 < len(bvh.nodes) {
+		box = UnionAABB(bvh.nodes[0].Bounds0, bvh.nodes[0].Bounds1)
+	}
+	for i := range bvh.unbounded {
+		box = UnionAABB(box, bvh.unbounded[i].Bounds)
+	}
+	return box
+}
@@ -15,6 +15,9 @@ type Camera struct {
 	Right   Vector3
 	Up      Vector3
 	LensRadius float32
+	// The shutter interval, rays are distributed uniformly in time between the open and close
+	ShutterOpen  float32
+	ShutterClose float32
 }
 
 func (camera *Camera) LookAt(eye, at, up Vector3) {
@@ -37,20 +40,33 @@ func screenToNDC(x, resolution uint32, jitter float32) float32 {
 	return 2.0*((float32(x)+0.5+jitter)/float32(resolution)) - 1.0
 }
 
-func (camera *Camera) GenerateRay(x, y uint32, screenSample, lensSample Sample2) Ray {
+func (camera *Camera) direction(x, y uint32, screenSample Sample2) Vector3 {
+	dx := camera.DX * screenToNDC(x, camera.Width, screenSample.X-0.499)
+	dy := camera.DY * screenToNDC(y, camera.Height, screenSample.Y-0.499)
+	right := MulVector3(dx, camera.Right)
+	up := MulVector3(dy, camera.Up)
+	return NormalizeVector3(AddVector3(AddVector3(right, up), camera.Forward))
+}
+
+// SetShutter sets the interval while the shutter is open, equal times disable motion blur
+func (camera *Camera) SetShutter(open, close float32) {
+	camera.ShutterOpen = open
+	camera.ShutterClose = close
+}
+
+// GenerateRay generates a ray with the differentials towards the next pixels in x and y, at the time of timeSample in [0 1) within the shutter
+func (camera *Camera) GenerateRay(x, y uint32, screenSample, lensSample Sample2, timeSample float32) RayDifferential {
 	lensSample = RandomOnDisk(lensSample.X, lensSample.Y).Mul(camera.LensRadius)
 
 	originUp := MulVector3(lensSample.X, camera.Up)
 	originRight := MulVector3(lensSample.Y, camera.Right)
 	origin := AddVector3(camera.Origin, AddVector3(originUp, originRight));
 
-	dx := camera.DX * screenToNDC(x, camera.Width, screenSample.X-0.499)
-	dy := camera.DY * screenToNDC(y, camera.Height, screenSample.Y-0.499)
-	right := MulVector3(dx, camera.Right)
-	up := MulVector3(dy, camera.Up)
-	direction := NormalizeVector3(AddVector3(AddVector3(right, up), camera.Forward))
-
-	return Ray{origin, direction}
+	direction := camera.direction(x, y, screenSample)
+	rxDirection := camera.direction(x+1, y, screenSample)
+	ryDirection := camera.direction(x, y+1, screenSample)
+	time := camera.ShutterOpen + timeSample*(camera.ShutterClose-camera.ShutterOpen)
+	return RayDifferential{Ray{origin, direction, time}, true, origin, origin, rxDirection, ryDirection}
 }
 
 func NewCameraPerspectiveFov(width uint32, height uint32, fovy float32) Camera {
@@ -59,7 +75,7 @@ func NewCameraPerspectiveFov(width uint32, height uint32, fovy float32) Camera {
 	fovx := fovy * aspect
 	return Camera{width, height, aspect, fovx, fovy,
 		Vector3{}, Vector3{0.0, 0.0, -1.0}, Vector3{1.0, 0.0, 0.0}, Vector3{0.0, 1.0, 0.0},
-		0.0}
+		0.0, 0.0, 0.0}
 }
 
 func NewCameraPerspectiveLens(width, height uint32, fovy, aperture float32) Camera {
@@ -68,6 +84,6 @@ func NewCameraPerspectiveLens(width, height uint32, fovy, aperture float32) Came
 	fovx := fovy * aspect
 	return Camera{width, height, aspect, fovx, fovy,
 		Vector3{}, Vector3{0.0, 0.0, -1.0}, Vector3{1.0, 0.0, 0.0}, Vector3{0.0, 1.0, 0.0},
-		aperture*0.5}
+		aperture*0.5, 0.0, 0.0}
 }
 
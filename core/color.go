@@ -28,23 +28,18 @@ func DivColor32(c Color32, x float32) Color32 {
 }
 
 func sRGBToLinear(x float32) float32 {
-	if 0.0 <= x && x <= 0.04045 {
+	if x <= 0.04045 {
 		return x / 12.92
-	} else if 0.04045 < x && x <= 1.0 {
-		return math32.Pow((x+0.055)/1.055, 2.4)
-	} else {
-		return x
 	}
+	return math32.Pow((x+0.055)/1.055, 2.4)
 }
 
+// linearToSRGB sRGB transfer function, which is extended above 1 by the same curve
 func linearToSRGB(x float32) float32 {
-	if 0.0 <= x && x <= 0.0031308 {
+	if x <= 0.0031308 {
 		return x * 12.92
-	} else if 0.0031308 < x && x <= 1.0 {
-		return math32.Pow(1.055*x, 1.0/2.4) - 0.055
-	} else {
-		return x
 	}
+	return 1.055*math32.Pow(x, 1.0/2.4) - 0.055
 }
 
 func SRGBToLinear(c Color32) Color32 {
@@ -62,3 +57,8 @@ func LinearToSRGB(c Color32) Color32 {
 		linearToSRGB(c.B),
 		c.A}
 }
+
+// Luminance relative luminance of linear Rec.709 color
+func Luminance(c Vector3) float32 {
+	return 0.2126*c.X + 0.7152*c.Y + 0.0722*c.Z
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// ColorSpace RGB color space defined by the chromaticities of the primaries and the white
+//
+// The working space of the renderer is linear Rec.709, ConvertColor and the conversions of images map other spaces to it.
+type ColorSpace struct {
+	Name    string
+	White   Vector3
+	ToXYZ   [3]Vector3
+	FromXYZ [3]Vector3
+}
+
+func chromaticityToXYZ(x, y float32) Vector3 {
+	return Vector3{x / y, 1.0, (1.0 - x - y) / y}
+}
+
+func NewColorSpace(name string, rx, ry, gx, gy, bx, by, wx, wy float32) *ColorSpace {
+	r := chromaticityToXYZ(rx, ry)
+	g := chromaticityToXYZ(gx, gy)
+	b := chromaticityToXYZ(bx, by)
+	white := chromaticityToXYZ(wx, wy)
+	primaries := [3]Vector3{{r.X, g.X, b.X}, {r.Y, g.Y, b.Y}, {r.Z, g.Z, b.Z}}
+	inverse := inverseMatrix3(&primaries)
+	scale := mulMatrix3Vector3(&inverse, white)
+	toXYZ := [3]Vector3{
+		HadamardDotVector3(primaries[0], scale),
+		HadamardDotVector3(primaries[1], scale),
+		HadamardDotVector3(primaries[2], scale)}
+	return &ColorSpace{name, white, toXYZ, inverseMatrix3(&toXYZ)}
+}
+
+var (
+	ColorSpaceRec709    = NewColorSpace("Rec.709", 0.64, 0.33, 0.30, 0.60, 0.15, 0.06, 0.3127, 0.3290)
+	ColorSpaceACEScg    = NewColorSpace("ACEScg", 0.713, 0.293, 0.165, 0.830, 0.128, 0.044, 0.32168, 0.33767)
+	ColorSpaceRec2020   = NewColorSpace("Rec.2020", 0.708, 0.292, 0.170, 0.797, 0.131, 0.046, 0.3127, 0.3290)
+	ColorSpaceDisplayP3 = NewColorSpace("Display P3", 0.680, 0.320, 0.265, 0.690, 0.150, 0.060, 0.3127, 0.3290)
+)
+
+func mulMatrix3(m0, m1 *[3]Vector3) [3]Vector3 {
+	column := func(i int) Vector3 {
+		switch i {
+		case 0:
+			return Vector3{m1[0].X, m1[1].X, m1[2].X}
+		case 1:
+			return Vector3{m1[0].Y, m1[1].Y, m1[2].Y}
+		default:
+			return Vector3{m1[0].Z, m1[1].Z, m1[2].Z}
+		}
+	}
+	var m [3]Vector3
+	for i := 0; i < 3; i++ {
+		m[i] = Vector3{DotVector3(m0[i], column(0)), DotVector3(m0[i], column(1)), DotVector3(m0[i], column(2))}
+	}
+	return m
+}
+
+func inverseMatrix3(m *[3]Vector3) [3]Vector3 {
+	c0 := CrossVector3(m[1], m[2])
+	c1 := CrossVector3(m[2], m[0])
+	c2 := CrossVector3(m[0], m[1])
+	determinant := DotVector3(m[0], c0)
+	if math32.Abs(determinant) <= Epsilon32*Epsilon32 {
+		return [3]Vector3{}
+	}
+	inv := 1.0 / determinant
+	return [3]Vector3{
+		{c0.X * inv, c1.X * inv, c2.X * inv},
+		{c0.Y * inv, c1.Y * inv, c2.Y * inv},
+		{c0.Z * inv, c1.Z * inv, c2.Z * inv}}
+}
+
+// ChromaticAdaptation Bradford matrix which maps colors in XYZ under the source white to the destination white
+func ChromaticAdaptation(source, destination Vector3) [3]Vector3 {
+	s := mulMatrix3Vector3(&bradford, source)
+	d := mulMatrix3Vector3(&bradford, destination)
+	scaled := [3]Vector3{
+		MulVector3(d.X/s.X, bradford[0]),
+		MulVector3(d.Y/s.Y, bradford[1]),
+		MulVector3(d.Z/s.Z, bradford[2])}
+	return mulMatrix3(&inverseBradford, &scaled)
+}
+
+// ColorConversion matrix from a color space to another, including the chromatic adaptation between the whites
+func ColorConversion(from, to *ColorSpace) [3]Vector3 {
+	if from == to {
+		return [3]Vector3{{1.0, 0.0, 0.0}, {0.0, 1.0, 0.0}, {0.0, 0.0, 1.0}}
+	}
+	adaptation := ChromaticAdaptation(from.White, to.White)
+	m := mulMatrix3(&adaptation, &from.ToXYZ)
+	return mulMatrix3(&to.FromXYZ, &m)
+}
+
+func ConvertColor(rgb Vector3, from, to *ColorSpace) Vector3 {
+	m := ColorConversion(from, to)
+	return mulMatrix3Vector3(&m, rgb)
+}
+
+func convertImage(image []Vector3, space *ColorSpace) {
+	m := ColorConversion(space, ColorSpaceRec709)
+	for i := range image {
+		image[i] = mulMatrix3Vector3(&m, image[i])
+	}
+}
+
+// ConvertColorSpace tags the linear image with a color space, then converts it to the working space
+func (texture *ImageTexture) ConvertColorSpace(space *ColorSpace) {
+	convertImage(texture.Image, space)
+	if nil != texture.MipMap {
+		texture.GenerateMipMap()
+	}
+}
+
+// ConvertColorSpace tags the linear image with a color space, then converts it to the working space
+func (env *SphereMap) ConvertColorSpace(space *ColorSpace) {
+	convertImage(env.Image, space)
+	if nil != env.MipMap {
+		env.GenerateMipMap()
+	}
+}
+
+type TransferFunction int32
+
+const (
+	TransferLinear TransferFunction = iota
+	TransferSRGB
+	TransferRec709
+	TransferPQ
+	TransferHLG
+)
+
+const (
+	pqM1 float32 = 2610.0 / 16384.0
+	pqM2 float32 = 2523.0 / 4096.0 * 128.0
+	pqC1 float32 = 3424.0 / 4096.0
+	pqC2 float32 = 2413.0 / 4096.0 * 32.0
+	pqC3 float32 = 2392.0 / 4096.0 * 32.0
+
+	hlgA float32 = 0.17883277
+	hlgB float32 = 0.28466892
+	hlgC float32 = 0.55991073
+	// The scene linear signal of the reference white, which is encoded to 75% by BT.2408
+	hlgReferenceWhite float32 = 0.2647
+)
+
+// rec709OETF ITU-R BT.709 opto-electronic transfer function
+func rec709OETF(x float32) float32 {
+	if x < 0.018 {
+		return 4.5 * x
+	}
+	return 1.099*math32.Pow(x, 0.45) - 0.099
+}
+
+// pqInverseEOTF SMPTE ST 2084 encoding of the luminance normalized by 10000 nits
+func pqInverseEOTF(y float32) float32 {
+	p := math32.Pow(y, pqM1)
+	return math32.Pow((pqC1+pqC2*p)/(1.0+pqC3*p), pqM2)
+}
+
+// hlgOETF ITU-R BT.2100 hybrid log-gamma transfer function of the scene linear signal in [0 1]
+func hlgOETF(e float32) float32 {
+	if e <= 1.0/12.0 {
+		return math32.Sqrt(3.0 * e)
+	}
+	return hlgA*math32.Log(12.0*e-hlgB) + hlgC
+}
+
+// OutputEncoding color space and transfer function of an output image
+//
+// ReferenceWhite is the luminance in nits of the working white for PQ, HLG maps the working white to its reference white.
+// SDR transfers clip colors to [0 1], HDR transfers clip to their peaks.
+type OutputEncoding struct {
+	Space          *ColorSpace
+	Transfer       TransferFunction
+	ReferenceWhite float32
+	conversion     [3]Vector3
+}
+
+func NewOutputEncoding(space *ColorSpace, transfer TransferFunction) OutputEncoding {
+	return OutputEncoding{space, transfer, 203.0, ColorConversion(ColorSpaceRec709, space)}
+}
+
+func (encoding *OutputEncoding) encode(x float32) float32 {
+	x = math32.Max(0.0, x)
+	switch encoding.Transfer {
+	case TransferSRGB:
+		return linearToSRGB(Saturate32(x))
+	case TransferRec709:
+		return rec709OETF(Saturate32(x))
+	case TransferPQ:
+		return pqInverseEOTF(Saturate32(x * encoding.ReferenceWhite / 10000.0))
+	case TransferHLG:
+		return hlgOETF(Saturate32(x * hlgReferenceWhite))
+	default:
+		return x
+	}
+}
+
+// Encode converts a color in the working space to the output
+func (encoding *OutputEncoding) Encode(c Color32) Color32 {
+	rgb := mulMatrix3Vector3(&encoding.conversion, Vector3{c.R, c.G, c.B})
+	return Color32{encoding.encode(rgb.X), encoding.encode(rgb.Y), encoding.encode(rgb.Z), c.A}
+}
@@ -0,0 +1,50 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func assertVector3InDelta(assert *assert.Assertions, expected, actual Vector3, delta float64, message string) {
+	assert.InDeltaf(expected.X, actual.X, delta, message)
+	assert.InDeltaf(expected.Y, actual.Y, delta, message)
+	assert.InDeltaf(expected.Z, actual.Z, delta, message)
+}
+
+func TestColorSpace(t *testing.T) {
+	assert := assert.New(t)
+	for i := 0; i < 3; i++ {
+		assertVector3InDelta(assert, linearSRGBToXYZ[i], ColorSpaceRec709.ToXYZ[i], 1.0e-3, "Rec.709 to XYZ")
+	}
+	white := Vector3{1.0, 1.0, 1.0}
+	spaces := []*ColorSpace{ColorSpaceACEScg, ColorSpaceRec2020, ColorSpaceDisplayP3}
+	for _, space := range spaces {
+		assertVector3InDelta(assert, white, ConvertColor(white, space, ColorSpaceRec709), 1.0e-3, "White should be adapted to white")
+		color := Vector3{0.2, 0.5, 0.8}
+		back := ConvertColor(ConvertColor(color, ColorSpaceRec709, space), space, ColorSpaceRec709)
+		assertVector3InDelta(assert, color, back, 1.0e-4, space.Name)
+	}
+	red := ConvertColor(Vector3{1.0, 0.0, 0.0}, ColorSpaceDisplayP3, ColorSpaceRec709)
+	assert.Truef(1.0 < red.X && red.Y < 0.0, "P3 red should be out of the Rec.709 gamut")
+}
+
+func TestOutputEncoding(t *testing.T) {
+	assert := assert.New(t)
+	srgb := NewOutputEncoding(ColorSpaceRec709, TransferSRGB)
+	c := srgb.Encode(Color32{0.5, 2.0, -1.0, 1.0})
+	assert.InDelta(0.7354, c.R, 1.0e-3)
+	assert.InDeltaf(1.0, c.G, 1.0e-5, "SDR should clip")
+	assert.InDelta(0.0, c.B, 1.0e-5)
+
+	rec709 := NewOutputEncoding(ColorSpaceRec709, TransferRec709)
+	assert.InDelta(1.0, rec709.Encode(Color32{1.0, 1.0, 1.0, 1.0}).R, 1.0e-3)
+
+	pq := NewOutputEncoding(ColorSpaceRec2020, TransferPQ)
+	pq.ReferenceWhite = 100.0
+	assert.InDeltaf(0.508, pq.Encode(Color32{1.0, 1.0, 1.0, 1.0}).G, 2.0e-3, "100 nits")
+	assert.InDeltaf(1.0, pq.Encode(Color32{100.0, 100.0, 100.0, 1.0}).G, 1.0e-3, "10000 nits")
+
+	hlg := NewOutputEncoding(ColorSpaceRec2020, TransferHLG)
+	assert.InDeltaf(0.75, hlg.Encode(Color32{1.0, 1.0, 1.0, 1.0}).G, 1.0e-2, "Reference white")
+	assert.InDelta(0.5, hlgOETF(1.0/12.0), 1.0e-5)
+	assert.InDelta(1.0, hlgOETF(1.0), 1.0e-5)
+}
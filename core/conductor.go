@@ -0,0 +1,155 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"math/cmplx"
+)
+
+// ComplexIOR complex index of refraction, eta + i k, for each channel
+type ComplexIOR struct {
+	Eta Vector3
+	K   Vector3
+}
+
+// ConductorPresets complex indices of refraction of metals sampled at the red, green and blue wavelengths
+var ConductorPresets = map[string]ComplexIOR{
+	"Ag": {Vector3{0.1552646489, 0.1167232965, 0.1383806959}, Vector3{4.8283433224, 3.1222459278, 2.1469504455}},
+	"Al": {Vector3{1.6574599595, 0.8803689579, 0.5212287346}, Vector3{9.2238691996, 6.2695232477, 4.8370012281}},
+	"Au": {Vector3{0.1431189557, 0.3749570432, 1.4424785571}, Vector3{3.9831604247, 2.3857207478, 1.6032152899}},
+	"Cr": {Vector3{4.3696828663, 2.9167024892, 1.6547005413}, Vector3{5.2064337956, 4.2313645277, 3.7549467933}},
+	"Cu": {Vector3{0.2004376970, 0.9240334304, 1.1022119527}, Vector3{3.9129485033, 2.4528477015, 2.1421879552}},
+	"Fe": {Vector3{2.9114, 2.9497, 2.5845}, Vector3{3.0893, 2.9318, 2.7670}},
+	"Pt": {Vector3{2.3757, 2.0847, 1.8453}, Vector3{4.2655, 3.7153, 3.1365}},
+	"Ti": {Vector3{2.7407, 2.5418, 2.2670}, Vector3{3.8143, 3.4345, 3.0385}},
+	"W":  {Vector3{4.3707029924, 3.3002972445, 2.9982666528}, Vector3{3.5006778591, 2.6048652781, 2.2731930614}},
+}
+
+// LookupComplexIOR finds a preset by the name like "Au"
+func LookupComplexIOR(name string) (ComplexIOR, bool) {
+	ior, found := ConductorPresets[name]
+	return ior, found
+}
+
+// fresnelComplex exact Fresnel reflectance of a conductor for unpolarized light
+func fresnelComplex(cosine float32, eta complex128) float32 {
+	cosI := complex(float64(Saturate32(cosine)), 0.0)
+	sin2I := 1.0 - cosI*cosI
+	sin2T := sin2I / (eta * eta)
+	cosT := cmplx.Sqrt(1.0 - sin2T)
+	parallel := (eta*cosI - cosT) / (eta*cosI + cosT)
+	perpendicular := (cosI - eta*cosT) / (cosI + eta*cosT)
+	norm := func(x complex128) float64 {
+		return real(x)*real(x) + imag(x)*imag(x)
+	}
+	return float32(0.5 * (norm(parallel) + norm(perpendicular)))
+}
+
+// FresnelConductor exact Fresnel reflectance of a conductor for each channel
+func FresnelConductor(cosine float32, ior ComplexIOR) Vector3 {
+	return Vector3{
+		fresnelComplex(cosine, complex(float64(ior.Eta.X), float64(ior.K.X))),
+		fresnelComplex(cosine, complex(float64(ior.Eta.Y), float64(ior.K.Y))),
+		fresnelComplex(cosine, complex(float64(ior.Eta.Z), float64(ior.K.Z)))}
+}
+
+// Conductor metal with GGX microfacet reflection and the exact conductor Fresnel
+//
+// FilmThickness in nanometers coats the surface by a thin film of FilmIOR, like anodized metals, zero means no film.
+type Conductor struct {
+	IOR                  ComplexIOR
+	Roughness            float32
+	RoughnessTexture     Texture
+	FilmThickness        float32
+	FilmIOR              float32
+	FilmThicknessTexture Texture
+}
+
+func NewConductor(ior ComplexIOR, roughness float32) Conductor {
+	return Conductor{IOR: ior, Roughness: roughness}
+}
+
+// NewConductorPreset creates a conductor from a preset in ConductorPresets
+func NewConductorPreset(name string, roughness float32) (Conductor, bool) {
+	ior, found := LookupComplexIOR(name)
+	return NewConductor(ior, roughness), found
+}
+
+func (conductor *Conductor) Resolve(hitRecord *HitRecord) Material {
+	if conductor.RoughnessTexture == nil && conductor.FilmThicknessTexture == nil {
+		return conductor
+	}
+	return &Conductor{
+		IOR:           conductor.IOR,
+		Roughness:     ModulateScalar(conductor.Roughness, conductor.RoughnessTexture, hitRecord),
+		FilmThickness: ModulateScalar(conductor.FilmThickness, conductor.FilmThicknessTexture, hitRecord),
+		FilmIOR:       conductor.FilmIOR}
+}
+
+// fresnel reflectance of the surface with the thin film if it exists
+func (conductor *Conductor) fresnel(cosine float32) Vector3 {
+	if conductor.FilmThickness <= 0.0 {
+		return FresnelConductor(cosine, conductor.IOR)
+	}
+	return ThinFilmReflectance(cosine, conductor.FilmThickness, conductor.FilmIOR, func(lambda float32) complex128 {
+		return InterpolateComplexIOR(conductor.IOR, lambda)
+	})
+}
+
+func (conductor *Conductor) distribution() ggx {
+	alpha := math32.Max(0.001, conductor.Roughness)
+	return ggx{alpha, alpha}
+}
+
+func (conductor *Conductor) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	if wi.Z <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	distribution := conductor.distribution()
+	wm := ggx_VNDF(wi, distribution.alphaX, distribution.alphaY, eta0, eta1)
+	wo := Reflect(wi.Minus(), wm)
+	if wo.Z <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, wo}
+	}
+	f := conductor.fresnel(DotVector3(wo, wm))
+	g1 := distribution.G1(wi)
+	g2 := distribution.G2(wi, wo)
+	pdf := distribution.PdfReflection(wi, wm)
+	return MaterialSample{true, pdf, MulVector3(g2/g1, f), wo}
+}
+
+func (conductor *Conductor) Eval(wi, wo Vector3) Vector3 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return Vector3{}
+	}
+	wm := NormalizeVector3(AddVector3(wi, wo))
+	distribution := conductor.distribution()
+	f := conductor.fresnel(DotVector3(wo, wm))
+	d := distribution.D(wm)
+	g2 := distribution.G2(wi, wo)
+	return MulVector3(d*g2/(4.0*wi.Z), f)
+}
+
+func (conductor *Conductor) Pdf(wi, wo Vector3) float32 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return 0.0
+	}
+	wm := NormalizeVector3(AddVector3(wi, wo))
+	return conductor.distribution().PdfReflection(wi, wm)
+}
+
+func (conductor *Conductor) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return scatterBySample(conductor, ray, hitRecord, attenuation, scattered)
+}
+
+func (conductor *Conductor) GetRoughness() float32 {
+	return conductor.Roughness
+}
+
+func (conductor *Conductor) GetMetallic() float32 {
+	return 1.0
+}
+
+// GetAlbedo returns the reflectance at normal incidence, that is F0 of the split-sum approximation
+func (conductor *Conductor) GetAlbedo() Vector3 {
+	return conductor.fresnel(1.0)
+}
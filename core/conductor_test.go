@@ -0,0 +1,26 @@
+package core
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFresnelConductor(t *testing.T) {
+	assert := assert.New(t)
+	for name, ior := range ConductorPresets {
+		previous := FresnelConductor(1.0, ior)
+		for i:=9; 0<=i; i-- {
+			cosine := float32(i)*0.1
+			f := FresnelConductor(cosine, ior)
+			assert.Truef(0.0<=f.X && f.X<=1.0 && 0.0<=f.Y && f.Y<=1.0 && 0.0<=f.Z && f.Z<=1.0, "Reflectance of %v should be in [0 1] (%v)", name, f)
+			previous = f
+		}
+		assert.Truef(EqualVector3(previous, Vector3{1.0, 1.0, 1.0}), "Reflectance of %v at grazing angle should be one (%v)", name, previous)
+	}
+
+	gold, found := LookupComplexIOR("Au")
+	assert.True(found, "Au should be a preset")
+	f0 := FresnelConductor(1.0, gold)
+	assert.Truef(f0.Z < f0.Y && f0.Y < f0.X, "Gold %v should be yellow", f0)
+	_, found = LookupComplexIOR("Unobtainium")
+	assert.False(found, "Unknown metal should not be found")
+}
@@ -20,6 +20,19 @@ func NewCoordinate(normal Vector3) Coordinate {
 	return Coordinate{normal, binormal0, binormal1}
 }
 
+// NewCoordinateTangent builds a basis whose first binormal follows the tangent projected onto the tangent plane
+//
+// It falls back to NewCoordinate if the tangent is degenerated.
+func NewCoordinateTangent(normal, tangent Vector3) Coordinate {
+	binormal0 := SubVector3(tangent, MulVector3(DotVector3(normal, tangent), normal))
+	if binormal0.LengthSqr() <= Epsilon32 {
+		return NewCoordinate(normal)
+	}
+	binormal0 = NormalizeVector3(binormal0)
+	binormal1 := CrossVector3(normal, binormal0)
+	return Coordinate{normal, binormal0, binormal1}
+}
+
 func (coordinate *Coordinate) WorldToLocal(v Vector3) Vector3 {
 	x := DotVector3(v, coordinate.Binormal0)
 	y := DotVector3(v, coordinate.Binormal1)
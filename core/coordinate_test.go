@@ -48,3 +48,18 @@ func TestRandomHemiSphereCoordinate(t *testing.T) {
 		assert.Truef(0.0<=d && d<=1.0, "Dot of %v and %v sholud be (0 1)", n0, n1)
 	}
 }
+
+func TestCoordinateTangent(t *testing.T) {
+	assert := assert.New(t)
+	random := rand.New(rand.NewSource(1))
+	for i:=0; i<100; i++ {
+		n := NormalizeVector3(Vector3{random.Float32()-0.5, random.Float32()-0.5, random.Float32()-0.5})
+		tangent := NormalizeVector3(Vector3{random.Float32()-0.5, random.Float32()-0.5, random.Float32()-0.5})
+		coordinate := NewCoordinateTangent(n, tangent)
+		x := coordinate.LocalToWorld(Vector3{1.0, 0.0, 0.0})
+		assert.InDeltaf(1.0, x.Length(), 1.0e-5, "Length of %v should be 1", x)
+		assert.InDeltaf(0.0, DotVector3(n, x), 1.0e-5, "%v should be perpendicular to %v", x, n)
+		assert.Truef(0.0<=DotVector3(tangent, x), "%v should follow %v", x, tangent)
+		assert.InDeltaf(1.0, coordinate.WorldToLocal(n).Z, 1.0e-5, "Local normal should be Z")
+	}
+}
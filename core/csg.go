@@ -0,0 +1,171 @@
+package core
+
+// Interval where a ray is inside a solid, Entry and Exit are the records of its boundaries with Entry.T < Exit.T
+type Interval struct {
+	Entry HitRecord
+	Exit  HitRecord
+}
+
+// Solid is a closed surface which reports all the intervals of a ray inside it
+//
+// Intervals appends the intervals along the whole line of the ray, in ascending order and disjoint,
+// the normals of their records point out of the solid.
+type Solid interface {
+	Hittable
+	Intervals(ray Ray, intervals []Interval) []Interval
+}
+
+type CSGOperation int32
+
+const (
+	CSGUnion CSGOperation = iota
+	CSGIntersection
+	CSGDifference
+)
+
+// CSG boolean combination of two solids, which is a solid itself
+//
+// The surface keeps the records of the solids it comes from, the surfaces of Right which bound a difference
+// are turned inside out. A non nil Material overrides the materials of both. Alpha is not tested.
+//
+// Scott D. Roth, "Ray Casting for Modeling Solids", 1982
+type CSG struct {
+	Operation CSGOperation
+	Left      Solid
+	Right     Solid
+	Material  Material
+}
+
+func NewCSG(operation CSGOperation, left, right Solid) *CSG {
+	return &CSG{operation, left, right, nil}
+}
+
+func (csg *CSG) inside(left, right bool) bool {
+	switch csg.Operation {
+	case CSGIntersection:
+		return left && right
+	case CSGDifference:
+		return left && !right
+	default:
+		return left || right
+	}
+}
+
+// csgEvent boundary of an operand, entering if Entry, right if it belongs to the second operand
+type csgEvent struct {
+	Record *HitRecord
+	Entry  bool
+	Right  bool
+}
+
+func (csg *CSG) Intervals(ray Ray, intervals []Interval) []Interval {
+	left := csg.Left.Intervals(ray, nil)
+	if 0 == len(left) && CSGUnion != csg.Operation {
+		return intervals
+	}
+	right := csg.Right.Intervals(ray, nil)
+	if 0 == len(right) && CSGIntersection == csg.Operation {
+		return intervals
+	}
+
+	// Merge the boundaries of both operands by T and emit where the combined state changes
+	events := make([]csgEvent, 0, 2*(len(left)+len(right)))
+	i, j := 0, 0
+	for i < 2*len(left) || j < 2*len(right) {
+		var leftEvent, rightEvent csgEvent
+		if i < 2*len(left) {
+			leftEvent = csgEvent{intervalRecord(left, i), 0 == i%2, false}
+		}
+		if j < 2*len(right) {
+			rightEvent = csgEvent{intervalRecord(right, j), 0 == j%2, true}
+		}
+		if nil != leftEvent.Record && (nil == rightEvent.Record || leftEvent.Record.T <= rightEvent.Record.T) {
+			events = append(events, leftEvent)
+			i++
+		} else {
+			events = append(events, rightEvent)
+			j++
+		}
+	}
+
+	inLeft, inRight, inside := false, false, false
+	interval := Interval{}
+	for _, event := range events {
+		if event.Right {
+			inRight = event.Entry
+		} else {
+			inLeft = event.Entry
+		}
+		now := csg.inside(inLeft, inRight)
+		if now == inside {
+			continue
+		}
+		inside = now
+		record := *event.Record
+		if event.Right && CSGDifference == csg.Operation {
+			flipRecord(&record)
+		}
+		if nil != csg.Material {
+			record.Material = csg.Material
+		}
+		if inside {
+			interval.Entry = record
+			continue
+		}
+		interval.Exit = record
+		if interval.Entry.T < interval.Exit.T {
+			intervals = append(intervals, interval)
+		}
+	}
+	return intervals
+}
+
+// intervalRecord returns the entry of an interval for even indices and the exit for odd ones
+func intervalRecord(intervals []Interval, index int) *HitRecord {
+	if 0 == index%2 {
+		return &intervals[index/2].Entry
+	}
+	return &intervals[index/2].Exit
+}
+
+// flipRecord turns a surface inside out, U is reversed to keep the derivatives right handed around the normal
+func flipRecord(record *HitRecord) {
+	record.UV.X = 1.0 - record.UV.X
+	record.Normal = record.Normal.Minus()
+	record.GeometricNormal = record.GeometricNormal.Minus()
+	record.Tangent = record.Tangent.Minus()
+	record.DPDU = record.DPDU.Minus()
+	record.DNDV = record.DNDV.Minus()
+}
+
+// Hit finds the first boundary of the combined intervals in (tmin tmax)
+func (csg *CSG) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	for _, interval := range csg.Intervals(ray, nil) {
+		if tmin < interval.Entry.T && interval.Entry.T < tmax {
+			*record = interval.Entry
+			return true
+		}
+		if tmin < interval.Exit.T && interval.Exit.T < tmax {
+			*record = interval.Exit
+			return true
+		}
+	}
+	return false
+}
+
+func (csg *CSG) BoundingBox() AABB {
+	left := csg.Left.BoundingBox()
+	switch csg.Operation {
+	case CSGIntersection:
+		right := csg.Right.BoundingBox()
+		box := AABB{maxVector3(left.Min, right.Min), minVector3(left.Max, right.Max)}
+		if box.IsEmpty() {
+			return NewEmptyAABB()
+		}
+		return box
+	case CSGDifference:
+		return left
+	default:
+		return UnionAABB(left, csg.Right.BoundingBox())
+	}
+}
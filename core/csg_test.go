@@ -0,0 +1,96 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSolidIntervals(t *testing.T) {
+	assert := assert.New(t)
+	ray := Ray{Vector3{0.0, 0.0, 5.0}, Vector3{0.0, 0.0, -1.0}, 0.0}
+	box := NewBox(Vector3{-1.0, -1.0, -1.0}, Vector3{1.0, 1.0, 1.0}, nil)
+	cylinder := NewCylinder(Vector3{0.0, 0.0, -2.0}, Vector3{0.0, 0.0, 1.0}, 0.5, 4.0, nil)
+	cone := NewCone(Vector3{0.0, 0.0, -1.0}, Vector3{0.0, 0.0, 1.0}, 1.0, 2.0, nil)
+	for _, test := range []struct {
+		Solid Solid
+		Entry float32
+		Exit  float32
+	}{
+		{&Sphere{Vector3{}, 1.0, nil}, 4.0, 6.0},
+		{&box, 4.0, 6.0},
+		{&cylinder, 3.0, 7.0},
+	} {
+		intervals := test.Solid.Intervals(ray, nil)
+		if !assert.Equal(1, len(intervals)) {
+			continue
+		}
+		assert.InDelta(test.Entry, intervals[0].Entry.T, 1.0e-5)
+		assert.InDelta(test.Exit, intervals[0].Exit.T, 1.0e-5)
+		assert.InDelta(1.0, intervals[0].Entry.Normal.Z, 1.0e-5)
+		assert.InDelta(-1.0, intervals[0].Exit.Normal.Z, 1.0e-5)
+	}
+	// The apex of the cone is the exit from below
+	intervals := cone.Intervals(Ray{Vector3{0.0, 0.0, -5.0}, Vector3{0.0, 0.0, 1.0}, 0.0}, nil)
+	if assert.Equal(1, len(intervals)) {
+		assert.InDelta(4.0, intervals[0].Entry.T, 1.0e-5)
+		assert.InDelta(-1.0, intervals[0].Entry.Normal.Z, 1.0e-5)
+		assert.InDelta(6.0, intervals[0].Exit.T, 1.0e-5)
+	}
+	assert.Equal(0, len(box.Intervals(Ray{Vector3{3.0, 0.0, 5.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, nil)))
+}
+
+func TestCSG(t *testing.T) {
+	assert := assert.New(t)
+	red := &Lambertian{Vector3{1.0, 0.0, 0.0}, nil}
+	blue := &Lambertian{Vector3{0.0, 0.0, 1.0}, nil}
+	box := NewBox(Vector3{-1.0, -1.0, -1.0}, Vector3{1.0, 1.0, 1.0}, red)
+	sphere := &Sphere{Vector3{0.0, 0.0, 1.0}, 1.0, blue}
+	ray := Ray{Vector3{0.0, 0.0, 5.0}, Vector3{0.0, 0.0, -1.0}, 0.0}
+	record := HitRecord{}
+
+	union := NewCSG(CSGUnion, &box, sphere)
+	intervals := union.Intervals(ray, nil)
+	assert.Equal(1, len(intervals))
+	assert.True(union.Hit(ray, 0.001, Infinity32, &record))
+	assert.InDelta(3.0, record.T, 1.0e-5)
+	assert.Equal(Material(blue), record.Material)
+	// Inside the union the next boundary is the exit of the box
+	assert.True(union.Hit(ray, 3.5, Infinity32, &record))
+	assert.InDelta(6.0, record.T, 1.0e-5)
+	assert.Equal(Material(red), record.Material)
+
+	intersection := NewCSG(CSGIntersection, &box, sphere)
+	assert.True(intersection.Hit(ray, 0.001, Infinity32, &record))
+	assert.InDelta(4.0, record.T, 1.0e-5)
+	assert.Equal(Material(red), record.Material)
+	assert.True(intersection.Hit(ray, 4.5, Infinity32, &record))
+	assert.InDelta(5.0, record.T, 1.0e-5)
+	assert.Equal(Material(blue), record.Material)
+	assert.InDelta(-1.0, record.Normal.Z, 1.0e-5)
+
+	// The hollow of the sphere bounds the difference, its normal points out of the box
+	difference := NewCSG(CSGDifference, &box, sphere)
+	assert.True(difference.Hit(ray, 0.001, Infinity32, &record))
+	assert.InDelta(5.0, record.T, 1.0e-5)
+	assert.Equal(Material(blue), record.Material)
+	assert.InDelta(1.0, record.Normal.Z, 1.0e-5)
+	cross := CrossVector3(record.DPDU, record.DPDV)
+	assert.True(0.0 < DotVector3(cross, record.Normal))
+	// U is reversed along with its derivative
+	inside := HitRecord{}
+	assert.True(sphere.Hit(Ray{Vector3{0.0, 0.0, 1.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &inside))
+	assert.InDelta(1.0-inside.UV.X, record.UV.X, 1.0e-5)
+	assert.InDelta(inside.UV.Y, record.UV.Y, 1.0e-5)
+	assert.False(difference.Hit(Ray{Vector3{0.0, 0.0, 5.0}, Vector3{0.0, 0.0, 1.0}, 0.0}, 0.001, Infinity32, &record))
+	box2 := difference.BoundingBox()
+	assert.Equal(box.BoundingBox(), box2)
+
+	// Combinations nest
+	nested := NewCSG(CSGDifference, union, &Sphere{Vector3{0.0, 0.0, 0.0}, 0.5, nil})
+	nested.Material = red
+	assert.Equal(2, len(nested.Intervals(ray, nil)))
+	assert.True(nested.Hit(ray, 0.001, Infinity32, &record))
+	assert.Equal(Material(red), record.Material)
+	bounds := NewCSG(CSGIntersection, &box, sphere).BoundingBox()
+	assert.InDelta(0.0, bounds.Min.Z, 1.0e-5)
+	assert.InDelta(1.0, bounds.Max.Z, 1.0e-5)
+}
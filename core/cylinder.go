@@ -0,0 +1,377 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+const (
+	quadricSide int = iota
+	quadricBottom
+	quadricTop
+)
+
+// quadricHit candidate intersection with a part of a capped quadric
+type quadricHit struct {
+	t    float32
+	part int
+}
+
+// sortQuadricHits sorts a few candidates by insertion
+func sortQuadricHits(hits []quadricHit) {
+	for i := 1; i < len(hits); i++ {
+		for j := i; 0 < j && hits[j].t < hits[j-1].t; j-- {
+			hits[j], hits[j-1] = hits[j-1], hits[j]
+		}
+	}
+}
+
+// solveQuadratic returns the real roots of a*t^2 + 2*b*t + c in ascending order
+func solveQuadratic(a, b, c float32) (float32, float32, bool) {
+	if math32.Abs(a) <= Epsilon32*Epsilon32 {
+		if math32.Abs(b) <= Epsilon32*Epsilon32 {
+			return 0.0, 0.0, false
+		}
+		t := -c / (2.0 * b)
+		return t, t, true
+	}
+	discriminant := b*b - a*c
+	if discriminant < 0.0 {
+		return 0.0, 0.0, false
+	}
+	discriminant = math32.Sqrt(discriminant)
+	t0 := (-b - discriminant) / a
+	t1 := (-b + discriminant) / a
+	if t1 < t0 {
+		t0, t1 = t1, t0
+	}
+	return t0, t1, true
+}
+
+// quadricInterval the first and the last hits bound the segment through a convex quadric
+func quadricInterval(hits []quadricHit) (quadricHit, quadricHit, bool) {
+	if len(hits) < 2 || hits[len(hits)-1].t <= hits[0].t {
+		return quadricHit{}, quadricHit{}, false
+	}
+	return hits[0], hits[len(hits)-1], true
+}
+
+// capRecord fills UV and the derivatives of a cap, UV maps the square around the cap to [0 1]
+func capRecord(frame *axisFrame, local Vector3, radius, sign float32) (Vector2, Vector3, Vector3) {
+	uv := Vector2{0.5 + 0.5*sign*local.X/radius, 0.5 + 0.5*local.Y/radius}
+	return uv, MulVector3(2.0*sign*radius, frame.Tangent), MulVector3(2.0*radius, frame.Bitangent)
+}
+
+// lateralAngle returns the angle of a local point around the axis
+func lateralAngle(local Vector3) float32 {
+	phi := math32.Atan2(local.Y, local.X)
+	if phi < 0.0 {
+		phi += 2.0 * math32.Pi
+	}
+	return phi
+}
+
+// Cylinder capped cylinder from Base along Axis by Height
+//
+// UV of the side is the angle around the axis and the height normalized to [0 1].
+type Cylinder struct {
+	Base     Vector3
+	Axis     Vector3
+	Radius   float32
+	Height   float32
+	Material Material
+}
+
+func NewCylinder(base, axis Vector3, radius, height float32, material Material) Cylinder {
+	return Cylinder{base, NormalizeVector3(axis), radius, height, material}
+}
+
+// hits returns the intersections of the local line with the side and the caps in ascending order
+func (cylinder *Cylinder) hits(origin, direction Vector3) []quadricHit {
+	r2 := cylinder.Radius * cylinder.Radius
+	hits := make([]quadricHit, 0, 4)
+	a := direction.X*direction.X + direction.Y*direction.Y
+	b := origin.X*direction.X + origin.Y*direction.Y
+	c := origin.X*origin.X + origin.Y*origin.Y - r2
+	if t0, t1, valid := solveQuadratic(a, b, c); valid {
+		for _, t := range [2]float32{t0, t1} {
+			z := origin.Z + t*direction.Z
+			if 0.0 <= z && z <= cylinder.Height {
+				hits = append(hits, quadricHit{t, quadricSide})
+			}
+		}
+	}
+	if Epsilon32*Epsilon32 < math32.Abs(direction.Z) {
+		for _, part := range [2]int{quadricBottom, quadricTop} {
+			z := float32(0.0)
+			if part == quadricTop {
+				z = cylinder.Height
+			}
+			t := (z - origin.Z) / direction.Z
+			x := origin.X + t*direction.X
+			y := origin.Y + t*direction.Y
+			if x*x+y*y <= r2 {
+				hits = append(hits, quadricHit{t, part})
+			}
+		}
+	}
+	sortQuadricHits(hits)
+	return hits
+}
+
+func (cylinder *Cylinder) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	frame := newAxisFrame(cylinder.Axis)
+	origin := frame.toLocal(SubVector3(ray.Origin, cylinder.Base))
+	direction := frame.toLocal(ray.Direction)
+	hits := cylinder.hits(origin, direction)
+	for _, hit := range hits {
+		if hit.t <= tmin || tmax <= hit.t {
+			continue
+		}
+		local := AddVector3(origin, MulVector3(hit.t, direction))
+		cylinder.setRecord(ray, &frame, local, hit, record)
+		if alphaTest(record) {
+			return true
+		}
+	}
+	return false
+}
+
+// Intervals returns the segment of the ray through the cylinder
+func (cylinder *Cylinder) Intervals(ray Ray, intervals []Interval) []Interval {
+	frame := newAxisFrame(cylinder.Axis)
+	origin := frame.toLocal(SubVector3(ray.Origin, cylinder.Base))
+	direction := frame.toLocal(ray.Direction)
+	entry, exit, valid := quadricInterval(cylinder.hits(origin, direction))
+	if !valid {
+		return intervals
+	}
+	interval := Interval{}
+	cylinder.setRecord(ray, &frame, AddVector3(origin, MulVector3(entry.t, direction)), entry, &interval.Entry)
+	cylinder.setRecord(ray, &frame, AddVector3(origin, MulVector3(exit.t, direction)), exit, &interval.Exit)
+	return append(intervals, interval)
+}
+
+func (cylinder *Cylinder) setRecord(ray Ray, frame *axisFrame, local Vector3, hit quadricHit, record *HitRecord) {
+	switch hit.part {
+	case quadricBottom:
+		uv, dpdu, dpdv := capRecord(frame, local, cylinder.Radius, -1.0)
+		setFlatRecord(record, ray, hit.t, frame.Axis.Minus(), uv, dpdu, dpdv, cylinder.Material)
+	case quadricTop:
+		uv, dpdu, dpdv := capRecord(frame, local, cylinder.Radius, 1.0)
+		setFlatRecord(record, ray, hit.t, frame.Axis, uv, dpdu, dpdv, cylinder.Material)
+	default:
+		phi := lateralAngle(local)
+		cs := math32.Cos(phi)
+		sn := math32.Sin(phi)
+		normal := frame.toWorld(Vector3{cs, sn, 0.0})
+		dpdu := frame.toWorld(Vector3{-2.0 * math32.Pi * cylinder.Radius * sn, 2.0 * math32.Pi * cylinder.Radius * cs, 0.0})
+		record.T = hit.t
+		record.Position = ray.PointAt(hit.t)
+		record.Normal = normal
+		record.GeometricNormal = normal
+		record.Tangent = NormalizeVector3(dpdu)
+		record.UV = Vector2{phi / (2.0 * math32.Pi), local.Z / cylinder.Height}
+		record.DPDU = dpdu
+		record.DPDV = MulVector3(cylinder.Height, frame.Axis)
+		record.DNDU = DivVector3(dpdu, cylinder.Radius)
+		record.DNDV = Vector3{}
+		record.Color = Vector3{1.0, 1.0, 1.0}
+		record.Material = cylinder.Material
+	}
+}
+
+// capExtent extent of a circle of a radius perpendicular to axis along each world axis
+//
+// The extent along an axis is the radius multiplied by the sine of the angle between the axis and the circle's axis.
+func capExtent(axis Vector3, radius float32) Vector3 {
+	return Vector3{
+		radius * math32.Sqrt(math32.Max(0.0, 1.0-axis.X*axis.X)),
+		radius * math32.Sqrt(math32.Max(0.0, 1.0-axis.Y*axis.Y)),
+		radius * math32.Sqrt(math32.Max(0.0, 1.0-axis.Z*axis.Z))}
+}
+
+func (cylinder *Cylinder) BoundingBox() AABB {
+	extent := capExtent(cylinder.Axis, cylinder.Radius)
+	top := AddVector3(cylinder.Base, MulVector3(cylinder.Height, cylinder.Axis))
+	box := AABB{SubVector3(cylinder.Base, extent), AddVector3(cylinder.Base, extent)}
+	return UnionAABB(box, AABB{SubVector3(top, extent), AddVector3(top, extent)})
+}
+
+func (cylinder *Cylinder) Area() float32 {
+	return 2.0 * math32.Pi * cylinder.Radius * (cylinder.Height + cylinder.Radius)
+}
+
+// SampleArea selects the side or a cap by its area with u0, then reuses u0 for the position
+func (cylinder *Cylinder) SampleArea(u0, u1 float32) (Vector3, Vector3) {
+	frame := newAxisFrame(cylinder.Axis)
+	side := 2.0 * math32.Pi * cylinder.Radius * cylinder.Height
+	capArea := math32.Pi * cylinder.Radius * cylinder.Radius
+	x := u0 * (side + 2.0*capArea)
+	phi := 2.0 * math32.Pi * u1
+	cs := math32.Cos(phi)
+	sn := math32.Sin(phi)
+	if x < side {
+		z := cylinder.Height * x / side
+		local := Vector3{cylinder.Radius * cs, cylinder.Radius * sn, z}
+		return AddVector3(cylinder.Base, frame.toWorld(local)), frame.toWorld(Vector3{cs, sn, 0.0})
+	}
+	x -= side
+	normal := frame.Axis.Minus()
+	z := float32(0.0)
+	if capArea <= x {
+		x -= capArea
+		normal = frame.Axis
+		z = cylinder.Height
+	}
+	r := cylinder.Radius * math32.Sqrt(Saturate32(x/capArea))
+	local := Vector3{r * cs, r * sn, z}
+	return AddVector3(cylinder.Base, frame.toWorld(local)), normal
+}
+
+// Cone cone of Radius at Base whose apex is at Height along Axis, capped at the base
+//
+// UV of the side is the angle around the axis and the height normalized to [0 1].
+type Cone struct {
+	Base     Vector3
+	Axis     Vector3
+	Radius   float32
+	Height   float32
+	Material Material
+}
+
+func NewCone(base, axis Vector3, radius, height float32, material Material) Cone {
+	return Cone{base, NormalizeVector3(axis), radius, height, material}
+}
+
+// hits returns the intersections of the local line with the side and the base in ascending order
+func (cone *Cone) hits(origin, direction Vector3) []quadricHit {
+	// x^2 + y^2 = (k (h - z))^2
+	k := cone.Radius / cone.Height
+	k2 := k * k
+	hz := cone.Height - origin.Z
+	hits := make([]quadricHit, 0, 3)
+	a := direction.X*direction.X + direction.Y*direction.Y - k2*direction.Z*direction.Z
+	b := origin.X*direction.X + origin.Y*direction.Y + k2*hz*direction.Z
+	c := origin.X*origin.X + origin.Y*origin.Y - k2*hz*hz
+	if t0, t1, valid := solveQuadratic(a, b, c); valid {
+		for _, t := range [2]float32{t0, t1} {
+			z := origin.Z + t*direction.Z
+			if 0.0 <= z && z <= cone.Height {
+				hits = append(hits, quadricHit{t, quadricSide})
+			}
+		}
+	}
+	if Epsilon32*Epsilon32 < math32.Abs(direction.Z) {
+		t := -origin.Z / direction.Z
+		x := origin.X + t*direction.X
+		y := origin.Y + t*direction.Y
+		if x*x+y*y <= cone.Radius*cone.Radius {
+			hits = append(hits, quadricHit{t, quadricBottom})
+		}
+	}
+	sortQuadricHits(hits)
+	return hits
+}
+
+func (cone *Cone) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	frame := newAxisFrame(cone.Axis)
+	origin := frame.toLocal(SubVector3(ray.Origin, cone.Base))
+	direction := frame.toLocal(ray.Direction)
+	hits := cone.hits(origin, direction)
+	for _, hit := range hits {
+		if hit.t <= tmin || tmax <= hit.t {
+			continue
+		}
+		local := AddVector3(origin, MulVector3(hit.t, direction))
+		cone.setRecord(ray, &frame, local, hit, record)
+		if alphaTest(record) {
+			return true
+		}
+	}
+	return false
+}
+
+// Intervals returns the segment of the ray through the cone
+func (cone *Cone) Intervals(ray Ray, intervals []Interval) []Interval {
+	frame := newAxisFrame(cone.Axis)
+	origin := frame.toLocal(SubVector3(ray.Origin, cone.Base))
+	direction := frame.toLocal(ray.Direction)
+	entry, exit, valid := quadricInterval(cone.hits(origin, direction))
+	if !valid {
+		return intervals
+	}
+	interval := Interval{}
+	cone.setRecord(ray, &frame, AddVector3(origin, MulVector3(entry.t, direction)), entry, &interval.Entry)
+	cone.setRecord(ray, &frame, AddVector3(origin, MulVector3(exit.t, direction)), exit, &interval.Exit)
+	return append(intervals, interval)
+}
+
+// sideNormal normal of the side at an angle, which is constant along the generating line
+func (cone *Cone) sideNormal(cs, sn float32) Vector3 {
+	slant := math32.Sqrt(cone.Height*cone.Height + cone.Radius*cone.Radius)
+	return Vector3{cs * cone.Height / slant, sn * cone.Height / slant, cone.Radius / slant}
+}
+
+func (cone *Cone) setRecord(ray Ray, frame *axisFrame, local Vector3, hit quadricHit, record *HitRecord) {
+	if hit.part == quadricBottom {
+		uv, dpdu, dpdv := capRecord(frame, local, cone.Radius, -1.0)
+		setFlatRecord(record, ray, hit.t, frame.Axis.Minus(), uv, dpdu, dpdv, cone.Material)
+		return
+	}
+	phi := lateralAngle(local)
+	cs := math32.Cos(phi)
+	sn := math32.Sin(phi)
+	k := cone.Radius / cone.Height
+	r := k * (cone.Height - local.Z)
+	n := cone.sideNormal(cs, sn)
+	normal := frame.toWorld(n)
+	dpdu := frame.toWorld(Vector3{-2.0 * math32.Pi * r * sn, 2.0 * math32.Pi * r * cs, 0.0})
+	if r <= Epsilon32 {
+		// The apex
+		dpdu = frame.toWorld(Vector3{-sn, cs, 0.0})
+	}
+	record.T = hit.t
+	record.Position = ray.PointAt(hit.t)
+	record.Normal = normal
+	record.GeometricNormal = normal
+	record.Tangent = NormalizeVector3(dpdu)
+	record.UV = Vector2{phi / (2.0 * math32.Pi), local.Z / cone.Height}
+	record.DPDU = dpdu
+	record.DPDV = frame.toWorld(Vector3{-cone.Radius * cs, -cone.Radius * sn, cone.Height})
+	record.DNDU = frame.toWorld(Vector3{-2.0 * math32.Pi * n.Y, 2.0 * math32.Pi * n.X, 0.0})
+	record.DNDV = Vector3{}
+	record.Color = Vector3{1.0, 1.0, 1.0}
+	record.Material = cone.Material
+}
+
+func (cone *Cone) BoundingBox() AABB {
+	extent := capExtent(cone.Axis, cone.Radius)
+	apex := AddVector3(cone.Base, MulVector3(cone.Height, cone.Axis))
+	return ExpandAABB(AABB{SubVector3(cone.Base, extent), AddVector3(cone.Base, extent)}, apex)
+}
+
+func (cone *Cone) Area() float32 {
+	slant := math32.Sqrt(cone.Height*cone.Height + cone.Radius*cone.Radius)
+	return math32.Pi * cone.Radius * (slant + cone.Radius)
+}
+
+// SampleArea selects the side or the base by its area with u0, then reuses u0 for the position
+func (cone *Cone) SampleArea(u0, u1 float32) (Vector3, Vector3) {
+	frame := newAxisFrame(cone.Axis)
+	slant := math32.Sqrt(cone.Height*cone.Height + cone.Radius*cone.Radius)
+	side := math32.Pi * cone.Radius * slant
+	base := math32.Pi * cone.Radius * cone.Radius
+	x := u0 * (side + base)
+	phi := 2.0 * math32.Pi * u1
+	cs := math32.Cos(phi)
+	sn := math32.Sin(phi)
+	if x < side {
+		// The area grows by the square of the distance from the apex
+		s := math32.Sqrt(x / side)
+		local := Vector3{s * cone.Radius * cs, s * cone.Radius * sn, cone.Height * (1.0 - s)}
+		return AddVector3(cone.Base, frame.toWorld(local)), frame.toWorld(cone.sideNormal(cs, sn))
+	}
+	r := cone.Radius * math32.Sqrt(Saturate32((x-side)/base))
+	local := Vector3{r * cs, r * sn, 0.0}
+	return AddVector3(cone.Base, frame.toWorld(local)), frame.Axis.Minus()
+}
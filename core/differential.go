@@ -0,0 +1,128 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// ComputeDifferentials computes the screen space derivatives of the position and UV at a hit
+//
+// The offset rays are intersected with the tangent plane, then the derivatives of UV are solved by least squares.
+// The derivatives are zero if the ray has no differentials.
+func (hitRecord *HitRecord) ComputeDifferentials(ray *RayDifferential) {
+	hitRecord.DPDX = Vector3{}
+	hitRecord.DPDY = Vector3{}
+	hitRecord.DUVDX = Vector2{}
+	hitRecord.DUVDY = Vector2{}
+	if !ray.HasDifferentials {
+		return
+	}
+	normal := hitRecord.GeometricNormal
+	d := DotVector3(normal, hitRecord.Position)
+	intersect := func(origin, direction Vector3) (Vector3, bool) {
+		cosine := DotVector3(normal, direction)
+		if math32.Abs(cosine) <= Epsilon32 {
+			return Vector3{}, false
+		}
+		t := (d - DotVector3(normal, origin)) / cosine
+		return AddVector3(origin, MulVector3(t, direction)), true
+	}
+	px, validX := intersect(ray.RxOrigin, ray.RxDirection)
+	py, validY := intersect(ray.RyOrigin, ray.RyDirection)
+	if !validX || !validY {
+		return
+	}
+	hitRecord.DPDX = SubVector3(px, hitRecord.Position)
+	hitRecord.DPDY = SubVector3(py, hitRecord.Position)
+
+	ata00 := DotVector3(hitRecord.DPDU, hitRecord.DPDU)
+	ata01 := DotVector3(hitRecord.DPDU, hitRecord.DPDV)
+	ata11 := DotVector3(hitRecord.DPDV, hitRecord.DPDV)
+	determinant := ata00*ata11 - ata01*ata01
+	if math32.Abs(determinant) <= Epsilon32*Epsilon32 {
+		return
+	}
+	invDeterminant := 1.0 / determinant
+	solve := func(dp Vector3) Vector2 {
+		atb0 := DotVector3(hitRecord.DPDU, dp)
+		atb1 := DotVector3(hitRecord.DPDV, dp)
+		du := (ata11*atb0 - ata01*atb1) * invDeterminant
+		dv := (ata00*atb1 - ata01*atb0) * invDeterminant
+		if math32.IsNaN(du) || math32.IsInf(du, 0) || math32.IsNaN(dv) || math32.IsInf(dv, 0) {
+			return Vector2{}
+		}
+		return Vector2{du, dv}
+	}
+	hitRecord.DUVDX = solve(hitRecord.DPDX)
+	hitRecord.DUVDY = solve(hitRecord.DPDY)
+}
+
+// Refractor is implemented by materials which refract, GetIOR is the index inside relative to the side of the normal
+type Refractor interface {
+	GetIOR() float32
+}
+
+// RelativeIOR returns the relative index of refraction of a material, 1 if it does not refract
+func RelativeIOR(material Material) float32 {
+	if refractor, ok := material.(Refractor); ok {
+		return refractor.GetIOR()
+	}
+	return 1.0
+}
+
+// SpawnRay spawns a ray from a hit towards direction, ior is the relative index of refraction of the material
+//
+// The differentials are propagated only through specular reflection and refraction, others lose the differentials.
+// Matt Pharr, Wenzel Jakob, Greg Humphreys, "Physically Based Rendering: From Theory to Implementation", 3rd edition, 10.1.3 Ray Differentials for Specular Reflection and Transmission
+func (hitRecord *HitRecord) SpawnRay(ray *RayDifferential, direction Vector3, specular bool, ior float32) RayDifferential {
+	spawned := RayDifferential{Ray: Ray{hitRecord.Position, direction, ray.Time}}
+	if !ray.HasDifferentials || !specular {
+		return spawned
+	}
+	n := hitRecord.Normal
+	wo := MulVector3(-1.0, NormalizeVector3(ray.Direction))
+	wi := NormalizeVector3(direction)
+	dndx := AddVector3(MulVector3(hitRecord.DUVDX.X, hitRecord.DNDU), MulVector3(hitRecord.DUVDX.Y, hitRecord.DNDV))
+	dndy := AddVector3(MulVector3(hitRecord.DUVDY.X, hitRecord.DNDU), MulVector3(hitRecord.DUVDY.Y, hitRecord.DNDV))
+	dwodx := SubVector3(MulVector3(-1.0, NormalizeVector3(ray.RxDirection)), wo)
+	dwody := SubVector3(MulVector3(-1.0, NormalizeVector3(ray.RyDirection)), wo)
+
+	spawned.HasDifferentials = true
+	spawned.RxOrigin = AddVector3(hitRecord.Position, hitRecord.DPDX)
+	spawned.RyOrigin = AddVector3(hitRecord.Position, hitRecord.DPDY)
+	cosO := DotVector3(wo, n)
+	if 0.0 < cosO*DotVector3(wi, n) {
+		// Reflection
+		dDNdx := DotVector3(dwodx, n) + DotVector3(wo, dndx)
+		dDNdy := DotVector3(dwody, n) + DotVector3(wo, dndy)
+		spawned.RxDirection = AddVector3(SubVector3(wi, dwodx), MulVector3(2.0, AddVector3(MulVector3(cosO, dndx), MulVector3(dDNdx, n))))
+		spawned.RyDirection = AddVector3(SubVector3(wi, dwody), MulVector3(2.0, AddVector3(MulVector3(cosO, dndy), MulVector3(dDNdy, n))))
+		return spawned
+	}
+
+	// Refraction, eta is the index of the incident side over the transmitted side
+	if ior <= 0.0 {
+		spawned.HasDifferentials = false
+		return spawned
+	}
+	eta := 1.0 / ior
+	if cosO < 0.0 {
+		eta = ior
+		n = n.Minus()
+		dndx = dndx.Minus()
+		dndy = dndy.Minus()
+		cosO = -cosO
+	}
+	cosI := math32.Abs(DotVector3(wi, n))
+	if cosI <= Epsilon32 {
+		spawned.HasDifferentials = false
+		return spawned
+	}
+	mu := eta*cosO - cosI
+	dDNdx := DotVector3(dwodx, n) + DotVector3(wo, dndx)
+	dDNdy := DotVector3(dwody, n) + DotVector3(wo, dndy)
+	dmudx := (eta - eta*eta*cosO/cosI) * dDNdx
+	dmudy := (eta - eta*eta*cosO/cosI) * dDNdy
+	spawned.RxDirection = AddVector3(SubVector3(wi, MulVector3(eta, dwodx)), AddVector3(MulVector3(mu, dndx), MulVector3(dmudx, n)))
+	spawned.RyDirection = AddVector3(SubVector3(wi, MulVector3(eta, dwody)), AddVector3(MulVector3(mu, dndy), MulVector3(dmudy, n)))
+	return spawned
+}
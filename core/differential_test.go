@@ -0,0 +1,85 @@
+package core
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCameraDifferentials(t *testing.T) {
+	assert := assert.New(t)
+	camera := NewCameraPerspectiveFov(100, 100, DegToRad32*45.0)
+	ray := camera.GenerateRay(10, 20, Sample2{0.5, 0.5}, Sample2{0.5, 0.5}, 0.0)
+	assert.True(ray.HasDifferentials)
+	rx := camera.GenerateRay(11, 20, Sample2{0.5, 0.5}, Sample2{0.5, 0.5}, 0.0)
+	ry := camera.GenerateRay(10, 21, Sample2{0.5, 0.5}, Sample2{0.5, 0.5}, 0.0)
+	assert.Truef(EqualVector3(rx.Direction, ray.RxDirection), "%v should be %v", ray.RxDirection, rx.Direction)
+	assert.Truef(EqualVector3(ry.Direction, ray.RyDirection), "%v should be %v", ray.RyDirection, ry.Direction)
+	assert.Less(float32(0.0), ray.Spread())
+}
+
+func TestComputeDifferentials(t *testing.T) {
+	assert := assert.New(t)
+	sphere := Sphere{Vector3{0.0, 0.0, 0.0}, 1.0, nil}
+	direction := NormalizeVector3(Vector3{0.1, 0.2, -1.0})
+	rxDirection := NormalizeVector3(Vector3{0.101, 0.2, -1.0})
+	ryDirection := NormalizeVector3(Vector3{0.1, 0.201, -1.0})
+	origin := Vector3{0.0, 0.0, 3.0}
+	ray := RayDifferential{Ray{origin, direction, 0.0}, true, origin, origin, rxDirection, ryDirection}
+
+	var hitRecord, hitRecordX, hitRecordY HitRecord
+	assert.True(sphere.Hit(ray.Ray, 0.0, 10.0, &hitRecord))
+	assert.True(sphere.Hit(Ray{origin, rxDirection, 0.0}, 0.0, 10.0, &hitRecordX))
+	assert.True(sphere.Hit(Ray{origin, ryDirection, 0.0}, 0.0, 10.0, &hitRecordY))
+	hitRecord.ComputeDifferentials(&ray)
+	duvdx := SubVector2(hitRecordX.UV, hitRecord.UV)
+	duvdy := SubVector2(hitRecordY.UV, hitRecord.UV)
+	assert.InDeltaf(duvdx.X, hitRecord.DUVDX.X, 1.0e-4, "dudx")
+	assert.InDeltaf(duvdx.Y, hitRecord.DUVDX.Y, 1.0e-4, "dvdx")
+	assert.InDeltaf(duvdy.X, hitRecord.DUVDY.X, 1.0e-4, "dudy")
+	assert.InDeltaf(duvdy.Y, hitRecord.DUVDY.Y, 1.0e-4, "dvdy")
+}
+
+func newPlaneHitRecord() HitRecord {
+	normal := Vector3{0.0, 0.0, 1.0}
+	return HitRecord{Normal: normal, GeometricNormal: normal, DPDU: Vector3{1.0, 0.0, 0.0}, DPDV: Vector3{0.0, 1.0, 0.0}}
+}
+
+func TestSpawnRayDifferentials(t *testing.T) {
+	assert := assert.New(t)
+	origin := Vector3{0.0, 0.0, 1.0}
+	direction := NormalizeVector3(Vector3{0.3, 0.0, -1.0})
+	rxDirection := NormalizeVector3(Vector3{0.31, 0.0, -1.0})
+	ray := RayDifferential{Ray{origin, direction, 0.0}, true, origin, origin, rxDirection, direction}
+	hitRecord := newPlaneHitRecord()
+	hitRecord.Position = ray.PointAt(1.0/-direction.Z)
+	hitRecord.ComputeDifferentials(&ray)
+	assert.False(hitRecord.SpawnRay(&ray, direction, false, 1.0).HasDifferentials)
+
+	// Flat mirror reflects the offset ray as it is
+	reflected := hitRecord.SpawnRay(&ray, Reflect(direction, hitRecord.Normal), true, 1.0)
+	assert.True(reflected.HasDifferentials)
+	expected := Reflect(rxDirection, hitRecord.Normal)
+	assert.InDeltaf(expected.X, reflected.RxDirection.X, 1.0e-4, "%v should be %v", reflected.RxDirection, expected)
+	assert.InDeltaf(expected.Z, reflected.RxDirection.Z, 1.0e-4, "%v should be %v", reflected.RxDirection, expected)
+
+	// Flat interface refracts the offset ray to the first order
+	var refracted, refractedX Vector3
+	assert.True(Refract(&refracted, direction, hitRecord.Normal, 1.0/1.5))
+	assert.True(Refract(&refractedX, rxDirection, hitRecord.Normal, 1.0/1.5))
+	spawned := hitRecord.SpawnRay(&ray, refracted, true, 1.5)
+	assert.True(spawned.HasDifferentials)
+	actual := NormalizeVector3(spawned.RxDirection)
+	refractedX = NormalizeVector3(refractedX)
+	assert.InDeltaf(refractedX.X, actual.X, 1.0e-3, "%v should be %v", actual, refractedX)
+	assert.InDeltaf(refractedX.Z, actual.Z, 1.0e-3, "%v should be %v", actual, refractedX)
+
+	// At normal incidence the index comes from the material rather than the directions
+	direction = Vector3{0.0, 0.0, -1.0}
+	ray = RayDifferential{Ray{origin, direction, 0.0}, true, origin, origin, rxDirection, direction}
+	hitRecord.Position = ray.PointAt(1.0)
+	hitRecord.ComputeDifferentials(&ray)
+	assert.True(Refract(&refractedX, rxDirection, hitRecord.Normal, 1.0/1.5))
+	spawned = hitRecord.SpawnRay(&ray, direction, true, 1.5)
+	actual = NormalizeVector3(spawned.RxDirection)
+	refractedX = NormalizeVector3(refractedX)
+	assert.InDeltaf(refractedX.X, actual.X, 1.0e-3, "%v should be %v", actual, refractedX)
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// Dispersion index of refraction depending on a wavelength in nanometers
+type Dispersion interface {
+	IOR(lambda float32) float32
+}
+
+// Cauchy n = A + B/λ^2, λ in micrometers
+type Cauchy struct {
+	A float32
+	B float32
+}
+
+func (cauchy *Cauchy) IOR(lambda float32) float32 {
+	micrometer := lambda * 1.0e-3
+	return cauchy.A + cauchy.B/(micrometer*micrometer)
+}
+
+// Sellmeier n^2 = 1 + Σ B_i λ^2 / (λ^2 - C_i), λ in micrometers
+type Sellmeier struct {
+	B [3]float32
+	C [3]float32
+}
+
+func (sellmeier *Sellmeier) IOR(lambda float32) float32 {
+	micrometer := lambda * 1.0e-3
+	l2 := micrometer * micrometer
+	n2 := float32(1.0)
+	for i := 0; i < 3; i++ {
+		n2 += sellmeier.B[i] * l2 / (l2 - sellmeier.C[i])
+	}
+	return math32.Sqrt(math32.Max(1.0, n2))
+}
+
+// NewSellmeierBK7 Schott N-BK7 borosilicate crown glass
+func NewSellmeierBK7() *Sellmeier {
+	return &Sellmeier{
+		[3]float32{1.03961212, 0.231792344, 1.01046945},
+		[3]float32{0.00600069867, 0.0200179144, 103.560653}}
+}
+
+// NewSellmeierDiamond diamond, which has strong dispersion
+func NewSellmeierDiamond() *Sellmeier {
+	return &Sellmeier{
+		[3]float32{0.3306, 4.3356, 0.0},
+		[3]float32{0.030625, 0.011236, 0.0}}
+}
+
+// DispersiveMaterial is implemented by materials whose scattering directions depend on wavelengths
+//
+// AtWavelength returns the material at a wavelength in nanometers, false if the material does not disperse.
+type DispersiveMaterial interface {
+	AtWavelength(lambda float32) (Material, bool)
+}
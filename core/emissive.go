@@ -0,0 +1,122 @@
+package core
+
+// Emitter is implemented by materials which emit light
+//
+// Emitted returns the radiance towards wi in the local shading frame.
+type Emitter interface {
+	Emitted(wi Vector3) Vector3
+}
+
+// Emissive surface which emits Color multiplied by Intensity and absorbs all incident light
+//
+// ColorTexture multiplies Color, the back side emits only if TwoSided.
+type Emissive struct {
+	Color        Vector3
+	Intensity    float32
+	ColorTexture Texture
+	TwoSided     bool
+}
+
+func NewEmissive(color Vector3, intensity float32) Emissive {
+	return Emissive{color, intensity, nil, false}
+}
+
+// NewBlackbodyEmissive emissive of a color temperature in Kelvin, Intensity is the luminance
+func NewBlackbodyEmissive(kelvin, intensity float32) Emissive {
+	return Emissive{Blackbody(kelvin), intensity, nil, false}
+}
+
+func (emissive *Emissive) Resolve(hitRecord *HitRecord) Material {
+	if emissive.ColorTexture == nil {
+		return emissive
+	}
+	return &Emissive{ModulateColor(emissive.Color, emissive.ColorTexture, hitRecord), emissive.Intensity, nil, emissive.TwoSided}
+}
+
+func (emissive *Emissive) Emitted(wi Vector3) Vector3 {
+	if wi.Z <= 0.0 && !emissive.TwoSided {
+		return Vector3{}
+	}
+	return MulVector3(emissive.Intensity, emissive.Color)
+}
+
+func (emissive *Emissive) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+}
+
+func (emissive *Emissive) Eval(wi, wo Vector3) Vector3 {
+	return Vector3{}
+}
+
+func (emissive *Emissive) Pdf(wi, wo Vector3) float32 {
+	return 0.0
+}
+
+func (emissive *Emissive) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return false
+}
+
+func (emissive *Emissive) GetRoughness() float32 {
+	return 1.0
+}
+
+func (emissive *Emissive) GetMetallic() float32 {
+	return 0.0
+}
+
+func (emissive *Emissive) GetAlbedo() Vector3 {
+	return Vector3{}
+}
+
+// Emitting adds the emission of Emission to a material which keeps scattering the incident light
+//
+// Resolve returns an Emitting of the resolved material, which Unwrap returns after the emission is gathered.
+type Emitting struct {
+	Material Material
+	Emission Emissive
+}
+
+func NewEmitting(material Material, emission Emissive) Emitting {
+	return Emitting{material, emission}
+}
+
+func (emitting *Emitting) Unwrap() Material {
+	return emitting.Material
+}
+
+func (emitting *Emitting) Resolve(hitRecord *HitRecord) Material {
+	emission := emitting.Emission.Resolve(hitRecord).(*Emissive)
+	return &Emitting{emitting.Material.Resolve(hitRecord), *emission}
+}
+
+func (emitting *Emitting) Emitted(wi Vector3) Vector3 {
+	return emitting.Emission.Emitted(wi)
+}
+
+func (emitting *Emitting) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	return emitting.Material.Sample(wi, eta0, eta1)
+}
+
+func (emitting *Emitting) Eval(wi, wo Vector3) Vector3 {
+	return emitting.Material.Eval(wi, wo)
+}
+
+func (emitting *Emitting) Pdf(wi, wo Vector3) float32 {
+	return emitting.Material.Pdf(wi, wo)
+}
+
+func (emitting *Emitting) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return emitting.Material.Scatter(ray, hitRecord, attenuation, scattered)
+}
+
+func (emitting *Emitting) GetRoughness() float32 {
+	return emitting.Material.GetRoughness()
+}
+
+func (emitting *Emitting) GetMetallic() float32 {
+	return emitting.Material.GetMetallic()
+}
+
+func (emitting *Emitting) GetAlbedo() Vector3 {
+	return emitting.Material.GetAlbedo()
+}
@@ -14,6 +14,7 @@ type SphereMap struct {
 	Width int32
 	Height int32
 	Image []Vector3
+	MipMap *MipMap
 }
 
 func (env *SphereMap) Load(path string) {
@@ -93,6 +94,32 @@ func (env *SphereMap) Sample(n Vector3) Vector3 {
 	return env.Pixel(x, y)
 }
 
+// SampleFiltered samples over the cone of the spread angle around n
+func (env *SphereMap) SampleFiltered(n Vector3, spread float32) Vector3 {
+	r := (1/math32.Pi) * math32.Acos(n.Z)/math32.Sqrt(n.X*n.X + n.Y*n.Y)
+	u := (n.X*r + 1)*0.5
+	v := (1-n.Y*r)*0.5
+	x := u*float32(env.Width)
+	y := v*float32(env.Height)
+	// The radius 0.5 of the image covers the angle pi
+	width := spread/(2.0*math32.Pi) * float32(maxInt32(env.Width, env.Height))
+	return env.PixelFiltered(x, y, width)
+}
+
+// GenerateMipMap builds the pyramid for PixelFiltered
+func (env *SphereMap) GenerateMipMap() {
+	env.MipMap = NewMipMap(env.Width, env.Height, env.Image, WrapClamp, WrapClamp)
+}
+
+// PixelFiltered filters trilinearly over the footprint width in pixels, it is the same as Pixel without the pyramid
+func (env *SphereMap) PixelFiltered(x, y, width float32) Vector3 {
+	if nil == env.MipMap || width <= 1.0 {
+		return env.Pixel(x, y)
+	}
+	size := float32(maxInt32(env.Width, env.Height))
+	return env.MipMap.Trilinear(x/float32(env.Width), y/float32(env.Height), width/size)
+}
+
 func (env *SphereMap) Normal(x, y float32) Vector3 {
 	u := x*2.0 - 1.0
 	v := 1.0 - y*2.0
@@ -166,7 +193,7 @@ func (env *SphereMap) GenIrradiance(width, height int32) SphereMap {
 			image[i*width + j] = MulVector3(math32.Pi/float32(samples), total)
 		}
 	}
-	return SphereMap{width, height, image}
+	return SphereMap{width, height, image, nil}
 }
 
 func ImportanceSampleGGX(x, y, roughness float32, n Vector3) Vector3 {
@@ -213,7 +240,7 @@ func (env *SphereMap) GetSpecularOne(width, height int32, roughness float32) Sph
 			image[i*width + j] = MulVector3(1.0/totalWeight, total)
 		}
 	}
-	return SphereMap{width, height, image}
+	return SphereMap{width, height, image, nil}
 }
 
 func (env *SphereMap) GenSpecular(width, height, miplevels int32) []SphereMap {
@@ -271,6 +298,6 @@ func (env *SphereMap) GenBRDF(width, height int32) SphereMap {
 			image[i*width + j] = MulVector3(1.0/float32(samples), total)
 		}
 	}
-	return SphereMap{width, height, image}
+	return SphereMap{width, height, image, nil}
 }
 
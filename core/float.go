@@ -66,3 +66,21 @@ func Schlick(cosine, refIndex float32) float32 {
 	r0 = r0 * r0
 	return r0 + (1.0-r0)*math32.Pow((1.0-cosine), 5.0)
 }
+
+// FresnelDielectric exact Fresnel reflectance of a dielectric interface for unpolarized light
+//
+// eta is the relative index of refraction, the transmitted side over the incident side.
+func FresnelDielectric(cosine, eta float32) float32 {
+	if cosine < 0.0 {
+		eta = 1.0 / eta
+		cosine = -cosine
+	}
+	sin2T := (1.0 - cosine*cosine) / (eta * eta)
+	if 1.0 <= sin2T {
+		return 1.0
+	}
+	cosT := math32.Sqrt(1.0 - sin2T)
+	parallel := (eta*cosine - cosT) / (eta*cosine + cosT)
+	perpendicular := (cosine - eta*cosT) / (cosine + eta*cosT)
+	return 0.5 * (parallel*parallel + perpendicular*perpendicular)
+}
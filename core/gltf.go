@@ -0,0 +1,916 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git.maze.io/go/math32"
+	"image"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// GLTFCamera perspective camera of a glTF scene, Transform places it in the world looking down -Z with +Y up
+type GLTFCamera struct {
+	Name        string
+	Transform   Transform
+	YFov        float32
+	AspectRatio float32
+	ZNear       float32
+	ZFar        float32
+}
+
+// Camera returns the camera for an image resolution, the aspect ratio is given by the resolution
+func (camera *GLTFCamera) Camera(width, height uint32) Camera {
+	result := NewCameraPerspectiveFov(width, height, camera.YFov)
+	eye := camera.Transform.Point(Vector3{})
+	forward := camera.Transform.Vector(Vector3{0.0, 0.0, -1.0})
+	up := camera.Transform.Vector(Vector3{0.0, 1.0, 0.0})
+	result.LookAt(eye, AddVector3(eye, forward), up)
+	return result
+}
+
+// GLTFScene scene graph, cameras and lights imported from glTF
+type GLTFScene struct {
+	Root    *SceneNode
+	Cameras []GLTFCamera
+	Lights  []Light
+}
+
+type gltfTextureInfo struct {
+	Index    int      `json:"index"`
+	TexCoord int      `json:"texCoord"`
+	Scale    *float32 `json:"scale"`
+}
+
+type gltfMaterial struct {
+	Name                 string `json:"name"`
+	PbrMetallicRoughness *struct {
+		BaseColorFactor          []float32        `json:"baseColorFactor"`
+		MetallicFactor           *float32         `json:"metallicFactor"`
+		RoughnessFactor          *float32         `json:"roughnessFactor"`
+		BaseColorTexture         *gltfTextureInfo `json:"baseColorTexture"`
+		MetallicRoughnessTexture *gltfTextureInfo `json:"metallicRoughnessTexture"`
+	} `json:"pbrMetallicRoughness"`
+	NormalTexture   *gltfTextureInfo `json:"normalTexture"`
+	EmissiveTexture *gltfTextureInfo `json:"emissiveTexture"`
+	EmissiveFactor  []float32        `json:"emissiveFactor"`
+	AlphaMode       string           `json:"alphaMode"`
+	AlphaCutoff     *float32         `json:"alphaCutoff"`
+	Extensions      struct {
+		EmissiveStrength *struct {
+			EmissiveStrength float32 `json:"emissiveStrength"`
+		} `json:"KHR_materials_emissive_strength"`
+	} `json:"extensions"`
+}
+
+type gltfNode struct {
+	Name        string    `json:"name"`
+	Children    []int     `json:"children"`
+	Mesh        *int      `json:"mesh"`
+	Camera      *int      `json:"camera"`
+	Matrix      []float32 `json:"matrix"`
+	Translation []float32 `json:"translation"`
+	Rotation    []float32 `json:"rotation"`
+	Scale       []float32 `json:"scale"`
+	Extensions  struct {
+		LightsPunctual *struct {
+			Light int `json:"light"`
+		} `json:"KHR_lights_punctual"`
+	} `json:"extensions"`
+}
+
+type gltfLight struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Color     []float32 `json:"color"`
+	Intensity *float32  `json:"intensity"`
+	Range     float32   `json:"range"`
+	Spot      *struct {
+		InnerConeAngle float32  `json:"innerConeAngle"`
+		OuterConeAngle *float32 `json:"outerConeAngle"`
+	} `json:"spot"`
+}
+
+type gltfDocument struct {
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Name  string `json:"name"`
+		Nodes []int  `json:"nodes"`
+	} `json:"scenes"`
+	Nodes  []gltfNode `json:"nodes"`
+	Meshes []struct {
+		Name       string `json:"name"`
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+			Indices    *int           `json:"indices"`
+			Material   *int           `json:"material"`
+			Mode       *int           `json:"mode"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Materials []gltfMaterial `json:"materials"`
+	Textures  []struct {
+		Sampler *int `json:"sampler"`
+		Source  *int `json:"source"`
+	} `json:"textures"`
+	Images []struct {
+		URI        string `json:"uri"`
+		MimeType   string `json:"mimeType"`
+		BufferView *int   `json:"bufferView"`
+	} `json:"images"`
+	Samplers []struct {
+		WrapS *int `json:"wrapS"`
+		WrapT *int `json:"wrapT"`
+	} `json:"samplers"`
+	Accessors []struct {
+		BufferView    *int            `json:"bufferView"`
+		ByteOffset    int             `json:"byteOffset"`
+		ComponentType int             `json:"componentType"`
+		Normalized    bool            `json:"normalized"`
+		Count         int             `json:"count"`
+		Type          string          `json:"type"`
+		Sparse        json.RawMessage `json:"sparse"`
+	} `json:"accessors"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+		ByteStride int `json:"byteStride"`
+	} `json:"bufferViews"`
+	Buffers []struct {
+		URI        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+	Cameras []struct {
+		Name        string `json:"name"`
+		Type        string `json:"type"`
+		Perspective *struct {
+			AspectRatio float32 `json:"aspectRatio"`
+			YFov        float32 `json:"yfov"`
+			ZNear       float32 `json:"znear"`
+			ZFar        float32 `json:"zfar"`
+		} `json:"perspective"`
+	} `json:"cameras"`
+	Extensions struct {
+		LightsPunctual *struct {
+			Lights []gltfLight `json:"lights"`
+		} `json:"KHR_lights_punctual"`
+	} `json:"extensions"`
+}
+
+const (
+	gltfMagic     uint32 = 0x46546C67
+	gltfChunkJSON uint32 = 0x4E4F534A
+	gltfChunkBIN  uint32 = 0x004E4942
+
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+
+	gltfTriangles     = 4
+	gltfTriangleStrip = 5
+	gltfTriangleFan   = 6
+)
+
+// gltfLoader converts a parsed document, the converted meshes, materials and textures are cached to be shared
+type gltfLoader struct {
+	document  gltfDocument
+	dir       string
+	binary    []byte
+	buffers   [][]byte
+	meshes    map[int][]Hittable
+	materials map[int]Material
+	textures  map[[2]int]*ImageTexture
+	visiting  map[int]bool
+	scene     *GLTFScene
+	cameras   map[*SceneNode]int
+	lights    map[*SceneNode]int
+}
+
+// LoadGLTF loads a glTF 2.0 file, either JSON .gltf with external or embedded buffers or binary .glb
+func LoadGLTF(path string) (*GLTFScene, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadGLTF(data, filepath.Dir(path))
+}
+
+// ReadGLTF parses glTF or GLB data, dir is the directory to resolve the relative URIs of buffers and images
+//
+// Meshes become Mesh objects of triangles with the first texture coordinates, points and lines are skipped.
+// The metallic roughness materials become Principled materials, wrapped by NormalMapped and AlphaMasked as needed,
+// and emissive materials keep them under Emitting. Cameras are perspective only. The lights of KHR_lights_punctual
+// are placed in the world by their nodes, their photometric intensities are used as radiometric.
+func ReadGLTF(data []byte, dir string) (*GLTFScene, error) {
+	loader := &gltfLoader{
+		dir:       dir,
+		meshes:    map[int][]Hittable{},
+		materials: map[int]Material{},
+		textures:  map[[2]int]*ImageTexture{},
+		visiting:  map[int]bool{},
+		cameras:   map[*SceneNode]int{},
+		lights:    map[*SceneNode]int{},
+	}
+	document := data
+	if 12 <= len(data) && gltfMagic == binary.LittleEndian.Uint32(data) {
+		var err error
+		document, loader.binary, err = readGLB(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(document, &loader.document); err != nil {
+		return nil, err
+	}
+	return loader.load()
+}
+
+// readGLB splits the binary container into the JSON and the BIN chunks
+func readGLB(data []byte) ([]byte, []byte, error) {
+	if 2 != binary.LittleEndian.Uint32(data[4:]) {
+		return nil, nil, errors.New("unsupported glb version")
+	}
+	length := int(binary.LittleEndian.Uint32(data[8:]))
+	if len(data) < length {
+		return nil, nil, errors.New("truncated glb")
+	}
+	var document, bin []byte
+	for offset := 12; offset+8 <= length; {
+		chunkLength := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		start := offset + 8
+		if length < start+chunkLength {
+			return nil, nil, errors.New("truncated glb chunk")
+		}
+		switch chunkType {
+		case gltfChunkJSON:
+			document = data[start : start+chunkLength]
+		case gltfChunkBIN:
+			bin = data[start : start+chunkLength]
+		}
+		offset = start + chunkLength
+	}
+	if nil == document {
+		return nil, nil, errors.New("glb without json chunk")
+	}
+	return document, bin, nil
+}
+
+func (loader *gltfLoader) load() (*GLTFScene, error) {
+	document := &loader.document
+	loader.buffers = make([][]byte, len(document.Buffers))
+	for i, buffer := range document.Buffers {
+		data, err := loader.readURI(buffer.URI)
+		if err != nil {
+			return nil, err
+		}
+		if 0 == len(buffer.URI) {
+			if nil == loader.binary {
+				return nil, fmt.Errorf("buffer %d has no data", i)
+			}
+			data = loader.binary
+		}
+		if len(data) < buffer.ByteLength {
+			return nil, fmt.Errorf("buffer %d is shorter than its byte length", i)
+		}
+		loader.buffers[i] = data
+	}
+
+	name := "scene"
+	var roots []int
+	if 0 < len(document.Scenes) {
+		index := 0
+		if nil != document.Scene {
+			index = *document.Scene
+		}
+		if index < 0 || len(document.Scenes) <= index {
+			return nil, fmt.Errorf("scene %d out of range", index)
+		}
+		if 0 < len(document.Scenes[index].Name) {
+			name = document.Scenes[index].Name
+		}
+		roots = document.Scenes[index].Nodes
+	} else {
+		// Without scenes, the nodes which are not children are the roots
+		child := make([]bool, len(document.Nodes))
+		for _, node := range document.Nodes {
+			for _, c := range node.Children {
+				if 0 <= c && c < len(child) {
+					child[c] = true
+				}
+			}
+		}
+		for i := range document.Nodes {
+			if !child[i] {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	loader.scene = &GLTFScene{Root: NewSceneNode(name, nil)}
+	for _, index := range roots {
+		node, err := loader.node(index)
+		if err != nil {
+			return nil, err
+		}
+		loader.scene.Root.AddChild(node)
+	}
+	if err := loader.placeCamerasAndLights(loader.scene.Root); err != nil {
+		return nil, err
+	}
+	return loader.scene, nil
+}
+
+// readURI reads a data URI or a file relative to the directory, nil for an empty URI
+func (loader *gltfLoader) readURI(uri string) ([]byte, error) {
+	if 0 == len(uri) {
+		return nil, nil
+	}
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.IndexByte(uri, ',')
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, errors.New("unsupported data uri")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+	path, err := url.PathUnescape(uri)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filepath.Join(loader.dir, filepath.FromSlash(path)))
+}
+
+func (loader *gltfLoader) bufferView(index int) ([]byte, int, error) {
+	if index < 0 || len(loader.document.BufferViews) <= index {
+		return nil, 0, fmt.Errorf("buffer view %d out of range", index)
+	}
+	view := &loader.document.BufferViews[index]
+	if view.Buffer < 0 || len(loader.buffers) <= view.Buffer {
+		return nil, 0, fmt.Errorf("buffer %d out of range", view.Buffer)
+	}
+	buffer := loader.buffers[view.Buffer]
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteStride < 0 {
+		return nil, 0, fmt.Errorf("buffer view %d has a negative range", index)
+	}
+	if len(buffer) < view.ByteOffset || len(buffer)-view.ByteOffset < view.ByteLength {
+		return nil, 0, fmt.Errorf("buffer view %d out of its buffer", index)
+	}
+	return buffer[view.ByteOffset : view.ByteOffset+view.ByteLength], view.ByteStride, nil
+}
+
+func gltfComponents(accessorType string) int {
+	switch accessorType {
+	case "SCALAR":
+		return 1
+	case "VEC2":
+		return 2
+	case "VEC3":
+		return 3
+	case "VEC4", "MAT2":
+		return 4
+	case "MAT3":
+		return 9
+	case "MAT4":
+		return 16
+	}
+	return 0
+}
+
+func gltfComponentSize(componentType int) int {
+	switch componentType {
+	case gltfByte, gltfUnsignedByte:
+		return 1
+	case gltfShort, gltfUnsignedShort:
+		return 2
+	case gltfUnsignedInt, gltfFloat:
+		return 4
+	}
+	return 0
+}
+
+// gltfMaxZeroElements bounds the elements of an accessor without a buffer view, which are allocated as zeros
+const gltfMaxZeroElements = 1 << 24
+
+// accessor reads the elements of an accessor as float64, normalized integers are mapped to [0 1] or [-1 1]
+func (loader *gltfLoader) accessor(index int) ([]float64, int, error) {
+	if index < 0 || len(loader.document.Accessors) <= index {
+		return nil, 0, fmt.Errorf("accessor %d out of range", index)
+	}
+	accessor := &loader.document.Accessors[index]
+	if 0 < len(accessor.Sparse) {
+		return nil, 0, fmt.Errorf("sparse accessor %d is not supported", index)
+	}
+	components := gltfComponents(accessor.Type)
+	size := gltfComponentSize(accessor.ComponentType)
+	if 0 == components || 0 == size {
+		return nil, 0, fmt.Errorf("accessor %d has an invalid type", index)
+	}
+	if accessor.Count < 0 || accessor.ByteOffset < 0 {
+		return nil, 0, fmt.Errorf("accessor %d has a negative range", index)
+	}
+	if nil == accessor.BufferView {
+		// All zeros without a buffer view
+		if gltfMaxZeroElements < accessor.Count {
+			return nil, 0, fmt.Errorf("accessor %d has too many elements", index)
+		}
+		return make([]float64, accessor.Count*components), components, nil
+	}
+	data, stride, err := loader.bufferView(*accessor.BufferView)
+	if err != nil {
+		return nil, 0, err
+	}
+	if 0 == stride {
+		stride = components * size
+	}
+	// The last element ends in the view, checked by division so that a huge count does not overflow
+	if 0 < accessor.Count {
+		last := len(data) - accessor.ByteOffset - components*size
+		if last < 0 || last/stride < accessor.Count-1 {
+			return nil, 0, fmt.Errorf("accessor %d out of its buffer view", index)
+		}
+	}
+	values := make([]float64, accessor.Count*components)
+	for i := 0; i < accessor.Count; i++ {
+		element := data[accessor.ByteOffset+i*stride:]
+		for j := 0; j < components; j++ {
+			c := element[j*size:]
+			var value float64
+			switch accessor.ComponentType {
+			case gltfByte:
+				value = float64(int8(c[0]))
+				if accessor.Normalized {
+					value = math.Max(value/127.0, -1.0)
+				}
+			case gltfUnsignedByte:
+				value = float64(c[0])
+				if accessor.Normalized {
+					value /= 255.0
+				}
+			case gltfShort:
+				value = float64(int16(binary.LittleEndian.Uint16(c)))
+				if accessor.Normalized {
+					value = math.Max(value/32767.0, -1.0)
+				}
+			case gltfUnsignedShort:
+				value = float64(binary.LittleEndian.Uint16(c))
+				if accessor.Normalized {
+					value /= 65535.0
+				}
+			case gltfUnsignedInt:
+				value = float64(binary.LittleEndian.Uint32(c))
+			case gltfFloat:
+				value = float64(math.Float32frombits(binary.LittleEndian.Uint32(c)))
+			}
+			values[i*components+j] = value
+		}
+	}
+	return values, components, nil
+}
+
+func (loader *gltfLoader) vector3s(index int) ([]Vector3, error) {
+	values, components, err := loader.accessor(index)
+	if err != nil {
+		return nil, err
+	}
+	if components < 3 {
+		return nil, fmt.Errorf("accessor %d is not a 3D vector", index)
+	}
+	result := make([]Vector3, len(values)/components)
+	for i := range result {
+		v := values[i*components:]
+		result[i] = Vector3{float32(v[0]), float32(v[1]), float32(v[2])}
+	}
+	return result, nil
+}
+
+// uvs reads texture coordinates, flipped to the bottom left origin of UV
+func (loader *gltfLoader) uvs(index int) ([]Vector2, error) {
+	values, components, err := loader.accessor(index)
+	if err != nil {
+		return nil, err
+	}
+	if components < 2 {
+		return nil, fmt.Errorf("accessor %d is not a 2D vector", index)
+	}
+	result := make([]Vector2, len(values)/components)
+	for i := range result {
+		v := values[i*components:]
+		result[i] = Vector2{float32(v[0]), 1.0 - float32(v[1])}
+	}
+	return result, nil
+}
+
+// triangleIndices converts the indices of strips and fans into triangles
+func triangleIndices(indices []int32, mode int) []int32 {
+	switch mode {
+	case gltfTriangleStrip:
+		triangles := []int32{}
+		for i := 2; i < len(indices); i++ {
+			if 0 == i%2 {
+				triangles = append(triangles, indices[i-2], indices[i-1], indices[i])
+			} else {
+				triangles = append(triangles, indices[i-1], indices[i-2], indices[i])
+			}
+		}
+		return triangles
+	case gltfTriangleFan:
+		triangles := []int32{}
+		for i := 2; i < len(indices); i++ {
+			triangles = append(triangles, indices[0], indices[i-1], indices[i])
+		}
+		return triangles
+	}
+	return indices[:len(indices)-len(indices)%3]
+}
+
+func (loader *gltfLoader) mesh(index int) ([]Hittable, error) {
+	if hittables, ok := loader.meshes[index]; ok {
+		return hittables, nil
+	}
+	if index < 0 || len(loader.document.Meshes) <= index {
+		return nil, fmt.Errorf("mesh %d out of range", index)
+	}
+	hittables := []Hittable{}
+	for _, primitive := range loader.document.Meshes[index].Primitives {
+		mode := gltfTriangles
+		if nil != primitive.Mode {
+			mode = *primitive.Mode
+		}
+		if gltfTriangles != mode && gltfTriangleStrip != mode && gltfTriangleFan != mode {
+			continue
+		}
+		position, ok := primitive.Attributes["POSITION"]
+		if !ok {
+			continue
+		}
+		positions, err := loader.vector3s(position)
+		if err != nil {
+			return nil, err
+		}
+		var normals []Vector3
+		if normal, ok := primitive.Attributes["NORMAL"]; ok {
+			if normals, err = loader.vector3s(normal); err != nil {
+				return nil, err
+			}
+		}
+		var uvs []Vector2
+		if texcoord, ok := primitive.Attributes["TEXCOORD_0"]; ok {
+			if uvs, err = loader.uvs(texcoord); err != nil {
+				return nil, err
+			}
+		}
+		var indices []int32
+		if nil != primitive.Indices {
+			values, _, err := loader.accessor(*primitive.Indices)
+			if err != nil {
+				return nil, err
+			}
+			indices = make([]int32, len(values))
+			for i, value := range values {
+				indices[i] = int32(value)
+			}
+		} else {
+			indices = make([]int32, len(positions))
+			for i := range indices {
+				indices[i] = int32(i)
+			}
+		}
+		material, err := loader.material(primitive.Material)
+		if err != nil {
+			return nil, err
+		}
+		mesh, err := NewMesh(positions, normals, uvs, triangleIndices(indices, mode), material)
+		if err != nil {
+			return nil, fmt.Errorf("mesh %d: %v", index, err)
+		}
+		hittables = append(hittables, mesh)
+	}
+	loader.meshes[index] = hittables
+	return hittables, nil
+}
+
+func gltfWrap(mode *int) WrapMode {
+	if nil == mode {
+		return WrapRepeat
+	}
+	switch *mode {
+	case 33071:
+		return WrapClamp
+	case 33648:
+		return WrapMirror
+	}
+	return WrapRepeat
+}
+
+// texture loads the image of a texture, colors are converted from sRGB if srgb is true
+func (loader *gltfLoader) texture(index int, srgb bool) (*ImageTexture, error) {
+	key := [2]int{index, 0}
+	if srgb {
+		key[1] = 1
+	}
+	if texture, ok := loader.textures[key]; ok {
+		return texture, nil
+	}
+	document := &loader.document
+	if index < 0 || len(document.Textures) <= index || nil == document.Textures[index].Source {
+		return nil, fmt.Errorf("texture %d has no image", index)
+	}
+	source := *document.Textures[index].Source
+	if source < 0 || len(document.Images) <= source {
+		return nil, fmt.Errorf("image %d out of range", source)
+	}
+	var data []byte
+	var err error
+	if nil != document.Images[source].BufferView {
+		data, _, err = loader.bufferView(*document.Images[source].BufferView)
+	} else {
+		data, err = loader.readURI(document.Images[source].URI)
+	}
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image %d: %v", source, err)
+	}
+	texture := NewImageTextureFromImage(img, srgb)
+	if sampler := document.Textures[index].Sampler; nil != sampler && 0 <= *sampler && *sampler < len(document.Samplers) {
+		texture.WrapU = gltfWrap(document.Samplers[*sampler].WrapS)
+		texture.WrapV = gltfWrap(document.Samplers[*sampler].WrapT)
+	}
+	texture.GenerateMipMap()
+	loader.textures[key] = texture
+	return texture, nil
+}
+
+func gltfColor(values []float32, fallback Vector3) Vector3 {
+	if len(values) < 3 {
+		return fallback
+	}
+	return Vector3{values[0], values[1], values[2]}
+}
+
+// material converts a material, nil is the default material of glTF
+func (loader *gltfLoader) material(index *int) (Material, error) {
+	key := -1
+	if nil != index {
+		key = *index
+	}
+	if material, ok := loader.materials[key]; ok {
+		return material, nil
+	}
+	source := gltfMaterial{}
+	if 0 <= key {
+		if len(loader.document.Materials) <= key {
+			return nil, fmt.Errorf("material %d out of range", key)
+		}
+		source = loader.document.Materials[key]
+	}
+
+	baseColor := Vector3{1.0, 1.0, 1.0}
+	opacity := float32(1.0)
+	metallic := float32(1.0)
+	roughness := float32(1.0)
+	var baseColorTexture *ImageTexture
+	principled := NewPrincipled(baseColor, metallic, roughness)
+	if pbr := source.PbrMetallicRoughness; nil != pbr {
+		principled.BaseColor = gltfColor(pbr.BaseColorFactor, baseColor)
+		if 4 <= len(pbr.BaseColorFactor) {
+			opacity = pbr.BaseColorFactor[3]
+		}
+		if nil != pbr.MetallicFactor {
+			principled.Metallic = *pbr.MetallicFactor
+		}
+		if nil != pbr.RoughnessFactor {
+			principled.Roughness = *pbr.RoughnessFactor
+		}
+		if nil != pbr.BaseColorTexture {
+			texture, err := loader.texture(pbr.BaseColorTexture.Index, true)
+			if err != nil {
+				return nil, err
+			}
+			baseColorTexture = texture
+			principled.BaseColorTexture = texture
+		}
+		if nil != pbr.MetallicRoughnessTexture {
+			texture, err := loader.texture(pbr.MetallicRoughnessTexture.Index, false)
+			if err != nil {
+				return nil, err
+			}
+			principled.RoughnessTexture = &ChannelTexture{texture, 1}
+			principled.MetallicTexture = &ChannelTexture{texture, 2}
+		}
+	}
+	var material Material = &principled
+
+	emissive := gltfColor(source.EmissiveFactor, Vector3{})
+	if !emissive.IsZero() {
+		strength := float32(1.0)
+		if nil != source.Extensions.EmissiveStrength {
+			strength = source.Extensions.EmissiveStrength.EmissiveStrength
+		}
+		light := NewEmissive(emissive, strength)
+		if nil != source.EmissiveTexture {
+			texture, err := loader.texture(source.EmissiveTexture.Index, true)
+			if err != nil {
+				return nil, err
+			}
+			light.ColorTexture = texture
+		}
+		emitting := NewEmitting(material, light)
+		material = &emitting
+	}
+
+	if nil != source.NormalTexture {
+		texture, err := loader.texture(source.NormalTexture.Index, false)
+		if err != nil {
+			return nil, err
+		}
+		mapped := NewNormalMapped(material, texture)
+		if nil != source.NormalTexture.Scale {
+			mapped.Strength = *source.NormalTexture.Scale
+		}
+		material = &mapped
+	}
+
+	if "MASK" == source.AlphaMode || "BLEND" == source.AlphaMode {
+		var opacityTexture Texture
+		if nil != baseColorTexture && nil != baseColorTexture.Alpha {
+			opacityTexture = &AlphaChannelTexture{baseColorTexture}
+		}
+		masked := NewAlphaMasked(material, opacityTexture)
+		masked.Opacity = opacity
+		if "BLEND" == source.AlphaMode {
+			masked.Mode = AlphaStochastic
+		} else if nil != source.AlphaCutoff {
+			masked.Threshold = *source.AlphaCutoff
+		}
+		material = &masked
+	}
+	loader.materials[key] = material
+	return material, nil
+}
+
+// gltfCollapsed reports whether a node is scaled to zero, which hides it and its descendants
+func gltfCollapsed(node *gltfNode) bool {
+	return 3 == len(node.Scale) && 0.0 == node.Scale[0]*node.Scale[1]*node.Scale[2]
+}
+
+// transform returns the local transform of a node by its matrix or its translation, rotation and scale
+func (loader *gltfLoader) transform(node *gltfNode) (Transform, error) {
+	if 16 == len(node.Matrix) {
+		// Column major
+		var m Matrix4
+		for row := 0; row < 4; row++ {
+			for column := 0; column < 4; column++ {
+				m[row][column] = node.Matrix[column*4+row]
+			}
+		}
+		transform, valid := NewTransform(m)
+		if !valid {
+			return transform, errors.New("singular node matrix")
+		}
+		return transform, nil
+	}
+	keyframe := Keyframe{0.0, Vector3{}, IdentityQuaternion(), Vector3{1.0, 1.0, 1.0}}
+	if 3 == len(node.Translation) {
+		keyframe.Translation = Vector3{node.Translation[0], node.Translation[1], node.Translation[2]}
+	}
+	if 4 == len(node.Rotation) {
+		keyframe.Rotation = NormalizeQuaternion(Quaternion{node.Rotation[0], node.Rotation[1], node.Rotation[2], node.Rotation[3]})
+	}
+	if 3 == len(node.Scale) {
+		keyframe.Scale = Vector3{node.Scale[0], node.Scale[1], node.Scale[2]}
+	}
+	return keyframe.Transform(), nil
+}
+
+func (loader *gltfLoader) node(index int) (*SceneNode, error) {
+	document := &loader.document
+	if index < 0 || len(document.Nodes) <= index {
+		return nil, fmt.Errorf("node %d out of range", index)
+	}
+	if loader.visiting[index] {
+		return nil, fmt.Errorf("node %d is its own ancestor", index)
+	}
+	loader.visiting[index] = true
+	defer delete(loader.visiting, index)
+
+	source := &document.Nodes[index]
+	name := source.Name
+	if 0 == len(name) {
+		name = fmt.Sprintf("node%d", index)
+	}
+	node := NewSceneNode(name, nil)
+	if gltfCollapsed(source) {
+		node.Visibility = 0
+	} else {
+		transform, err := loader.transform(source)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %v", index, err)
+		}
+		node.Transform = transform
+	}
+
+	if nil != source.Mesh {
+		hittables, err := loader.mesh(*source.Mesh)
+		if err != nil {
+			return nil, err
+		}
+		if 1 == len(hittables) {
+			node.Object = hittables[0]
+		} else {
+			for i, hittable := range hittables {
+				node.AddChild(NewSceneNode(fmt.Sprintf("primitive%d", i), hittable))
+			}
+		}
+	}
+	if nil != source.Camera {
+		loader.cameras[node] = *source.Camera
+	}
+	if nil != source.Extensions.LightsPunctual {
+		loader.lights[node] = source.Extensions.LightsPunctual.Light
+	}
+	for _, child := range source.Children {
+		childNode, err := loader.node(child)
+		if err != nil {
+			return nil, err
+		}
+		node.AddChild(childNode)
+	}
+	return node, nil
+}
+
+// placeCamerasAndLights converts the cameras and the lights of the nodes in depth first order by their world transforms
+func (loader *gltfLoader) placeCamerasAndLights(node *SceneNode) error {
+	document := &loader.document
+	if index, ok := loader.cameras[node]; ok {
+		if index < 0 || len(document.Cameras) <= index {
+			return fmt.Errorf("camera %d out of range", index)
+		}
+		source := &document.Cameras[index]
+		if nil != source.Perspective {
+			loader.scene.Cameras = append(loader.scene.Cameras, GLTFCamera{
+				source.Name, node.WorldTransform(),
+				source.Perspective.YFov, source.Perspective.AspectRatio, source.Perspective.ZNear, source.Perspective.ZFar})
+		}
+	}
+	if index, ok := loader.lights[node]; ok {
+		if nil == document.Extensions.LightsPunctual || index < 0 || len(document.Extensions.LightsPunctual.Lights) <= index {
+			return fmt.Errorf("light %d out of range", index)
+		}
+		light := loader.light(&document.Extensions.LightsPunctual.Lights[index], node.WorldTransform())
+		if nil != light {
+			loader.scene.Lights = append(loader.scene.Lights, light)
+		}
+	}
+	for _, child := range node.Children {
+		if err := loader.placeCamerasAndLights(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// light converts a punctual light, which is at the origin of its node and points down -Z
+func (loader *gltfLoader) light(source *gltfLight, transform Transform) Light {
+	color := gltfColor(source.Color, Vector3{1.0, 1.0, 1.0})
+	intensity := float32(1.0)
+	if nil != source.Intensity {
+		intensity = *source.Intensity
+	}
+	position := transform.Point(Vector3{})
+	direction := transform.Vector(Vector3{0.0, 0.0, -1.0})
+	switch source.Type {
+	case "point":
+		light := NewPointLight(position, color, intensity)
+		light.Range = source.Range
+		return &light
+	case "spot":
+		inner := float32(0.0)
+		outer := float32(0.25 * math32.Pi)
+		if nil != source.Spot {
+			inner = source.Spot.InnerConeAngle
+			if nil != source.Spot.OuterConeAngle {
+				outer = *source.Spot.OuterConeAngle
+			}
+		}
+		light := NewSpotLight(position, direction, color, intensity, inner, outer)
+		light.Range = source.Range
+		return &light
+	case "directional":
+		light := NewDirectionalLight(direction, color, intensity)
+		return &light
+	}
+	return nil
+}
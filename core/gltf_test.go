@@ -0,0 +1,173 @@
+package core
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// testGLTFBuffer quad with positions, normals, uvs and unsigned short indices
+func testGLTFBuffer() []byte {
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.LittleEndian, []float32{-1.0, -1.0, 0.0, 1.0, -1.0, 0.0, 1.0, 1.0, 0.0, -1.0, 1.0, 0.0})
+	binary.Write(&buffer, binary.LittleEndian, []float32{0.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0, 0.0, 1.0, 0.0, 0.0, 1.0})
+	binary.Write(&buffer, binary.LittleEndian, []float32{0.0, 1.0, 1.0, 1.0, 1.0, 0.0, 0.0, 0.0})
+	binary.Write(&buffer, binary.LittleEndian, []uint16{0, 1, 2, 0, 2, 3})
+	return buffer.Bytes()
+}
+
+func testGLTFDocument(buffer string) string {
+	return `{
+	"asset": {"version": "2.0"},
+	"scene": 0,
+	"scenes": [{"name": "test", "nodes": [0, 4]}],
+	"nodes": [
+		{"name": "group", "translation": [0.0, 0.0, -5.0], "children": [1, 2, 3]},
+		{"name": "quad", "mesh": 0},
+		{"name": "eye", "camera": 0, "translation": [0.0, 0.0, 5.0]},
+		{"name": "lamp", "translation": [0.0, 3.0, 0.0], "extensions": {"KHR_lights_punctual": {"light": 0}}},
+		{"name": "hidden", "mesh": 0, "scale": [0.0, 1.0, 1.0]}
+	],
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 0, "NORMAL": 1, "TEXCOORD_0": 2}, "indices": 3, "material": 0}]}],
+	"materials": [{"name": "paint", "pbrMetallicRoughness": {"baseColorFactor": [0.5, 0.25, 1.0, 1.0], "metallicFactor": 0.0, "roughnessFactor": 0.3}}],
+	"cameras": [{"type": "perspective", "perspective": {"yfov": 0.8, "aspectRatio": 1.5, "znear": 0.1}}],
+	"extensions": {"KHR_lights_punctual": {"lights": [{"type": "spot", "color": [1.0, 0.5, 0.5], "intensity": 10.0}]}},
+	"accessors": [
+		{"bufferView": 0, "componentType": 5126, "count": 4, "type": "VEC3"},
+		{"bufferView": 0, "byteOffset": 48, "componentType": 5126, "count": 4, "type": "VEC3"},
+		{"bufferView": 0, "byteOffset": 96, "componentType": 5126, "count": 4, "type": "VEC2"},
+		{"bufferView": 1, "componentType": 5123, "count": 6, "type": "SCALAR"}
+	],
+	"bufferViews": [{"buffer": 0, "byteLength": 128}, {"buffer": 0, "byteOffset": 128, "byteLength": 12}],
+	"buffers": [{` + buffer + `"byteLength": 140}]
+}`
+}
+
+func checkGLTFScene(assert *assert.Assertions, scene *GLTFScene) {
+	assert.Equal("test", scene.Root.Name)
+	quad := scene.Root.Lookup("group/quad")
+	assert.NotNil(quad)
+	mesh, ok := quad.Object.(*Mesh)
+	assert.True(ok)
+	assert.Equal(int32(2), mesh.NumTriangles())
+
+	world := scene.Root.Flatten()
+	record := HitRecord{}
+	assert.True(world.Hit(Ray{Vector3{0.5, 0.5, 0.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &record))
+	assert.InDelta(5.0, record.T, 1.0e-5)
+	// The V of glTF goes down
+	assert.InDelta(0.75, record.UV.X, 1.0e-5)
+	assert.InDelta(0.75, record.UV.Y, 1.0e-5)
+	principled, ok := record.Material.(*Principled)
+	assert.True(ok)
+	assert.InDelta(0.25, principled.BaseColor.Y, 1.0e-6)
+	assert.InDelta(0.0, principled.Metallic, 1.0e-6)
+	assert.InDelta(0.3, principled.Roughness, 1.0e-6)
+
+	assert.Equal(1, len(scene.Cameras))
+	camera := scene.Cameras[0].Camera(150, 100)
+	assert.InDelta(0.0, camera.Origin.Z, 1.0e-5)
+	assert.InDelta(-1.0, camera.Forward.Z, 1.0e-5)
+	assert.InDelta(1.0, camera.Up.Y, 1.0e-5)
+	assert.InDelta(1.5, scene.Cameras[0].AspectRatio, 1.0e-6)
+
+	assert.Equal(1, len(scene.Lights))
+	spot, ok := scene.Lights[0].(*SpotLight)
+	assert.True(ok)
+	assert.InDelta(3.0, spot.Position.Y, 1.0e-5)
+	assert.InDelta(-5.0, spot.Position.Z, 1.0e-5)
+	assert.InDelta(-1.0, spot.Direction.Z, 1.0e-5)
+	assert.InDelta(0.5, spot.Color.Y, 1.0e-6)
+}
+
+func TestReadGLTF(t *testing.T) {
+	assert := assert.New(t)
+	uri := fmt.Sprintf(`"uri": "data:application/octet-stream;base64,%s", `, base64.StdEncoding.EncodeToString(testGLTFBuffer()))
+	scene, err := ReadGLTF([]byte(testGLTFDocument(uri)), ".")
+	assert.Nil(err)
+	if nil == scene {
+		return
+	}
+	checkGLTFScene(assert, scene)
+
+	// The collapsed node is not visible
+	hidden := scene.Root.Lookup("hidden")
+	assert.NotNil(hidden)
+	assert.Equal(Visibility(0), hidden.Visibility)
+}
+
+func TestReadGLB(t *testing.T) {
+	assert := assert.New(t)
+	document := []byte(testGLTFDocument(""))
+	for 0 != len(document)%4 {
+		document = append(document, ' ')
+	}
+	bin := testGLTFBuffer()
+	for 0 != len(bin)%4 {
+		bin = append(bin, 0)
+	}
+	var glb bytes.Buffer
+	binary.Write(&glb, binary.LittleEndian, []uint32{gltfMagic, 2, uint32(12 + 8 + len(document) + 8 + len(bin))})
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(document)), gltfChunkJSON})
+	glb.Write(document)
+	binary.Write(&glb, binary.LittleEndian, []uint32{uint32(len(bin)), gltfChunkBIN})
+	glb.Write(bin)
+	scene, err := ReadGLTF(glb.Bytes(), ".")
+	assert.Nil(err)
+	if nil == scene {
+		return
+	}
+	checkGLTFScene(assert, scene)
+
+	_, err = ReadGLTF([]byte(`{"buffers": [{"byteLength": 4}]}`), ".")
+	assert.NotNil(err)
+}
+
+func TestGLTFEmissive(t *testing.T) {
+	assert := assert.New(t)
+	uri := fmt.Sprintf(`"uri": "data:application/octet-stream;base64,%s", `, base64.StdEncoding.EncodeToString(testGLTFBuffer()))
+	document := strings.Replace(testGLTFDocument(uri), `"roughnessFactor": 0.3}}`, `"roughnessFactor": 0.3}, "emissiveFactor": [1.0, 0.5, 0.0]}`, 1)
+	scene, err := ReadGLTF([]byte(document), ".")
+	assert.Nil(err)
+	if nil == scene {
+		return
+	}
+	record := HitRecord{}
+	assert.True(scene.Root.Flatten().Hit(Ray{Vector3{0.5, 0.5, 0.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &record))
+	// The emission is added to the base material, which still scatters
+	emitting, ok := record.Material.(*Emitting)
+	if !assert.True(ok) {
+		return
+	}
+	assert.InDelta(0.5, emitting.Emitted(Vector3{0.0, 0.0, 1.0}).Y, 1.0e-6)
+	principled, ok := emitting.Unwrap().(*Principled)
+	assert.True(ok)
+	assert.InDelta(0.25, principled.BaseColor.Y, 1.0e-6)
+	assert.InDelta(0.3, emitting.GetRoughness(), 1.0e-6)
+}
+
+func TestGLTFMalformedRanges(t *testing.T) {
+	assert := assert.New(t)
+	uri := fmt.Sprintf(`"uri": "data:application/octet-stream;base64,%s", `, base64.StdEncoding.EncodeToString(testGLTFBuffer()))
+	document := testGLTFDocument(uri)
+	for _, replacement := range [][2]string{
+		{`"count": 4, "type": "VEC3"`, `"count": -1, "type": "VEC3"`},
+		{`"count": 4, "type": "VEC3"`, `"count": 4000000000000000000, "type": "VEC3"`},
+		{`{"buffer": 0, "byteLength": 128}`, `{"buffer": 0, "byteOffset": 4, "byteLength": -4}`},
+		{`{"buffer": 0, "byteLength": 128}`, `{"buffer": 0, "byteLength": 128, "byteStride": -12}`},
+		{`"bufferView": 0, "componentType": 5126, "count": 4`, `"componentType": 5126, "count": 4000000000000000000`},
+	} {
+		malformed := strings.Replace(document, replacement[0], replacement[1], 1)
+		_, err := ReadGLTF([]byte(malformed), ".")
+		assert.NotNil(err, replacement[1])
+	}
+}
+
+func TestTriangleIndices(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]int32{0, 1, 2, 2, 1, 3}, triangleIndices([]int32{0, 1, 2, 3}, gltfTriangleStrip))
+	assert.Equal([]int32{0, 1, 2, 0, 2, 3}, triangleIndices([]int32{0, 1, 2, 3}, gltfTriangleFan))
+}
@@ -1,9 +1,29 @@
 package core
 
+// HitRecord
+//
+// Normal is the shading normal, which can be perturbed by materials, GeometricNormal is the normal of the surface.
+// DPDU, DPDV, DNDU and DNDV are the partial derivatives of the position and the normal by UV,
+// DPDX, DPDY, DUVDX and DUVDY are the screen space derivatives computed by ComputeDifferentials.
+// Color is the interpolated vertex color, white unless the surface has vertex colors.
+// alphaMaterial, if not nil, is the material of a scene node whose mask is tested instead of the one of the surface.
 type HitRecord struct {
 	T float32
 	Position Vector3
 	Normal Vector3
+	GeometricNormal Vector3
+	Tangent Vector3
+	UV Vector2
+	DPDU Vector3
+	DPDV Vector3
+	DNDU Vector3
+	DNDV Vector3
+	DPDX Vector3
+	DPDY Vector3
+	DUVDX Vector2
+	DUVDY Vector2
+	Color Vector3
 	Material Material
+	alphaMaterial Material
 }
 
@@ -1,6 +1,17 @@
 package core
 
+// Hittable
+//
+// BoundingBox bounds the surface, it is infinite for unbounded surfaces.
 type Hittable interface {
 	Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool
+	BoundingBox() AABB
 }
 
+// AreaSampler is implemented by bounded surfaces which can be sampled uniformly by area, so that they can act as area lights
+//
+// SampleArea returns a position and the geometric normal there, whose pdf by area is 1/Area.
+type AreaSampler interface {
+	Area() float32
+	SampleArea(u0, u1 float32) (Vector3, Vector3)
+}
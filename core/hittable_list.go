@@ -9,11 +9,11 @@ func (hittableList *HittableList) AddHittable(hittable Hittable) {
 }
 
 func (hittableList *HittableList) AddHittables(hittables []Hittable) {
-	hittableList.hittables = append(hittables, hittableList.hittables...)
+	hittableList.hittables = append(hittableList.hittables, hittables...)
 }
 
 func (hittableList *HittableList) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
-	tmp := HitRecord{}
+	tmp := HitRecord{alphaMaterial: record.alphaMaterial}
 	hitAnything := false
 	closestSoFar := tmax
 	for i:=0; i<len(hittableList.hittables); i++ {
@@ -27,6 +27,30 @@ func (hittableList *HittableList) Hit(ray Ray, tmin float32, tmax float32, recor
 	return hitAnything
 }
 
+// HitVisible ignores the visibility, all surfaces of a list are visible
+func (hittableList *HittableList) HitVisible(ray Ray, tmin float32, tmax float32, visibility Visibility, record *HitRecord) bool {
+	return hittableList.Hit(ray, tmin, tmax, record)
+}
+
+// Occluded returns true if any surface is hit between tmin and tmax, for shadow rays
+func (hittableList *HittableList) Occluded(ray Ray, tmin float32, tmax float32) bool {
+	tmp := HitRecord{}
+	for i:=0; i<len(hittableList.hittables); i++ {
+		if hittableList.hittables[i].Hit(ray, tmin, tmax, &tmp) {
+			return true
+		}
+	}
+	return false
+}
+
+func (hittableList *HittableList) BoundingBox() AABB {
+	box := NewEmptyAABB()
+	for i:=0; i<len(hittableList.hittables); i++ {
+		box = UnionAABB(box, hittableList.hittables[i].BoundingBox())
+	}
+	return box
+}
+
 func NewHittableList() HittableList {
 	return HittableList{}
 }
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// Instance places a shared object in the world by a transform from the object space
+//
+// Rays are transformed into the object space and hits are transformed back, normals by the inverse transpose.
+// Many instances can share one object.
+type Instance struct {
+	Object    Hittable
+	Transform Transform
+}
+
+func NewInstance(object Hittable, transform Transform) Instance {
+	return Instance{object, transform}
+}
+
+func normalizeOrZero(v Vector3) Vector3 {
+	length := v.Length()
+	if length <= 0.0 || math32.IsNaN(length) {
+		return Vector3{}
+	}
+	return DivVector3(v, length)
+}
+
+func (instance *Instance) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	inverse := instance.Transform.Invert()
+	if !instance.Object.Hit(inverse.Ray(ray), tmin, tmax, record) {
+		return false
+	}
+	transform := &instance.Transform
+	record.Position = ray.PointAt(record.T)
+	record.Normal = normalizeOrZero(transform.Normal(record.Normal))
+	record.GeometricNormal = normalizeOrZero(transform.Normal(record.GeometricNormal))
+	record.Tangent = normalizeOrZero(transform.Vector(record.Tangent))
+	record.DPDU = transform.Vector(record.DPDU)
+	record.DPDV = transform.Vector(record.DPDV)
+	record.DNDU = transform.Normal(record.DNDU)
+	record.DNDV = transform.Normal(record.DNDV)
+	return true
+}
+
+func (instance *Instance) BoundingBox() AABB {
+	return instance.Transform.AABB(instance.Object.BoundingBox())
+}
+
+// MotionBounds transforms the bounds of a moving object, the transformed boxes interpolate linearly as well
+func (instance *Instance) MotionBounds(time0, time1 float32) (AABB, AABB) {
+	box0, box1 := motionBoundsOf(instance.Object, time0, time1)
+	return instance.Transform.AABB(box0), instance.Transform.AABB(box1)
+}
@@ -0,0 +1,288 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"math/rand"
+)
+
+const (
+	layeredMaxDepth int = 10
+	layeredSamples  int = 1
+)
+
+// Layered rough dielectric coat over an arbitrary base material
+//
+// The light transport between the layers is evaluated stochastically by position-free random walks,
+// so that Eval and Pdf are unbiased Monte Carlo estimates and Sample conserves energy.
+// The density of a walk is not known in closed form, so the PDF of its samples is 0 like of delta directions,
+// and their Weight is the only unbiased estimate.
+// Yu Guo, Miloš Hašan, Shuang Zhao, "Position-Free Monte Carlo Simulation for Arbitrary Layered BSDFs", SIGGRAPH Asia 2018
+// Matt Pharr, Wenzel Jakob, Greg Humphreys, "Physically Based Rendering: From Theory to Implementation", 4th edition, 14.3 Layered BxDFs
+type Layered struct {
+	Base       Material
+	Roughness  float32
+	IOR        float32
+	Absorption Vector3
+	Thickness  float32
+}
+
+func NewLayered(base Material, roughness, ior float32) Layered {
+	return Layered{base, roughness, ior, Vector3{}, 1.0}
+}
+
+func (layered *Layered) Resolve(hitRecord *HitRecord) Material {
+	base := layered.Base.Resolve(hitRecord)
+	if base == layered.Base {
+		return layered
+	}
+	resolved := *layered
+	resolved.Base = base
+	return &resolved
+}
+
+func (layered *Layered) coat() ggx {
+	alpha := math32.Max(0.001, layered.Roughness)
+	return ggx{alpha, alpha}
+}
+
+// transmittance Beer-Lambert attenuation through the coat along w
+func (layered *Layered) transmittance(w Vector3) Vector3 {
+	cosine := math32.Abs(w.Z)
+	if cosine <= Epsilon32 {
+		return Vector3{}
+	}
+	d := layered.Thickness / cosine
+	return Vector3{
+		math32.Exp(-layered.Absorption.X * d),
+		math32.Exp(-layered.Absorption.Y * d),
+		math32.Exp(-layered.Absorption.Z * d)}
+}
+
+// PowerHeuristic multiple importance sampling weight of the power heuristic with beta 2
+func PowerHeuristic(f, g float32) float32 {
+	f2 := f * f
+	g2 := g * g
+	if f2+g2 <= 0.0 {
+		return 0.0
+	}
+	return f2 / (f2 + g2)
+}
+
+// russianRoulette returns false if the walk should be terminated, otherwise the survival probability
+func russianRoulette(depth int, beta Vector3) (float32, bool) {
+	m := MaxElementVector3(beta)
+	if depth <= 3 || 0.25 <= m {
+		return 1.0, true
+	}
+	q := math32.Max(0.0, 1.0-m)
+	if rand.Float32() < q {
+		return 0.0, false
+	}
+	return 1.0 - q, true
+}
+
+func (layered *Layered) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	if wi.Z <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	coat := layered.coat()
+	w, valid := coat.SampleDielectric(wi, layered.IOR, eta0, eta1, rand.Float32())
+	if !valid {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	pdf := coat.PdfDielectric(wi, w, layered.IOR)
+	if pdf <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	f := coat.EvalDielectric(wi, w, layered.IOR)
+	weight := Vector3{f / pdf, f / pdf, f / pdf}
+	if 0.0 < w.Z {
+		return MaterialSample{true, 0.0, weight, w}
+	}
+
+	// Random walk between the base and the coat
+	top := true
+	for depth := 0; depth < layeredMaxDepth; depth++ {
+		survival, alive := russianRoulette(depth, weight)
+		if !alive {
+			break
+		}
+		weight = DivVector3(weight, survival)
+		weight = HadamardDotVector3(weight, layered.transmittance(w))
+		top = !top
+		if top {
+			next, valid := coat.SampleDielectric(w.Minus(), layered.IOR, rand.Float32(), rand.Float32(), rand.Float32())
+			if !valid {
+				break
+			}
+			p := coat.PdfDielectric(w.Minus(), next, layered.IOR)
+			if p <= Epsilon32 {
+				break
+			}
+			weight = MulVector3(coat.EvalDielectric(w.Minus(), next, layered.IOR)/p, weight)
+			w = next
+			if 0.0 < w.Z {
+				return MaterialSample{true, 0.0, weight, w}
+			}
+		} else {
+			materialSample := layered.Base.Sample(w.Minus(), rand.Float32(), rand.Float32())
+			if !materialSample.Continue {
+				break
+			}
+			weight = HadamardDotVector3(weight, materialSample.Weight)
+			w = materialSample.Scattered
+			if w.Z < 0.0 {
+				// Transmitted through the base
+				return MaterialSample{true, 0.0, weight, w}
+			}
+		}
+	}
+	return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+}
+
+func (layered *Layered) Eval(wi, wo Vector3) Vector3 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return Vector3{}
+	}
+	coat := layered.coat()
+	eta := layered.IOR
+	fTop := coat.EvalDielectric(wi, wo, eta) / wo.Z
+	result := Vector3{fTop, fTop, fTop}
+
+	total := Vector3{}
+	for s := 0; s < layeredSamples; s++ {
+		// Enter from wi and exit towards wo, both through transmission
+		w, valid := coat.SampleDielectricLobe(wi, eta, rand.Float32(), rand.Float32(), false)
+		if !valid {
+			continue
+		}
+		pdf := coat.PdfDielectricLobe(wi, w, eta)
+		if pdf <= Epsilon32 {
+			continue
+		}
+		exit, valid := coat.SampleDielectricLobe(wo, eta, rand.Float32(), rand.Float32(), false)
+		if !valid {
+			continue
+		}
+		exitPdf := coat.PdfDielectricLobe(wo, exit, eta)
+		if exitPdf <= Epsilon32 {
+			continue
+		}
+		// The exit is traced from wo in the adjoint direction, which cancels the radiance scaling by eta^2
+		exitWeight := coat.EvalDielectric(wo, exit, eta) * eta * eta / (math32.Abs(exit.Z) * exitPdf)
+		exitTransmittance := MulVector3(exitWeight, layered.transmittance(exit))
+
+		beta := Vector3{1.0, 1.0, 1.0}
+		beta = MulVector3(coat.EvalDielectric(wi, w, eta)/pdf, beta)
+		top := true
+		for depth := 0; depth < layeredMaxDepth; depth++ {
+			survival, alive := russianRoulette(depth, beta)
+			if !alive {
+				break
+			}
+			beta = DivVector3(beta, survival)
+			beta = HadamardDotVector3(beta, layered.transmittance(w))
+			top = !top
+			if top {
+				// Only reflection at the exit, transmission is accounted at the base
+				next, valid := coat.SampleDielectricLobe(w.Minus(), eta, rand.Float32(), rand.Float32(), true)
+				if !valid {
+					break
+				}
+				p := coat.PdfDielectricLobe(w.Minus(), next, eta)
+				if p <= Epsilon32 {
+					break
+				}
+				beta = MulVector3(coat.EvalDielectric(w.Minus(), next, eta)/p, beta)
+				w = next
+				continue
+			}
+
+			// Connect to the exit direction
+			base := layered.Base.Eval(w.Minus(), exit.Minus())
+			if !base.IsZero() {
+				weight := PowerHeuristic(exitPdf, layered.Base.Pdf(w.Minus(), exit.Minus()))
+				contribution := HadamardDotVector3(HadamardDotVector3(beta, base), exitTransmittance)
+				total = AddVector3(total, MulVector3(weight, contribution))
+			}
+
+			view := w.Minus()
+			materialSample := layered.Base.Sample(view, rand.Float32(), rand.Float32())
+			if !materialSample.Continue || materialSample.Scattered.Z <= Epsilon32 {
+				break
+			}
+			beta = HadamardDotVector3(beta, materialSample.Weight)
+			w = materialSample.Scattered
+
+			// Exit along the sampled direction, a base without density is specular
+			fExit := coat.EvalDielectric(w.Minus(), wo, eta) / wo.Z
+			if 0.0 < fExit {
+				weight := float32(1.0)
+				basePdf := layered.Base.Pdf(view, w)
+				if 0.0 < basePdf {
+					weight = PowerHeuristic(basePdf, coat.PdfDielectricLobe(w.Minus(), wo, eta))
+				}
+				contribution := MulVector3(fExit*weight, HadamardDotVector3(beta, layered.transmittance(w)))
+				total = AddVector3(total, contribution)
+			}
+		}
+	}
+	result = AddVector3(result, DivVector3(total, float32(layeredSamples)))
+	return MulVector3(wo.Z, result)
+}
+
+func (layered *Layered) Pdf(wi, wo Vector3) float32 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return 0.0
+	}
+	coat := layered.coat()
+	eta := layered.IOR
+	// Probability to be transmitted into the coat
+	entering := 1.0 - FresnelDielectric(wi.Z, eta)
+	total := float32(0.0)
+	for s := 0; s < layeredSamples; s++ {
+		total += coat.PdfDielectric(wi, wo, eta)
+		// Transmission, reflection at the base and transmission
+		w, valid := coat.SampleDielectricLobe(wi, eta, rand.Float32(), rand.Float32(), false)
+		if !valid {
+			continue
+		}
+		exit, valid := coat.SampleDielectricLobe(wo, eta, rand.Float32(), rand.Float32(), false)
+		if !valid {
+			continue
+		}
+		exitPdf := coat.PdfDielectricLobe(wo, exit, eta)
+		materialSample := layered.Base.Sample(w.Minus(), rand.Float32(), rand.Float32())
+		if !materialSample.Continue {
+			continue
+		}
+		basePdf := layered.Base.Pdf(w.Minus(), exit.Minus())
+		if 0.0 < basePdf {
+			total += entering * PowerHeuristic(exitPdf, basePdf) * basePdf
+			coatPdf := coat.PdfDielectric(materialSample.Scattered.Minus(), wo, eta)
+			total += entering * PowerHeuristic(layered.Base.Pdf(w.Minus(), materialSample.Scattered), coatPdf) * coatPdf
+		} else {
+			total += entering * coat.PdfDielectric(materialSample.Scattered.Minus(), wo, eta)
+		}
+	}
+	// Mix with the uniform distribution to compensate the missing paths
+	return Lerp32(1.0/(4.0*math32.Pi), total/float32(layeredSamples), 0.9)
+}
+
+func (layered *Layered) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return scatterBySample(layered, ray, hitRecord, attenuation, scattered)
+}
+
+func (layered *Layered) GetRoughness() float32 {
+	return layered.Base.GetRoughness()
+}
+
+func (layered *Layered) GetMetallic() float32 {
+	return layered.Base.GetMetallic()
+}
+
+// GetAlbedo returns the albedo of the base seen through the coat at normal incidence
+func (layered *Layered) GetAlbedo() Vector3 {
+	vertical := layered.transmittance(Vector3{0.0, 0.0, 1.0})
+	return HadamardDotVector3(layered.Base.GetAlbedo(), HadamardDotVector3(vertical, vertical))
+}
@@ -0,0 +1,46 @@
+package core
+import (
+	"testing"
+	"math/rand"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayeredIndexMatched(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(6)
+	base := NewLambertian(Vector3{0.8, 0.4, 0.2})
+	// A coat without contrast of the index should not change the base
+	layered := NewLayered(&base, 0.2, 1.0001)
+	wi := NormalizeVector3(Vector3{0.3, -0.2, 0.8})
+	const samples int = 20000
+	total := Vector3{}
+	for i:=0; i<samples; i++ {
+		sample := layered.Sample(wi, rand.Float32(), rand.Float32())
+		if sample.Continue {
+			total = AddVector3(total, sample.Weight)
+		}
+	}
+	albedo := DivVector3(total, float32(samples))
+	assert.InDeltaf(0.8, albedo.X, 0.03, "Albedo %v should be close to the base", albedo)
+	assert.InDeltaf(0.4, albedo.Y, 0.03, "Albedo %v should be close to the base", albedo)
+}
+
+func TestLayeredEnergy(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(7)
+	base := NewLambertian(Vector3{1.0, 1.0, 1.0})
+	layered := Layered{&base, 0.3, 1.5, Vector3{0.2, 0.5, 1.0}, 0.5}
+	wi := NormalizeVector3(Vector3{0.1, 0.4, 0.5})
+	const samples int = 20000
+	total := Vector3{}
+	for i:=0; i<samples; i++ {
+		sample := layered.Sample(wi, rand.Float32(), rand.Float32())
+		if sample.Continue {
+			total = AddVector3(total, sample.Weight)
+			// The walk has no density in closed form
+			assert.Equal(float32(0.0), sample.PDF)
+		}
+	}
+	albedo := DivVector3(total, float32(samples))
+	assert.Truef(albedo.X <= 1.0 && albedo.Z < albedo.Y && albedo.Y < albedo.X, "Albedo %v should be absorbed by the coat", albedo)
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// LightSample direction and distance from a position toward a light, Radiance is the incident radiance divided by the pdf
+type LightSample struct {
+	Direction Vector3
+	Distance  float32
+	Radiance  Vector3
+}
+
+// Light is sampled explicitly by shadow rays, unlike emissive surfaces which are found by the paths
+type Light interface {
+	SampleLight(position Vector3, u0, u1 float32) LightSample
+}
+
+// rangeWindow fades a light smoothly to zero at its range, zero range is unlimited
+//
+// The window of KHR_lights_punctual, which follows the inverse square falloff.
+func rangeWindow(distance, lightRange float32) float32 {
+	if lightRange <= 0.0 {
+		return 1.0
+	}
+	ratio := distance / lightRange
+	window := Saturate32(1.0 - ratio*ratio*ratio*ratio)
+	return window * window
+}
+
+// PointLight emits Intensity times Color in all directions, Intensity is the radiant intensity
+type PointLight struct {
+	Position  Vector3
+	Color     Vector3
+	Intensity float32
+	Range     float32
+}
+
+func NewPointLight(position, color Vector3, intensity float32) PointLight {
+	return PointLight{position, color, intensity, 0.0}
+}
+
+func (light *PointLight) SampleLight(position Vector3, u0, u1 float32) LightSample {
+	return samplePunctual(light.Position, position, MulVector3(light.Intensity, light.Color), light.Range)
+}
+
+func samplePunctual(lightPosition, position, intensity Vector3, lightRange float32) LightSample {
+	d := SubVector3(lightPosition, position)
+	distanceSqr := d.LengthSqr()
+	if distanceSqr <= 0.0 {
+		return LightSample{Vector3{0.0, 1.0, 0.0}, 0.0, Vector3{}}
+	}
+	distance := math32.Sqrt(distanceSqr)
+	attenuation := rangeWindow(distance, lightRange) / distanceSqr
+	return LightSample{DivVector3(d, distance), distance, MulVector3(attenuation, intensity)}
+}
+
+// SpotLight point light restricted to a cone around Direction, which falls off smoothly from InnerConeAngle to OuterConeAngle
+type SpotLight struct {
+	Position       Vector3
+	Direction      Vector3
+	Color          Vector3
+	Intensity      float32
+	Range          float32
+	InnerConeAngle float32
+	OuterConeAngle float32
+}
+
+func NewSpotLight(position, direction, color Vector3, intensity, innerConeAngle, outerConeAngle float32) SpotLight {
+	return SpotLight{position, NormalizeVector3(direction), color, intensity, 0.0, innerConeAngle, outerConeAngle}
+}
+
+func (light *SpotLight) SampleLight(position Vector3, u0, u1 float32) LightSample {
+	sample := samplePunctual(light.Position, position, MulVector3(light.Intensity, light.Color), light.Range)
+	cosOuter := math32.Cos(light.OuterConeAngle)
+	cosInner := math32.Cos(light.InnerConeAngle)
+	cosine := -DotVector3(sample.Direction, light.Direction)
+	falloff := float32(1.0)
+	if cosine <= cosOuter {
+		falloff = 0.0
+	} else if cosine < cosInner && cosOuter < cosInner {
+		t := (cosine - cosOuter) / (cosInner - cosOuter)
+		falloff = t * t
+	}
+	sample.Radiance = MulVector3(falloff, sample.Radiance)
+	return sample
+}
+
+// DirectionalLight light from infinitely far away which travels along Direction, Intensity is the irradiance
+type DirectionalLight struct {
+	Direction Vector3
+	Color     Vector3
+	Intensity float32
+}
+
+func NewDirectionalLight(direction, color Vector3, intensity float32) DirectionalLight {
+	return DirectionalLight{NormalizeVector3(direction), color, intensity}
+}
+
+func (light *DirectionalLight) SampleLight(position Vector3, u0, u1 float32) LightSample {
+	return LightSample{MulVector3(-1.0, light.Direction), Infinity32, MulVector3(light.Intensity, light.Color)}
+}
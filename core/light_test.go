@@ -0,0 +1,32 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPunctualLights(t *testing.T) {
+	assert := assert.New(t)
+	point := NewPointLight(Vector3{0.0, 2.0, 0.0}, Vector3{1.0, 0.5, 0.25}, 8.0)
+	sample := point.SampleLight(Vector3{}, 0.5, 0.5)
+	assert.InDelta(1.0, sample.Direction.Y, 1.0e-6)
+	assert.InDelta(2.0, sample.Distance, 1.0e-6)
+	assert.InDelta(2.0, sample.Radiance.X, 1.0e-6)
+	assert.InDelta(0.5, sample.Radiance.Z, 1.0e-6)
+	point.Range = 2.0
+	sample = point.SampleLight(Vector3{}, 0.5, 0.5)
+	assert.InDelta(0.0, sample.Radiance.X, 1.0e-6)
+
+	spot := NewSpotLight(Vector3{0.0, 2.0, 0.0}, Vector3{0.0, -1.0, 0.0}, Vector3{1.0, 1.0, 1.0}, 4.0, 0.1, 0.5)
+	sample = spot.SampleLight(Vector3{}, 0.5, 0.5)
+	assert.InDelta(1.0, sample.Radiance.X, 1.0e-6)
+	sample = spot.SampleLight(Vector3{2.0, 2.0, 0.0}, 0.5, 0.5)
+	assert.InDelta(0.0, sample.Radiance.X, 1.0e-6)
+	sample = spot.SampleLight(Vector3{0.6, 0.0, 0.0}, 0.5, 0.5)
+	assert.True(0.0 < sample.Radiance.X && sample.Radiance.X < 4.0/(0.36+4.0))
+
+	directional := NewDirectionalLight(Vector3{0.0, -2.0, 0.0}, Vector3{1.0, 1.0, 1.0}, 3.0)
+	sample = directional.SampleLight(Vector3{5.0, 0.0, 0.0}, 0.5, 0.5)
+	assert.InDelta(1.0, sample.Direction.Y, 1.0e-6)
+	assert.Equal(Infinity32, sample.Distance)
+	assert.InDelta(3.0, sample.Radiance.X, 1.0e-6)
+}
@@ -12,8 +12,17 @@ type MaterialSample struct {
 	Scattered Vector3
 }
 
+// Material
+//
+// Sample, Eval and Pdf work in the local shading frame, where wi is the direction towards the viewer
+// and wo is the scattered direction. Eval returns the BSDF multiplied by the cosine of wo.
+// Resolve returns the material whose textured parameters are evaluated at a hit,
+// the other methods use only the constant parameters.
 type Material interface {
+	Resolve(hitRecord *HitRecord) Material
 	Sample(wi Vector3, eta0, eta1 float32) MaterialSample
+	Eval(wi, wo Vector3) Vector3
+	Pdf(wi, wo Vector3) float32
 	Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool
 	GetRoughness() float32
 	GetMetallic() float32
@@ -22,10 +31,18 @@ type Material interface {
 
 type Lambertian struct {
 	Albedo Vector3
+	AlbedoTexture Texture
 }
 
 func NewLambertian(albedo Vector3) Lambertian {
-	return Lambertian{albedo}
+	return Lambertian{albedo, nil}
+}
+
+func (lambertian *Lambertian) Resolve(hitRecord *HitRecord) Material {
+	if lambertian.AlbedoTexture == nil {
+		return lambertian
+	}
+	return &Lambertian{ModulateColor(lambertian.Albedo, lambertian.AlbedoTexture, hitRecord), nil}
 }
 
 func (lambertian *Lambertian) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
@@ -33,15 +50,29 @@ func (lambertian *Lambertian) Sample(wi Vector3, eta0, eta1 float32) MaterialSam
 		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
 	}
 	wm := RandomOnCosineHemiSphere(eta0, eta1)
-	pdf := wi.Z / math32.Pi //PDF of cosine hemisphere
+	pdf := wm.Z / math32.Pi //PDF of cosine hemisphere
 	return MaterialSample{true, pdf, lambertian.Albedo, wm}
 }
 
+func (lambertian *Lambertian) Eval(wi, wo Vector3) Vector3 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return Vector3{}
+	}
+	return MulVector3(wo.Z/math32.Pi, lambertian.Albedo)
+}
+
+func (lambertian *Lambertian) Pdf(wi, wo Vector3) float32 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return 0.0
+	}
+	return wo.Z / math32.Pi
+}
+
 func (material *Lambertian) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
 	coordinate := NewCoordinate(hitRecord.Normal)
 	n := RandomOnHemiSphere(rand.Float32(), rand.Float32())
-	*scattered = Ray{hitRecord.Position, coordinate.LocalToWorld(n)}
-	*attenuation = material.Albedo
+	*scattered = Ray{hitRecord.Position, coordinate.LocalToWorld(n), ray.Time}
+	*attenuation = ModulateColor(material.Albedo, material.AlbedoTexture, hitRecord)
 	return true
 }
 
@@ -91,9 +122,10 @@ func ggx_G2(wi, wo Vector3, alpha2 float32) float32 {
 	return 2.0 * dotNI * dotNO / (denomI + denomO)
 }
 
-func ggx_VNDF(wo Vector3, roughness, eta0, eta1 float32) Vector3 {
+// ggx_VNDF samples a visible normal of the anisotropic GGX, alphaX and alphaY are along the tangent and the bitangent
+func ggx_VNDF(wo Vector3, alphaX, alphaY, eta0, eta1 float32) Vector3 {
 	//1. Transform the view direction to the hemisphere configuration
-	v := NormalizeVector3(Vector3{roughness*wo.X, roughness*wo.Y, wo.Z})
+	v := NormalizeVector3(Vector3{alphaX*wo.X, alphaY*wo.Y, wo.Z})
 
     //2. Construct orthonormal bais
 	var t1 Vector3
@@ -134,32 +166,61 @@ func ggx_VNDF(wo Vector3, roughness, eta0, eta1 float32) Vector3 {
 	n := AddVector3(AddVector3(t1, t2), v)
 
     //5. Transform the normal back to the elipsoid configuration
-	return NormalizeVector3(Vector3{roughness*n.X, roughness*n.Y, math32.Max(0.0, n.Z)})
+	return NormalizeVector3(Vector3{alphaX*n.X, alphaY*n.Y, math32.Max(Epsilon32, n.Z)})
 }
 
 // https://hal.archives-ouvertes.fr/hal-01509746/document
+// RoughnessX and RoughnessY are GGX alphas along the tangent and the bitangent.
 type Metal struct {
 	Albedo Vector3
-	Roughness float32
+	RoughnessX float32
+	RoughnessY float32
 	Metallic float32
 	RefIndex float32
+	AlbedoTexture Texture
+	RoughnessTexture Texture
+	MetallicTexture Texture
+}
+
+func NewMetal(albedo Vector3, roughness, metallic, refIndex float32) Metal {
+	return Metal{albedo, roughness, roughness, metallic, refIndex, nil, nil, nil}
+}
+
+func (metal *Metal) Resolve(hitRecord *HitRecord) Material {
+	if metal.AlbedoTexture == nil && metal.RoughnessTexture == nil && metal.MetallicTexture == nil {
+		return metal
+	}
+	return &Metal{
+		Albedo: ModulateColor(metal.Albedo, metal.AlbedoTexture, hitRecord),
+		RoughnessX: ModulateScalar(metal.RoughnessX, metal.RoughnessTexture, hitRecord),
+		RoughnessY: ModulateScalar(metal.RoughnessY, metal.RoughnessTexture, hitRecord),
+		Metallic: ModulateScalar(metal.Metallic, metal.MetallicTexture, hitRecord),
+		RefIndex: metal.RefIndex}
+}
+
+func (metal *Metal) distribution() ggx {
+	return ggx{math32.Max(0.001, metal.RoughnessX), math32.Max(0.001, metal.RoughnessY)}
 }
 
 func (metal *Metal) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
-	wm := ggx_VNDF(wi, metal.Roughness, eta0, eta1)
+	if wi.Z <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	distribution := metal.distribution()
+	wm := ggx_VNDF(wi, distribution.alphaX, distribution.alphaY, eta0, eta1)
 	wo := SubVector3(MulVector3(2.0*DotVector3(wi, wm), wm), wi)
 	if 0.0 < wo.Z {
 		f := Schlick(DotVector3(wo, wm), metal.RefIndex)
-		g1 := ggx_G1(wi, metal.Roughness)
-		g2 := ggx_G2(wo, wi, metal.Roughness*metal.Roughness)
-		pdf := f * (g2/g1)
-		return MaterialSample{true, pdf, metal.Albedo, wm}
+		g1 := distribution.G1(wi)
+		g2 := distribution.G2(wi, wo)
+		pdf := metal.Pdf(wi, wo)
+		return MaterialSample{true, pdf, MulVector3(f * (g2/g1), metal.Albedo), wo}
 	}else{
-		return MaterialSample{false, 0.0, metal.Albedo, wm}
+		return MaterialSample{false, 0.0, Vector3{}, wo}
 	}
 
 	/*
-	alpha2 := metal.Roughness * metal.Roughness
+	alpha2 := metal.RoughnessX * metal.RoughnessX
 	theta := math32.Acos(math32.Sqrt((1.0-eta0)/((alpha2-1.0)*eta0 + 1.0)))
 	phi := 2.0 * math32.Pi * eta1
 	sinTheta := math32.Sin(theta)
@@ -180,15 +241,37 @@ func (metal *Metal) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
 	*/
 }
 
+func (metal *Metal) Eval(wi, wo Vector3) Vector3 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return Vector3{}
+	}
+	wm := NormalizeVector3(AddVector3(wi, wo))
+	distribution := metal.distribution()
+	f := Schlick(DotVector3(wo, wm), metal.RefIndex)
+	d := distribution.D(wm)
+	g2 := distribution.G2(wi, wo)
+	return MulVector3(f*d*g2/(4.0*wi.Z), metal.Albedo)
+}
+
+func (metal *Metal) Pdf(wi, wo Vector3) float32 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return 0.0
+	}
+	wm := NormalizeVector3(AddVector3(wi, wo))
+	distribution := metal.distribution()
+	return distribution.PdfReflection(wi, wm)
+}
+
 func (metal *Metal) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
 	reflected := NormalizeVector3(Reflect(ray.Direction, hitRecord.Normal))
-	*scattered = Ray{hitRecord.Position, reflected}
-	*attenuation = metal.Albedo
+	*scattered = Ray{hitRecord.Position, reflected, ray.Time}
+	*attenuation = ModulateColor(metal.Albedo, metal.AlbedoTexture, hitRecord)
 	return 0.0001 < DotVector3(scattered.Direction, hitRecord.Normal)
 }
 
+// GetRoughness returns the geometric mean of the anisotropic roughness
 func (material *Metal) GetRoughness() float32 {
-	return material.Roughness
+	return math32.Sqrt(material.RoughnessX * material.RoughnessY)
 }
 
 func (material *Metal) GetMetallic() float32 {
@@ -199,13 +282,84 @@ func (material *Metal) GetAlbedo() Vector3 {
 	return material.Albedo
 }
 
+// Dielectric
+//
+// FilmThickness in nanometers coats the surface by a thin film of FilmIOR, zero means no film.
+// RefIndex is the index of the inside over the outside, Priority and Absorption are used by DielectricStack for nested volumes.
+// Dispersion replaces RefIndex at the wavelength of a spectral path, nil means no dispersion.
 type Dielectric struct {
 	Albedo   Vector3
 	RefIndex float32
+	AlbedoTexture Texture
+	FilmThickness float32
+	FilmIOR float32
+	FilmThicknessTexture Texture
+	Priority int32
+	Absorption Vector3
+	Dispersion Dispersion
+}
+
+func (dielectric *Dielectric) Resolve(hitRecord *HitRecord) Material {
+	if dielectric.AlbedoTexture == nil && dielectric.FilmThicknessTexture == nil {
+		return dielectric
+	}
+	return &Dielectric{
+		Albedo: ModulateColor(dielectric.Albedo, dielectric.AlbedoTexture, hitRecord),
+		RefIndex: dielectric.RefIndex,
+		FilmThickness: ModulateScalar(dielectric.FilmThickness, dielectric.FilmThicknessTexture, hitRecord),
+		FilmIOR: dielectric.FilmIOR,
+		Priority: dielectric.Priority,
+		Absorption: dielectric.Absorption,
+		Dispersion: dielectric.Dispersion}
+}
+
+func (dielectric *Dielectric) AtWavelength(lambda float32) (Material, bool) {
+	if dielectric.Dispersion == nil {
+		return dielectric, false
+	}
+	dispersed := *dielectric
+	dispersed.RefIndex = dielectric.Dispersion.IOR(lambda)
+	return &dispersed, true
+}
+
+func (dielectric *Dielectric) GetPriority() int32 {
+	return dielectric.Priority
+}
+
+func (dielectric *Dielectric) GetIOR() float32 {
+	return dielectric.RefIndex
+}
+
+func (dielectric *Dielectric) GetAbsorption() Vector3 {
+	return dielectric.Absorption
+}
+
+func (dielectric *Dielectric) Relative(outside float32) Material {
+	relative := *dielectric
+	relative.RefIndex = dielectric.RefIndex / outside
+	return &relative
+}
+
+// filmReflectance reflectance of the thin film for the cosine on the outside
+func (dielectric *Dielectric) filmReflectance(cosine, thickness float32) Vector3 {
+	substrate := complex(float64(dielectric.RefIndex), 0.0)
+	return ThinFilmReflectance(cosine, thickness, dielectric.FilmIOR, func(lambda float32) complex128 {
+		return substrate
+	})
+}
+
+// selectFilm chooses reflection by the average reflectance of the film, then returns the weight of the choice
+func selectFilm(reflectance Vector3, u float32) (bool, Vector3) {
+	reflectance = SaturateVector3(reflectance)
+	p := Clamp0132((reflectance.X + reflectance.Y + reflectance.Z) / 3.0)
+	if u < p {
+		return true, DivVector3(reflectance, p)
+	}
+	return false, DivVector3(SubVector3(Vector3{1.0, 1.0, 1.0}, reflectance), 1.0-p)
 }
 
 func (dielectric *Dielectric) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
-	if wi.Z <= Epsilon32 {
+	if EqualZero32(wi.Z) {
 		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
 	}
 
@@ -229,17 +383,38 @@ func (dielectric *Dielectric) Sample(wi Vector3, eta0, eta1 float32) MaterialSam
 	if !Refract(&refracted, direction, n, niOverNt) {
 		return MaterialSample{true, 1.0, Vector3{1.0, 1.0, 1.0}, reflected}
 	}
+	if 0.0 < dielectric.FilmThickness {
+		// The film is on the outside, the reflectance of a lossless film is the same from both sides
+		cosOutside := wi.Z
+		if 0.0 < direction.Z {
+			cosOutside = math32.Sqrt(math32.Max(0.0, 1.0-niOverNt*niOverNt*(1.0-wi.Z*wi.Z)))
+		}
+		reflect, weight := selectFilm(dielectric.filmReflectance(cosOutside, dielectric.FilmThickness), rand.Float32())
+		if reflect {
+			return MaterialSample{true, 1.0, weight, reflected}
+		}
+		return MaterialSample{true, 1.0, HadamardDotVector3(weight, dielectric.Albedo), refracted}
+	}
 	reflectProb := Schlick(cosine, dielectric.RefIndex)
 	if rand.Float32() < reflectProb {
 		return MaterialSample{true, 1.0, Vector3{1.0, 1.0, 1.0}, reflected}
 	}else{
-		return MaterialSample{true, 1.0, Vector3{1.0, 1.0, 1.0}, refracted}
+		return MaterialSample{true, 1.0, dielectric.Albedo, refracted}
 	}
 }
 
+// Eval always returns zero because a smooth dielectric only scatters into delta directions.
+func (dielectric *Dielectric) Eval(wi, wo Vector3) Vector3 {
+	return Vector3{}
+}
+
+func (dielectric *Dielectric) Pdf(wi, wo Vector3) float32 {
+	return 0.0
+}
+
 func (dielectric *Dielectric) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
 	reflected := NormalizeVector3(Reflect(ray.Direction, hitRecord.Normal))
-	*attenuation = dielectric.Albedo
+	*attenuation = ModulateColor(dielectric.Albedo, dielectric.AlbedoTexture, hitRecord)
 	var niOverNt float32
 	var cosine float32
 	var normal Vector3
@@ -256,14 +431,30 @@ func (dielectric *Dielectric) Scatter(ray *Ray, hitRecord *HitRecord, attenuatio
 
 	var refracted Vector3
 	if !Refract(&refracted, ray.Direction, normal, niOverNt) {
-		*scattered = Ray{hitRecord.Position, reflected}
+		*scattered = Ray{hitRecord.Position, reflected, ray.Time}
+		return true
+	}
+	thickness := ModulateScalar(dielectric.FilmThickness, dielectric.FilmThicknessTexture, hitRecord)
+	if 0.0 < thickness {
+		// The film is on the outside, the reflectance of a lossless film is the same from both sides
+		cosAir := -DotVector3(NormalizeVector3(ray.Direction), normal)
+		if 0.0 < DotVector3(ray.Direction, hitRecord.Normal) {
+			cosAir = math32.Sqrt(math32.Max(0.0, 1.0-niOverNt*niOverNt*(1.0-cosAir*cosAir)))
+		}
+		reflect, weight := selectFilm(dielectric.filmReflectance(cosAir, thickness), rand.Float32())
+		*attenuation = HadamardDotVector3(*attenuation, weight)
+		if reflect {
+			*scattered = Ray{hitRecord.Position, reflected, ray.Time}
+		} else {
+			*scattered = Ray{hitRecord.Position, refracted, ray.Time}
+		}
 		return true
 	}
 	reflectProb := Schlick(cosine, dielectric.RefIndex)
 	if rand.Float32() < reflectProb {
-		*scattered = Ray{hitRecord.Position, reflected}
+		*scattered = Ray{hitRecord.Position, reflected, ray.Time}
 	}else{
-		*scattered = Ray{hitRecord.Position, refracted}
+		*scattered = Ray{hitRecord.Position, refracted, ray.Time}
 	}
 	return true
 }
@@ -280,3 +471,128 @@ func (material *Dielectric) GetAlbedo() Vector3 {
 	return material.Albedo
 }
 
+
+// scatterBySample implements Scatter in the world space on top of Sample
+func scatterBySample(material Material, ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	coordinate := NewShadingCoordinate(hitRecord)
+	material = material.Resolve(hitRecord)
+	wi := coordinate.WorldToLocal(ray.Direction.Minus())
+	materialSample := material.Sample(wi, rand.Float32(), rand.Float32())
+	if !materialSample.Continue {
+		return false
+	}
+	direction := coordinate.LocalToWorld(materialSample.Scattered)
+	if LeaksLight(hitRecord, direction, materialSample.Scattered) {
+		return false
+	}
+	*scattered = Ray{hitRecord.Position, direction, ray.Time}
+	*attenuation = materialSample.Weight
+	return true
+}
+
+// RoughDielectric dielectric with GGX microfacet reflection and transmission
+//
+// Bruce Walter, Stephen R. Marschner, Hongsong Li, Kenneth E. Torrance, "Microfacet Models for Refraction through Rough Surfaces", EGSR 2007
+// RefIndex is the index of the inside over the outside, it is inverted for rays exiting from the inside.
+// Albedo tints only transmission. Priority and Absorption are used by DielectricStack for nested volumes.
+type RoughDielectric struct {
+	Albedo           Vector3
+	Roughness        float32
+	RefIndex         float32
+	AlbedoTexture    Texture
+	RoughnessTexture Texture
+	Priority         int32
+	Absorption       Vector3
+}
+
+func (dielectric *RoughDielectric) Resolve(hitRecord *HitRecord) Material {
+	if dielectric.AlbedoTexture == nil && dielectric.RoughnessTexture == nil {
+		return dielectric
+	}
+	return &RoughDielectric{
+		Albedo:     ModulateColor(dielectric.Albedo, dielectric.AlbedoTexture, hitRecord),
+		Roughness:  ModulateScalar(dielectric.Roughness, dielectric.RoughnessTexture, hitRecord),
+		RefIndex:   dielectric.RefIndex,
+		Priority:   dielectric.Priority,
+		Absorption: dielectric.Absorption}
+}
+
+func (dielectric *RoughDielectric) GetPriority() int32 {
+	return dielectric.Priority
+}
+
+func (dielectric *RoughDielectric) GetIOR() float32 {
+	return dielectric.RefIndex
+}
+
+func (dielectric *RoughDielectric) GetAbsorption() Vector3 {
+	return dielectric.Absorption
+}
+
+func (dielectric *RoughDielectric) Relative(outside float32) Material {
+	relative := *dielectric
+	relative.RefIndex = dielectric.RefIndex / outside
+	return &relative
+}
+
+func (dielectric *RoughDielectric) distribution() ggx {
+	alpha := math32.Max(0.001, dielectric.Roughness)
+	return ggx{alpha, alpha}
+}
+
+func (dielectric *RoughDielectric) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	if EqualZero32(wi.Z) {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	upper := wi
+	if wi.Z < 0.0 {
+		upper = wi.Minus()
+	}
+	alpha := math32.Max(0.001, dielectric.Roughness)
+	wm := ggx_VNDF(upper, alpha, alpha, eta0, eta1)
+	var wo Vector3
+	valid := false
+	if rand.Float32() < FresnelDielectric(DotVector3(wi, wm), dielectric.RefIndex) {
+		wo = Reflect(wi.Minus(), wm)
+		valid = 0.0 < wi.Z*wo.Z
+	} else {
+		wo, valid = refractMicrofacet(wi, wm, dielectric.RefIndex)
+		valid = valid && wi.Z*wo.Z < 0.0
+	}
+	if !valid {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	pdf := dielectric.Pdf(wi, wo)
+	if pdf <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	return MaterialSample{true, pdf, DivVector3(dielectric.Eval(wi, wo), pdf), wo}
+}
+
+func (dielectric *RoughDielectric) Eval(wi, wo Vector3) Vector3 {
+	f := dielectric.distribution().EvalDielectric(wi, wo, dielectric.RefIndex)
+	if wi.Z*wo.Z < 0.0 {
+		return MulVector3(f, dielectric.Albedo)
+	}
+	return Vector3{f, f, f}
+}
+
+func (dielectric *RoughDielectric) Pdf(wi, wo Vector3) float32 {
+	return dielectric.distribution().PdfDielectric(wi, wo, dielectric.RefIndex)
+}
+
+func (dielectric *RoughDielectric) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return scatterBySample(dielectric, ray, hitRecord, attenuation, scattered)
+}
+
+func (material *RoughDielectric) GetRoughness() float32 {
+	return material.Roughness
+}
+
+func (material *RoughDielectric) GetMetallic() float32 {
+	return 0.0
+}
+
+func (material *RoughDielectric) GetAlbedo() Vector3 {
+	return material.Albedo
+}
@@ -0,0 +1,26 @@
+package core
+import (
+	"testing"
+	"math/rand"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoughDielectricSampleMatchesEval(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(5)
+	material := RoughDielectric{Albedo: Vector3{0.9, 0.5, 0.1}, Roughness: 0.3, RefIndex: 1.5}
+	directions := []Vector3{NormalizeVector3(Vector3{0.3, -0.2, 0.8}), NormalizeVector3(Vector3{-0.4, 0.1, -0.6})}
+	for _, wi := range directions {
+		for i:=0; i<1000; i++ {
+			sample := material.Sample(wi, rand.Float32(), rand.Float32())
+			if !sample.Continue {
+				continue
+			}
+			pdf := material.Pdf(wi, sample.Scattered)
+			assert.InDeltaf(1.0, sample.PDF/pdf, 1.0e-3, "PDF of sample %v should be %v", sample.PDF, pdf)
+			if sample.Scattered.Z*wi.Z < 0.0 {
+				assert.Truef(sample.Weight.Z < sample.Weight.X, "Transmission %v should be tinted", sample.Weight)
+			}
+		}
+	}
+}
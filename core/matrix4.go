@@ -0,0 +1,116 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// Matrix4 row major 4x4 matrix which transforms column vectors
+type Matrix4 [4][4]float32
+
+func IdentityMatrix4() Matrix4 {
+	return Matrix4{
+		{1.0, 0.0, 0.0, 0.0},
+		{0.0, 1.0, 0.0, 0.0},
+		{0.0, 0.0, 1.0, 0.0},
+		{0.0, 0.0, 0.0, 1.0}}
+}
+
+func TranslateMatrix4(t Vector3) Matrix4 {
+	return Matrix4{
+		{1.0, 0.0, 0.0, t.X},
+		{0.0, 1.0, 0.0, t.Y},
+		{0.0, 0.0, 1.0, t.Z},
+		{0.0, 0.0, 0.0, 1.0}}
+}
+
+func ScaleMatrix4(s Vector3) Matrix4 {
+	return Matrix4{
+		{s.X, 0.0, 0.0, 0.0},
+		{0.0, s.Y, 0.0, 0.0},
+		{0.0, 0.0, s.Z, 0.0},
+		{0.0, 0.0, 0.0, 1.0}}
+}
+
+// RotateMatrix4 rotation around an axis by an angle in radians, counterclockwise looking from the tip of the axis
+func RotateMatrix4(axis Vector3, angle float32) Matrix4 {
+	a := NormalizeVector3(axis)
+	sn := math32.Sin(angle)
+	cs := math32.Cos(angle)
+	c := 1.0 - cs
+	return Matrix4{
+		{a.X*a.X*c + cs, a.X*a.Y*c - a.Z*sn, a.X*a.Z*c + a.Y*sn, 0.0},
+		{a.Y*a.X*c + a.Z*sn, a.Y*a.Y*c + cs, a.Y*a.Z*c - a.X*sn, 0.0},
+		{a.Z*a.X*c - a.Y*sn, a.Z*a.Y*c + a.X*sn, a.Z*a.Z*c + cs, 0.0},
+		{0.0, 0.0, 0.0, 1.0}}
+}
+
+// MulMatrix4 returns m0 m1, which applies m1 first
+func MulMatrix4(m0, m1 Matrix4) Matrix4 {
+	var m Matrix4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			m[i][j] = m0[i][0]*m1[0][j] + m0[i][1]*m1[1][j] + m0[i][2]*m1[2][j] + m0[i][3]*m1[3][j]
+		}
+	}
+	return m
+}
+
+func TransposeMatrix4(m Matrix4) Matrix4 {
+	var t Matrix4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			t[i][j] = m[j][i]
+		}
+	}
+	return t
+}
+
+// InverseMatrix4 inverts by Gauss-Jordan elimination with partial pivoting, false if singular
+func InverseMatrix4(m Matrix4) (Matrix4, bool) {
+	inverse := IdentityMatrix4()
+	for column := 0; column < 4; column++ {
+		pivot := column
+		for row := column + 1; row < 4; row++ {
+			if math32.Abs(m[pivot][column]) < math32.Abs(m[row][column]) {
+				pivot = row
+			}
+		}
+		if math32.Abs(m[pivot][column]) <= Epsilon32*Epsilon32 {
+			return IdentityMatrix4(), false
+		}
+		m[column], m[pivot] = m[pivot], m[column]
+		inverse[column], inverse[pivot] = inverse[pivot], inverse[column]
+		scale := 1.0 / m[column][column]
+		for j := 0; j < 4; j++ {
+			m[column][j] *= scale
+			inverse[column][j] *= scale
+		}
+		for row := 0; row < 4; row++ {
+			if row == column {
+				continue
+			}
+			f := m[row][column]
+			for j := 0; j < 4; j++ {
+				m[row][j] -= f * m[column][j]
+				inverse[row][j] -= f * inverse[column][j]
+			}
+		}
+	}
+	return inverse, true
+}
+
+// TransformPoint transforms a position, the projective row is ignored for affine matrices
+func (m *Matrix4) TransformPoint(p Vector3) Vector3 {
+	return Vector3{
+		m[0][0]*p.X + m[0][1]*p.Y + m[0][2]*p.Z + m[0][3],
+		m[1][0]*p.X + m[1][1]*p.Y + m[1][2]*p.Z + m[1][3],
+		m[2][0]*p.X + m[2][1]*p.Y + m[2][2]*p.Z + m[2][3]}
+}
+
+// TransformVector transforms a direction, which is not affected by translation
+func (m *Matrix4) TransformVector(v Vector3) Vector3 {
+	return Vector3{
+		m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z}
+}
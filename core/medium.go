@@ -0,0 +1,421 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"git.maze.io/go/math32"
+	"io"
+	"math/rand"
+	"os"
+)
+
+// PhaseFunction distribution of the new propagation direction at a scattering event
+//
+// direction is the propagation direction before scattering, next is after.
+type PhaseFunction interface {
+	Eval(direction, next Vector3) float32
+	Sample(direction Vector3, eta0, eta1 float32) (Vector3, float32)
+}
+
+type IsotropicPhase struct {
+}
+
+func (phase *IsotropicPhase) Eval(direction, next Vector3) float32 {
+	return 1.0 / (4.0 * math32.Pi)
+}
+
+func (phase *IsotropicPhase) Sample(direction Vector3, eta0, eta1 float32) (Vector3, float32) {
+	return RandomOnSphere(eta0, eta1), 1.0 / (4.0 * math32.Pi)
+}
+
+type HenyeyGreensteinPhase struct {
+	G float32
+}
+
+func (phase *HenyeyGreensteinPhase) Eval(direction, next Vector3) float32 {
+	return HenyeyGreenstein(DotVector3(direction, next), phase.G)
+}
+
+func (phase *HenyeyGreensteinPhase) Sample(direction Vector3, eta0, eta1 float32) (Vector3, float32) {
+	next := SampleHenyeyGreenstein(direction, phase.G, eta0, eta1)
+	return next, HenyeyGreenstein(DotVector3(direction, next), phase.G)
+}
+
+// Medium participating medium
+//
+// The direction of a ray should be normalized, distances are measured by the parameter of the ray.
+// Sample samples a scattering event before tmax, it returns the distance, the throughput weight and true if scattered.
+// The weight is zero if the path is absorbed. Transmittance returns the transmittance between 0 and tmax.
+type Medium interface {
+	Sample(ray Ray, tmax float32) (float32, Vector3, bool)
+	Transmittance(ray Ray, tmax float32) Vector3
+	GetPhase() PhaseFunction
+}
+
+func averageVector3(x Vector3) float32 {
+	return (x.X + x.Y + x.Z) / 3.0
+}
+
+// HomogeneousMedium medium of constant absorption and scattering coefficients
+type HomogeneousMedium struct {
+	SigmaA Vector3
+	SigmaS Vector3
+	Phase  PhaseFunction
+}
+
+func NewHomogeneousMedium(sigmaA, sigmaS Vector3, g float32) HomogeneousMedium {
+	return HomogeneousMedium{sigmaA, sigmaS, &HenyeyGreensteinPhase{g}}
+}
+
+// Sample samples the distance in a channel selected uniformly, then weights by the average of the channels
+func (medium *HomogeneousMedium) Sample(ray Ray, tmax float32) (float32, Vector3, bool) {
+	sigmaT := AddVector3(medium.SigmaA, medium.SigmaS)
+	u := rand.Float32()
+	var channel float32
+	switch {
+	case u < 1.0/3.0:
+		channel = sigmaT.X
+	case u < 2.0/3.0:
+		channel = sigmaT.Y
+	default:
+		channel = sigmaT.Z
+	}
+	t := Infinity32
+	if Epsilon32 < channel {
+		t = -math32.Log(1.0-rand.Float32()) / channel
+	}
+	if tmax <= t {
+		transmittance := medium.Transmittance(ray, tmax)
+		pdf := averageVector3(transmittance)
+		if pdf <= 0.0 {
+			return tmax, Vector3{}, false
+		}
+		return tmax, DivVector3(transmittance, pdf), false
+	}
+	transmittance := medium.Transmittance(ray, t)
+	density := HadamardDotVector3(sigmaT, transmittance)
+	pdf := averageVector3(density)
+	return t, DivVector3(HadamardDotVector3(medium.SigmaS, transmittance), pdf), true
+}
+
+func (medium *HomogeneousMedium) Transmittance(ray Ray, tmax float32) Vector3 {
+	sigmaT := AddVector3(medium.SigmaA, medium.SigmaS)
+	attenuation := func(sigma float32) float32 {
+		if sigma <= 0.0 {
+			return 1.0
+		}
+		return math32.Exp(-sigma * tmax)
+	}
+	return Vector3{attenuation(sigmaT.X), attenuation(sigmaT.Y), attenuation(sigmaT.Z)}
+}
+
+func (medium *HomogeneousMedium) GetPhase() PhaseFunction {
+	return medium.Phase
+}
+
+// maxDensityGridVoxels bounds the size of a grid, which keeps the voxel indices in int32
+const maxDensityGridVoxels int64 = 1 << 30
+
+// DensityGrid voxels of density, x is the fastest
+type DensityGrid struct {
+	NX      int32
+	NY      int32
+	NZ      int32
+	Density []float32
+}
+
+// LoadDensityGrid loads a raw voxel file
+//
+// The file is three little endian int32 of the resolution in x, y and z, followed by the float32 densities.
+func LoadDensityGrid(path string) (*DensityGrid, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadDensityGrid(file)
+}
+
+func ReadDensityGrid(reader io.Reader) (*DensityGrid, error) {
+	var resolution [3]int32
+	if err := binary.Read(reader, binary.LittleEndian, &resolution); err != nil {
+		return nil, err
+	}
+	if resolution[0] <= 0 || resolution[1] <= 0 || resolution[2] <= 0 {
+		return nil, errors.New("invalid resolution of density grid")
+	}
+	count := int64(resolution[0]) * int64(resolution[1]) * int64(resolution[2])
+	if maxDensityGridVoxels < count {
+		return nil, fmt.Errorf("density grid of %dx%dx%d is too large", resolution[0], resolution[1], resolution[2])
+	}
+	density := make([]float32, count)
+	if err := binary.Read(reader, binary.LittleEndian, density); err != nil {
+		return nil, err
+	}
+	return &DensityGrid{resolution[0], resolution[1], resolution[2], density}, nil
+}
+
+func (grid *DensityGrid) voxel(x, y, z int32) float32 {
+	x = wrapTexel(x, grid.NX, WrapClamp)
+	y = wrapTexel(y, grid.NY, WrapClamp)
+	z = wrapTexel(z, grid.NZ, WrapClamp)
+	return grid.Density[(z*grid.NY+y)*grid.NX+x]
+}
+
+// Lookup trilinearly interpolates the density at p in [0 1]^3, zero outside
+func (grid *DensityGrid) Lookup(p Vector3) float32 {
+	if p.X < 0.0 || 1.0 < p.X || p.Y < 0.0 || 1.0 < p.Y || p.Z < 0.0 || 1.0 < p.Z {
+		return 0.0
+	}
+	x := p.X*float32(grid.NX) - 0.5
+	y := p.Y*float32(grid.NY) - 0.5
+	z := p.Z*float32(grid.NZ) - 0.5
+	fx := math32.Floor(x)
+	fy := math32.Floor(y)
+	fz := math32.Floor(z)
+	dx := x - fx
+	dy := y - fy
+	dz := z - fz
+	ix := int32(fx)
+	iy := int32(fy)
+	iz := int32(fz)
+	d00 := Lerp32(grid.voxel(ix, iy, iz), grid.voxel(ix+1, iy, iz), dx)
+	d10 := Lerp32(grid.voxel(ix, iy+1, iz), grid.voxel(ix+1, iy+1, iz), dx)
+	d01 := Lerp32(grid.voxel(ix, iy, iz+1), grid.voxel(ix+1, iy, iz+1), dx)
+	d11 := Lerp32(grid.voxel(ix, iy+1, iz+1), grid.voxel(ix+1, iy+1, iz+1), dx)
+	return Lerp32(Lerp32(d00, d10, dy), Lerp32(d01, d11, dy), dz)
+}
+
+func (grid *DensityGrid) MaxDensity() float32 {
+	maxDensity := float32(0.0)
+	for _, density := range grid.Density {
+		maxDensity = math32.Max(maxDensity, density)
+	}
+	return maxDensity
+}
+
+// GridMedium heterogeneous medium of the density grid placed in the box from Min to Max
+//
+// The coefficients are multiplied by the density. Sample uses delta tracking and Transmittance uses ratio tracking
+// against the majorant of the whole grid.
+// Jan Novák, Andrew Selle, Wojciech Jarosz, "Residual Ratio Tracking for Estimating Attenuation in Participating Media", SIGGRAPH Asia 2014
+type GridMedium struct {
+	Grid     *DensityGrid
+	Min      Vector3
+	Max      Vector3
+	SigmaA   Vector3
+	SigmaS   Vector3
+	Phase    PhaseFunction
+	majorant float32
+}
+
+func NewGridMedium(grid *DensityGrid, min, max, sigmaA, sigmaS Vector3, g float32) GridMedium {
+	majorant := grid.MaxDensity() * MaxElementVector3(AddVector3(sigmaA, sigmaS))
+	return GridMedium{grid, min, max, sigmaA, sigmaS, &HenyeyGreensteinPhase{g}, majorant}
+}
+
+// clip returns the range of the ray in the box
+func (medium *GridMedium) clip(ray Ray, tmax float32) (float32, float32, bool) {
+	t0 := float32(0.0)
+	t1 := tmax
+	origin := [3]float32{ray.Origin.X, ray.Origin.Y, ray.Origin.Z}
+	direction := [3]float32{ray.Direction.X, ray.Direction.Y, ray.Direction.Z}
+	min := [3]float32{medium.Min.X, medium.Min.Y, medium.Min.Z}
+	max := [3]float32{medium.Max.X, medium.Max.Y, medium.Max.Z}
+	for i := 0; i < 3; i++ {
+		if math32.Abs(direction[i]) <= Epsilon32 {
+			if origin[i] < min[i] || max[i] < origin[i] {
+				return 0.0, 0.0, false
+			}
+			continue
+		}
+		inv := 1.0 / direction[i]
+		near := (min[i] - origin[i]) * inv
+		far := (max[i] - origin[i]) * inv
+		if far < near {
+			near, far = far, near
+		}
+		t0 = math32.Max(t0, near)
+		t1 = math32.Min(t1, far)
+	}
+	return t0, t1, t0 < t1
+}
+
+func (medium *GridMedium) density(p Vector3) float32 {
+	size := SubVector3(medium.Max, medium.Min)
+	local := SubVector3(p, medium.Min)
+	return medium.Grid.Lookup(Vector3{local.X / size.X, local.Y / size.Y, local.Z / size.Z})
+}
+
+func (medium *GridMedium) Sample(ray Ray, tmax float32) (float32, Vector3, bool) {
+	weight := Vector3{1.0, 1.0, 1.0}
+	t0, t1, valid := medium.clip(ray, tmax)
+	if !valid || medium.majorant <= 0.0 {
+		return tmax, weight, false
+	}
+	t := t0
+	for {
+		t -= math32.Log(1.0-rand.Float32()) / medium.majorant
+		if t1 <= t {
+			return tmax, weight, false
+		}
+		density := medium.density(ray.PointAt(t))
+		sigmaA := MulVector3(density, medium.SigmaA)
+		sigmaS := MulVector3(density, medium.SigmaS)
+		sigmaN := SubVector3(Vector3{medium.majorant, medium.majorant, medium.majorant}, AddVector3(sigmaA, sigmaS))
+		pA := averageVector3(sigmaA) / medium.majorant
+		pS := averageVector3(sigmaS) / medium.majorant
+		u := rand.Float32()
+		if u < pA {
+			return t, Vector3{}, false
+		}
+		if u < pA+pS {
+			return t, HadamardDotVector3(weight, DivVector3(sigmaS, medium.majorant*pS)), true
+		}
+		pN := 1.0 - pA - pS
+		if pN <= 0.0 {
+			return t, Vector3{}, false
+		}
+		weight = HadamardDotVector3(weight, DivVector3(sigmaN, medium.majorant*pN))
+	}
+}
+
+func (medium *GridMedium) Transmittance(ray Ray, tmax float32) Vector3 {
+	transmittance := Vector3{1.0, 1.0, 1.0}
+	t0, t1, valid := medium.clip(ray, tmax)
+	if !valid || medium.majorant <= 0.0 {
+		return transmittance
+	}
+	sigmaT := AddVector3(medium.SigmaA, medium.SigmaS)
+	t := t0
+	for {
+		t -= math32.Log(1.0-rand.Float32()) / medium.majorant
+		if t1 <= t {
+			return transmittance
+		}
+		density := medium.density(ray.PointAt(t))
+		ratio := SubVector3(Vector3{1.0, 1.0, 1.0}, MulVector3(density/medium.majorant, sigmaT))
+		transmittance = HadamardDotVector3(transmittance, ratio)
+		if MaxElementVector3(transmittance) < 1.0e-3 {
+			// Russian roulette for the tail
+			if rand.Float32() < 0.5 {
+				return Vector3{}
+			}
+			transmittance = MulVector3(2.0, transmittance)
+		}
+	}
+}
+
+func (medium *GridMedium) GetPhase() PhaseFunction {
+	return medium.Phase
+}
+
+// MediumInterface is implemented by materials which bound a medium inside their closed surfaces
+type MediumInterface interface {
+	GetInterior() Medium
+}
+
+// MediumBoundary invisible surface which only bounds a medium, rays pass through it straight
+type MediumBoundary struct {
+	Interior Medium
+}
+
+func (boundary *MediumBoundary) GetInterior() Medium {
+	return boundary.Interior
+}
+
+func (boundary *MediumBoundary) Resolve(hitRecord *HitRecord) Material {
+	return boundary
+}
+
+func (boundary *MediumBoundary) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	return MaterialSample{true, 1.0, Vector3{1.0, 1.0, 1.0}, wi.Minus()}
+}
+
+func (boundary *MediumBoundary) Eval(wi, wo Vector3) Vector3 {
+	return Vector3{}
+}
+
+func (boundary *MediumBoundary) Pdf(wi, wo Vector3) float32 {
+	return 0.0
+}
+
+func (boundary *MediumBoundary) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	*attenuation = Vector3{1.0, 1.0, 1.0}
+	*scattered = Ray{hitRecord.Position, ray.Direction, ray.Time}
+	return true
+}
+
+func (boundary *MediumBoundary) GetRoughness() float32 {
+	return 0.0
+}
+
+func (boundary *MediumBoundary) GetMetallic() float32 {
+	return 0.0
+}
+
+func (boundary *MediumBoundary) GetAlbedo() Vector3 {
+	return Vector3{}
+}
+
+// NextMedium returns the medium after crossing the surface of a hit towards direction
+//
+// Entering a MediumInterface switches to its interior, leaving it switches to the outside.
+func NextMedium(hitRecord *HitRecord, direction Vector3, current, outside Medium) Medium {
+	material, ok := hitRecord.Material.(MediumInterface)
+	if !ok {
+		return current
+	}
+	if DotVector3(direction, hitRecord.GeometricNormal) < 0.0 {
+		return material.GetInterior()
+	}
+	return outside
+}
+
+// PassThrough returns the transmittance of a surface which rays cross straight, false if the surface scatters
+//
+// MediumBoundary and dielectrics without a film whose index is matched are crossed, the same as by Transmittance,
+// so that paths crossing them keep the weights of multiple importance sampling against shadow rays.
+func PassThrough(material Material) (Vector3, bool) {
+	switch surface := material.(type) {
+	case *MediumBoundary:
+		return Vector3{1.0, 1.0, 1.0}, true
+	case *Dielectric:
+		if Equal32(1.0, surface.RefIndex) && surface.FilmThickness <= 0.0 && nil == surface.Dispersion {
+			return surface.Albedo, true
+		}
+	}
+	return Vector3{}, false
+}
+
+// Transmittance of a shadow ray through the media, it passes through the surfaces of PassThrough and is blocked by other surfaces visible to shadows
+func Transmittance(world World, ray Ray, tmax float32, medium, outside Medium) Vector3 {
+	transmittance := Vector3{1.0, 1.0, 1.0}
+	hitRecord := HitRecord{}
+	for bounce := 0; bounce < 64; bounce++ {
+		hit := world.HitVisible(ray, 0.001, tmax, VisibleShadow, &hitRecord)
+		distance := tmax
+		if hit {
+			distance = hitRecord.T
+		}
+		if nil != medium {
+			transmittance = HadamardDotVector3(transmittance, medium.Transmittance(ray, distance))
+		}
+		if !hit {
+			return transmittance
+		}
+		surface, passes := PassThrough(hitRecord.Material.Resolve(&hitRecord))
+		if !passes {
+			return Vector3{}
+		}
+		transmittance = HadamardDotVector3(transmittance, surface)
+		if transmittance.IsZero() {
+			return transmittance
+		}
+		medium = NextMedium(&hitRecord, ray.Direction, medium, outside)
+		ray = Ray{hitRecord.Position, ray.Direction, ray.Time}
+		tmax -= distance
+	}
+	return Vector3{}
+}
@@ -0,0 +1,136 @@
+package core
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"git.maze.io/go/math32"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHomogeneousTransmittance(t *testing.T) {
+	assert := assert.New(t)
+	medium := NewHomogeneousMedium(Vector3{0.1, 0.2, 0.3}, Vector3{0.4, 0.3, 0.2}, 0.0)
+	ray := Ray{Vector3{}, Vector3{1.0, 0.0, 0.0}, 0.0}
+	transmittance := medium.Transmittance(ray, 2.0)
+	assert.InDelta(math32.Exp(-1.0), transmittance.X, 1.0e-5)
+	assert.InDelta(math32.Exp(-1.0), transmittance.Y, 1.0e-5)
+	assert.InDelta(math32.Exp(-1.0), transmittance.Z, 1.0e-5)
+
+	// The escaping weights estimate the transmittance
+	rand.Seed(1)
+	const samples = 100000
+	escaped := Vector3{}
+	for i := 0; i < samples; i++ {
+		_, weight, scattered := medium.Sample(ray, 2.0)
+		if !scattered {
+			escaped = AddVector3(escaped, weight)
+		}
+	}
+	escaped = DivVector3(escaped, samples)
+	assert.InDelta(transmittance.X, escaped.X, 0.01)
+}
+
+func constantGrid(density float32) *DensityGrid {
+	grid := &DensityGrid{4, 4, 4, make([]float32, 64)}
+	for i := range grid.Density {
+		grid.Density[i] = density
+	}
+	return grid
+}
+
+func TestGridMedium(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(1)
+	sigmaA := Vector3{0.2, 0.2, 0.2}
+	sigmaS := Vector3{0.3, 0.3, 0.3}
+	// Half of the majorant are null collisions
+	grid := constantGrid(1.0)
+	grid.Density[0] = 2.0
+	medium := NewGridMedium(grid, Vector3{-1.0, -1.0, -1.0}, Vector3{1.0, 1.0, 1.0}, sigmaA, sigmaS, 0.0)
+	ray := Ray{Vector3{-3.0, 0.0, 0.0}, Vector3{1.0, 0.0, 0.0}, 0.0}
+	expected := math32.Exp(-0.5 * 2.0)
+
+	const samples = 100000
+	transmittance := float32(0.0)
+	escaped := float32(0.0)
+	for i := 0; i < samples; i++ {
+		transmittance += medium.Transmittance(ray, 10.0).X
+		_, weight, scattered := medium.Sample(ray, 10.0)
+		if !scattered {
+			escaped += weight.X
+		}
+	}
+	assert.InDeltaf(expected, transmittance/samples, 0.01, "Ratio tracking")
+	assert.InDeltaf(expected, escaped/samples, 0.01, "Delta tracking")
+
+	outside := Ray{Vector3{-3.0, 2.0, 0.0}, Vector3{1.0, 0.0, 0.0}, 0.0}
+	assert.InDelta(1.0, medium.Transmittance(outside, 10.0).X, 1.0e-5)
+}
+
+func TestLoadDensityGrid(t *testing.T) {
+	assert := assert.New(t)
+	var buffer bytes.Buffer
+	binary.Write(&buffer, binary.LittleEndian, []int32{2, 1, 1})
+	binary.Write(&buffer, binary.LittleEndian, []float32{0.0, 1.0})
+	path := filepath.Join(t.TempDir(), "density.raw")
+	assert.Nil(os.WriteFile(path, buffer.Bytes(), 0644))
+
+	grid, err := LoadDensityGrid(path)
+	assert.Nil(err)
+	assert.Equal(int32(2), grid.NX)
+	assert.InDelta(0.5, grid.Lookup(Vector3{0.5, 0.5, 0.5}), 1.0e-5)
+	assert.InDelta(1.0, grid.Lookup(Vector3{0.75, 0.5, 0.5}), 1.0e-5)
+
+	_, err = ReadDensityGrid(bytes.NewReader(buffer.Bytes()[:16]))
+	assert.NotNil(err)
+
+	// Resolutions whose product overflows int32 are rejected before allocating
+	for _, resolution := range [][]int32{{2048, 2048, 1024}, {1300, 1300, 1300}} {
+		buffer.Reset()
+		binary.Write(&buffer, binary.LittleEndian, resolution)
+		_, err = ReadDensityGrid(bytes.NewReader(buffer.Bytes()))
+		assert.NotNil(err)
+	}
+}
+
+func TestPhaseFunction(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(1)
+	direction := Vector3{0.0, 0.0, 1.0}
+	phases := []PhaseFunction{&IsotropicPhase{}, &HenyeyGreensteinPhase{0.6}}
+	for _, phase := range phases {
+		// Uniform sphere estimate of the integral
+		const samples = 100000
+		sum := float32(0.0)
+		for i := 0; i < samples; i++ {
+			sum += phase.Eval(direction, RandomOnSphere(rand.Float32(), rand.Float32()))
+		}
+		assert.InDelta(1.0, sum*4.0*math32.Pi/samples, 0.03)
+		next, pdf := phase.Sample(direction, rand.Float32(), rand.Float32())
+		assert.InDelta(phase.Eval(direction, next), pdf, 1.0e-4)
+	}
+}
+
+func TestTransmittanceThroughBoundary(t *testing.T) {
+	assert := assert.New(t)
+	medium := NewHomogeneousMedium(Vector3{1.0, 1.0, 1.0}, Vector3{}, 0.0)
+	world := NewHittableList()
+	world.AddHittable(&Sphere{Vector3{}, 1.0, &MediumBoundary{&medium}})
+	ray := Ray{Vector3{-3.0, 0.0, 0.0}, Vector3{1.0, 0.0, 0.0}, 0.0}
+	transmittance := Transmittance(&world, ray, Infinity32, nil, nil)
+	assert.InDeltaf(math32.Exp(-2.0), transmittance.X, 1.0e-4, "Only the interior should attenuate")
+
+	// An index matched dielectric is crossed like the boundary and filters by its albedo
+	world.AddHittable(&Sphere{Vector3{3.0, 0.0, 0.0}, 0.5, &Dielectric{Albedo: Vector3{0.5, 0.5, 0.5}, RefIndex: 1.0}})
+	transmittance = Transmittance(&world, ray, Infinity32, nil, nil)
+	assert.InDelta(0.25*math32.Exp(-2.0), transmittance.X, 1.0e-4)
+
+	world.AddHittable(&Sphere{Vector3{6.0, 0.0, 0.0}, 1.0, &Lambertian{Albedo: Vector3{0.5, 0.5, 0.5}}})
+	transmittance = Transmittance(&world, ray, Infinity32, nil, nil)
+	assert.Truef(transmittance.IsZero(), "Opaque surfaces should block")
+	_, passes := PassThrough(&Dielectric{Albedo: Vector3{1.0, 1.0, 1.0}, RefIndex: 1.5})
+	assert.False(passes)
+}
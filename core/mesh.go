@@ -0,0 +1,275 @@
+package core
+
+import (
+	"errors"
+	"git.maze.io/go/math32"
+)
+
+// Mesh indexed triangle mesh, three indices per triangle
+//
+// Normals, UVs and Colors are optional per vertex attributes, nil if missing. Without normals the triangles are flat,
+// without UVs a triangle is parameterized by its barycentric coordinates.
+// The front faces are counterclockwise, the geometric normal is turned to the side of the interpolated normal.
+//
+// The triangles are not Hittables of their own, the indices are reordered so that the triangles of a leaf of the
+// hierarchy are contiguous, which keeps meshes of millions of triangles compact.
+type Mesh struct {
+	Positions []Vector3
+	Normals   []Vector3
+	UVs       []Vector2
+	Colors    []Vector3
+	Indices   []int32
+	Material  Material
+	nodes     []bvhFlatNode
+	areas     []float32
+	area      float32
+}
+
+// NewMesh validates the attributes and builds the BVH of the triangles, the indices are reordered in place
+func NewMesh(positions, normals []Vector3, uvs []Vector2, indices []int32, material Material) (*Mesh, error) {
+	if 0 != len(indices)%3 {
+		return nil, errors.New("number of mesh indices is not a multiple of 3")
+	}
+	if nil != normals && len(normals) != len(positions) {
+		return nil, errors.New("number of mesh normals does not match positions")
+	}
+	if nil != uvs && len(uvs) != len(positions) {
+		return nil, errors.New("number of mesh uvs does not match positions")
+	}
+	for _, index := range indices {
+		if index < 0 || int32(len(positions)) <= index {
+			return nil, errors.New("mesh index out of range")
+		}
+	}
+	mesh := &Mesh{Positions: positions, Normals: normals, UVs: uvs, Indices: indices, Material: material}
+	count := len(indices) / 3
+	bounds := make([]AABB, count)
+	for i := range bounds {
+		p0, p1, p2 := mesh.vertices(int32(i))
+		bounds[i] = ExpandAABB(ExpandAABB(AABB{p0, p0}, p1), p2)
+	}
+	nodes, order := buildBVH(bounds)
+	mesh.nodes = nodes
+	reordered := make([]int32, len(indices))
+	for i, triangle := range order {
+		copy(reordered[3*i:3*i+3], indices[3*triangle:3*triangle+3])
+	}
+	copy(indices, reordered)
+
+	mesh.areas = make([]float32, count)
+	for i := 0; i < count; i++ {
+		p0, p1, p2 := mesh.vertices(int32(i))
+		n := CrossVector3(SubVector3(p1, p0), SubVector3(p2, p0))
+		mesh.area += 0.5 * n.Length()
+		mesh.areas[i] = mesh.area
+	}
+	return mesh, nil
+}
+
+// SetColors sets the vertex colors, nil removes them
+func (mesh *Mesh) SetColors(colors []Vector3) error {
+	if nil != colors && len(colors) != len(mesh.Positions) {
+		return errors.New("number of mesh colors does not match positions")
+	}
+	mesh.Colors = colors
+	return nil
+}
+
+func (mesh *Mesh) NumTriangles() int32 {
+	return int32(len(mesh.Indices) / 3)
+}
+
+func (mesh *Mesh) vertices(triangle int32) (Vector3, Vector3, Vector3) {
+	i := 3 * triangle
+	return mesh.Positions[mesh.Indices[i]], mesh.Positions[mesh.Indices[i+1]], mesh.Positions[mesh.Indices[i+2]]
+}
+
+// Hit traverses the hierarchy and fills the record only for the closest triangle, unless the material tests alpha
+func (mesh *Mesh) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	if 0 == len(mesh.nodes) {
+		return false
+	}
+	_, alpha := mesh.Material.(AlphaTester)
+	if nil != record.alphaMaterial {
+		_, alpha = record.alphaMaterial.(AlphaTester)
+	}
+	closest := int32(-1)
+	closestSoFar := tmax
+	var b1, b2 float32
+	var stack [bvhStackSize]int32
+	top := 1
+	stack[0] = 0
+	for 0 < top {
+		top--
+		node := &mesh.nodes[stack[top]]
+		if _, _, valid := node.Bounds.Intersect(ray, tmin, closestSoFar); !valid {
+			continue
+		}
+		if 0 == node.Count {
+			stack[top] = node.Index
+			stack[top+1] = node.Index + 1
+			top += 2
+			continue
+		}
+		for triangle := node.Index; triangle < node.Index+node.Count; triangle++ {
+			p0, p1, p2 := mesh.vertices(triangle)
+			t, u, v, hit := intersectTriangle(ray, p0, p1, p2, tmin, closestSoFar)
+			if !hit {
+				continue
+			}
+			if alpha {
+				tmp := HitRecord{alphaMaterial: record.alphaMaterial}
+				mesh.setRecord(ray, triangle, t, u, v, &tmp)
+				if !alphaTest(&tmp) {
+					continue
+				}
+			}
+			closest, closestSoFar, b1, b2 = triangle, t, u, v
+		}
+	}
+	if closest < 0 {
+		return false
+	}
+	mesh.setRecord(ray, closest, closestSoFar, b1, b2, record)
+	return true
+}
+
+func (mesh *Mesh) BoundingBox() AABB {
+	if 0 == len(mesh.nodes) {
+		return NewEmptyAABB()
+	}
+	return mesh.nodes[0].Bounds
+}
+
+func (mesh *Mesh) Area() float32 {
+	return mesh.area
+}
+
+// SampleArea picks a triangle by its area, then a point uniformly on it
+func (mesh *Mesh) SampleArea(u0, u1 float32) (Vector3, Vector3) {
+	count := len(mesh.areas)
+	if 0 == count {
+		return Vector3{}, Vector3{0.0, 1.0, 0.0}
+	}
+	target := u0 * mesh.area
+	low, high := 0, count-1
+	for low < high {
+		middle := (low + high) / 2
+		if mesh.areas[middle] < target {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+	start := float32(0.0)
+	if 0 < low {
+		start = mesh.areas[low-1]
+	}
+	// Reuse u0 within the picked triangle
+	u := float32(0.0)
+	if start < mesh.areas[low] {
+		u = Clamp0132((target - start) / (mesh.areas[low] - start))
+	}
+	su := math32.Sqrt(u)
+	b1 := su * (1.0 - u1)
+	b2 := su * u1
+	p0, p1, p2 := mesh.vertices(int32(low))
+	position := AddVector3(AddVector3(MulVector3(1.0-b1-b2, p0), MulVector3(b1, p1)), MulVector3(b2, p2))
+	return position, NormalizeVector3(CrossVector3(SubVector3(p1, p0), SubVector3(p2, p0)))
+}
+
+// intersectTriangle by the ray triangle intersection of Möller and Trumbore, returns t and the barycentrics of p1 and p2
+//
+// Tomas Möller, Ben Trumbore, "Fast, Minimum Storage Ray/Triangle Intersection", 1997
+func intersectTriangle(ray Ray, p0, p1, p2 Vector3, tmin, tmax float32) (float32, float32, float32, bool) {
+	e1 := SubVector3(p1, p0)
+	e2 := SubVector3(p2, p0)
+	pvec := CrossVector3(ray.Direction, e2)
+	determinant := DotVector3(e1, pvec)
+	if math32.Abs(determinant) <= Epsilon32*Epsilon32 {
+		return 0.0, 0.0, 0.0, false
+	}
+	inverse := 1.0 / determinant
+	tvec := SubVector3(ray.Origin, p0)
+	b1 := DotVector3(tvec, pvec) * inverse
+	if b1 < 0.0 || 1.0 < b1 {
+		return 0.0, 0.0, 0.0, false
+	}
+	qvec := CrossVector3(tvec, e1)
+	b2 := DotVector3(ray.Direction, qvec) * inverse
+	if b2 < 0.0 || 1.0 < b1+b2 {
+		return 0.0, 0.0, 0.0, false
+	}
+	t := DotVector3(e2, qvec) * inverse
+	if t <= tmin || tmax <= t {
+		return 0.0, 0.0, 0.0, false
+	}
+	return t, b1, b2, true
+}
+
+func (mesh *Mesh) setRecord(ray Ray, triangle int32, t, b1, b2 float32, record *HitRecord) {
+	b0 := 1.0 - b1 - b2
+	i := 3 * triangle
+	i0, i1, i2 := mesh.Indices[i], mesh.Indices[i+1], mesh.Indices[i+2]
+	p0, p1, p2 := mesh.Positions[i0], mesh.Positions[i1], mesh.Positions[i2]
+	e1 := SubVector3(p1, p0)
+	e2 := SubVector3(p2, p0)
+	geometric := NormalizeVector3(CrossVector3(e1, e2))
+
+	uv0, uv1, uv2 := Vector2{0.0, 0.0}, Vector2{1.0, 0.0}, Vector2{1.0, 1.0}
+	if nil != mesh.UVs {
+		uv0, uv1, uv2 = mesh.UVs[i0], mesh.UVs[i1], mesh.UVs[i2]
+	}
+	du1, dv1 := uv1.X-uv0.X, uv1.Y-uv0.Y
+	du2, dv2 := uv2.X-uv0.X, uv2.Y-uv0.Y
+	uvDeterminant := du1*dv2 - dv1*du2
+	var dpdu, dpdv Vector3
+	degenerate := math32.Abs(uvDeterminant) <= Epsilon32*Epsilon32
+	if !degenerate {
+		inverse := 1.0 / uvDeterminant
+		dpdu = MulVector3(inverse, SubVector3(MulVector3(dv2, e1), MulVector3(dv1, e2)))
+		dpdv = MulVector3(inverse, SubVector3(MulVector3(du1, e2), MulVector3(du2, e1)))
+		cross := CrossVector3(dpdu, dpdv)
+		degenerate = cross.LengthSqr() <= 0.0
+	}
+	if degenerate {
+		dpdu, dpdv = OrthonormalBasis(geometric)
+	}
+
+	normal := geometric
+	dndu, dndv := Vector3{}, Vector3{}
+	if nil != mesh.Normals {
+		n0, n1, n2 := mesh.Normals[i0], mesh.Normals[i1], mesh.Normals[i2]
+		interpolated := AddVector3(AddVector3(MulVector3(b0, n0), MulVector3(b1, n1)), MulVector3(b2, n2))
+		if 0.0 < interpolated.LengthSqr() {
+			normal = NormalizeVector3(interpolated)
+			if DotVector3(normal, geometric) < 0.0 {
+				geometric = geometric.Minus()
+			}
+		}
+		if !degenerate {
+			inverse := 1.0 / uvDeterminant
+			dn1 := SubVector3(n1, n0)
+			dn2 := SubVector3(n2, n0)
+			dndu = MulVector3(inverse, SubVector3(MulVector3(dv2, dn1), MulVector3(dv1, dn2)))
+			dndv = MulVector3(inverse, SubVector3(MulVector3(du1, dn2), MulVector3(du2, dn1)))
+		}
+	}
+
+	record.T = t
+	record.Position = ray.PointAt(t)
+	record.Normal = normal
+	record.GeometricNormal = geometric
+	record.Tangent = NormalizeVector3(dpdu)
+	record.UV = Vector2{b0*uv0.X + b1*uv1.X + b2*uv2.X, b0*uv0.Y + b1*uv1.Y + b2*uv2.Y}
+	record.DPDU = dpdu
+	record.DPDV = dpdv
+	record.DNDU = dndu
+	record.DNDV = dndv
+	record.Color = Vector3{1.0, 1.0, 1.0}
+	if nil != mesh.Colors {
+		c0, c1, c2 := mesh.Colors[i0], mesh.Colors[i1], mesh.Colors[i2]
+		record.Color = AddVector3(AddVector3(MulVector3(b0, c0), MulVector3(b1, c1)), MulVector3(b2, c2))
+	}
+	record.Material = mesh.Material
+}
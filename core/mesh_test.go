@@ -0,0 +1,42 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"git.maze.io/go/math32"
+	"testing"
+)
+
+func newTestQuadMesh(material Material) (*Mesh, error) {
+	positions := []Vector3{{-1.0, -1.0, 0.0}, {1.0, -1.0, 0.0}, {1.0, 1.0, 0.0}, {-1.0, 1.0, 0.0}}
+	normals := []Vector3{{0.0, 0.0, 1.0}, {0.0, 0.0, 1.0}, {0.0, 0.0, 1.0}, {0.0, 0.0, 1.0}}
+	uvs := []Vector2{{0.0, 0.0}, {1.0, 0.0}, {1.0, 1.0}, {0.0, 1.0}}
+	return NewMesh(positions, normals, uvs, []int32{0, 1, 2, 0, 2, 3}, material)
+}
+
+func TestMesh(t *testing.T) {
+	assert := assert.New(t)
+	mesh, err := newTestQuadMesh(&Lambertian{Vector3{0.5, 0.5, 0.5}, nil})
+	assert.Nil(err)
+	assert.Equal(int32(2), mesh.NumTriangles())
+	assert.InDelta(4.0, mesh.Area(), 1.0e-5)
+
+	record := HitRecord{}
+	assert.True(mesh.Hit(Ray{Vector3{0.5, -0.25, 3.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &record))
+	assert.InDelta(3.0, record.T, 1.0e-5)
+	assert.InDelta(0.75, record.UV.X, 1.0e-5)
+	assert.InDelta(0.375, record.UV.Y, 1.0e-5)
+	assert.InDelta(1.0, record.Normal.Z, 1.0e-5)
+	assert.InDelta(1.0, record.GeometricNormal.Z, 1.0e-5)
+	assert.InDelta(2.0, record.DPDU.X, 1.0e-5)
+	assert.InDelta(2.0, record.DPDV.Y, 1.0e-5)
+	assert.False(mesh.Hit(Ray{Vector3{1.5, 0.0, 3.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &record))
+
+	position, normal := mesh.SampleArea(0.7, 0.3)
+	assert.InDelta(0.0, position.Z, 1.0e-5)
+	assert.True(math32.Abs(position.X) <= 1.0 && math32.Abs(position.Y) <= 1.0)
+	assert.InDelta(1.0, normal.Z, 1.0e-5)
+
+	_, err = NewMesh([]Vector3{{}, {}}, nil, nil, []int32{0, 1, 2}, nil)
+	assert.NotNil(err)
+	_, err = NewMesh([]Vector3{{}, {}, {}}, nil, nil, []int32{0, 1}, nil)
+	assert.NotNil(err)
+}
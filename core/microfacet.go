@@ -0,0 +1,194 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// ggx anisotropic GGX (Trowbridge-Reitz) distribution of microfacet normals in the local shading frame
+//
+// Eric Heitz, "Understanding the Masking-Shadowing Function in Microfacet-Based BRDFs", JCGT 2014
+// Eric Heitz, "Sampling the GGX Distribution of Visible Normals", JCGT 2018
+type ggx struct {
+	alphaX float32
+	alphaY float32
+}
+
+func newGGX(roughness, anisotropic float32) ggx {
+	aspect := math32.Sqrt(1.0 - 0.9*anisotropic)
+	alpha := roughness * roughness
+	return ggx{math32.Max(0.001, alpha/aspect), math32.Max(0.001, alpha*aspect)}
+}
+
+// D density of microfacet normal m
+func (distribution ggx) D(m Vector3) float32 {
+	if m.Z <= 0.0 {
+		return 0.0
+	}
+	x := m.X / distribution.alphaX
+	y := m.Y / distribution.alphaY
+	denom := x*x + y*y + m.Z*m.Z
+	return 1.0 / (math32.Pi * distribution.alphaX * distribution.alphaY * denom * denom)
+}
+
+// Lambda Smith's auxiliary function
+func (distribution ggx) Lambda(v Vector3) float32 {
+	z2 := v.Z * v.Z
+	if z2 <= Epsilon32*Epsilon32 {
+		return Infinity32
+	}
+	x := distribution.alphaX * v.X
+	y := distribution.alphaY * v.Y
+	alpha2Tan2 := (x*x + y*y) / z2
+	return 0.5 * (math32.Sqrt(1.0+alpha2Tan2) - 1.0)
+}
+
+// G1 masking function
+func (distribution ggx) G1(v Vector3) float32 {
+	return 1.0 / (1.0 + distribution.Lambda(v))
+}
+
+// G2 height-correlated masking-shadowing function
+func (distribution ggx) G2(wi, wo Vector3) float32 {
+	return 1.0 / (1.0 + distribution.Lambda(wi) + distribution.Lambda(wo))
+}
+
+// DV density of visible normal m from direction v
+func (distribution ggx) DV(v, m Vector3) float32 {
+	cosine := math32.Abs(v.Z)
+	if cosine <= Epsilon32 {
+		return 0.0
+	}
+	return distribution.G1(v) * math32.Abs(DotVector3(v, m)) * distribution.D(m) / cosine
+}
+
+// PdfReflection density of the direction reflected about a visible normal m
+func (distribution ggx) PdfReflection(wi, m Vector3) float32 {
+	dotIM := math32.Abs(DotVector3(wi, m))
+	if dotIM <= Epsilon32 {
+		return 0.0
+	}
+	return distribution.DV(wi, m) / (4.0 * dotIM)
+}
+
+// SampleVisible samples a visible normal from direction v, the result is always on the upper hemisphere
+func (distribution ggx) SampleVisible(v Vector3, eta0, eta1 float32) Vector3 {
+	if v.Z < 0.0 {
+		v = v.Minus()
+	}
+	return ggx_VNDF(v, distribution.alphaX, distribution.alphaY, eta0, eta1)
+}
+
+// refractMicrofacet refracts wi about the microfacet normal m, wi points away from the surface.
+// eta is the index of the lower side over the upper side of m.
+func refractMicrofacet(wi, m Vector3, eta float32) (Vector3, bool) {
+	cosine := DotVector3(wi, m)
+	if cosine < 0.0 {
+		eta = 1.0 / eta
+		cosine = -cosine
+		m = m.Minus()
+	}
+	sin2T := (1.0 - cosine*cosine) / (eta * eta)
+	if 1.0 <= sin2T {
+		return Vector3{}, false
+	}
+	cosT := math32.Sqrt(1.0 - sin2T)
+	wo := AddVector3(MulVector3(-1.0/eta, wi), MulVector3(cosine/eta-cosT, m))
+	return wo, true
+}
+
+// halfVectorDielectric generalized half vector of a pair of directions which is on the upper hemisphere
+func halfVectorDielectric(wi, wo Vector3, eta float32) (Vector3, float32, bool) {
+	etap := float32(1.0)
+	if wi.Z*wo.Z < 0.0 {
+		if 0.0 < wi.Z {
+			etap = eta
+		} else {
+			etap = 1.0 / eta
+		}
+	}
+	wm := AddVector3(MulVector3(etap, wo), wi)
+	if EqualZero32(wi.Z) || EqualZero32(wo.Z) || wm.LengthSqr() <= Epsilon32*Epsilon32 {
+		return Vector3{}, etap, false
+	}
+	wm = NormalizeVector3(wm)
+	if wm.Z < 0.0 {
+		wm = wm.Minus()
+	}
+	if DotVector3(wm, wo)*wo.Z < 0.0 || DotVector3(wm, wi)*wi.Z < 0.0 {
+		return Vector3{}, etap, false
+	}
+	return wm, etap, true
+}
+
+// EvalDielectric rough dielectric BSDF multiplied by the cosine of wo
+//
+// Bruce Walter, Stephen R. Marschner, Hongsong Li, Kenneth E. Torrance, "Microfacet Models for Refraction through Rough Surfaces", EGSR 2007
+func (distribution ggx) EvalDielectric(wi, wo Vector3, eta float32) float32 {
+	wm, etap, valid := halfVectorDielectric(wi, wo, eta)
+	if !valid {
+		return 0.0
+	}
+	f := FresnelDielectric(DotVector3(wi, wm), eta)
+	d := distribution.D(wm)
+	g := distribution.G2(wi, wo)
+	if 0.0 < wi.Z*wo.Z {
+		return f * d * g / (4.0 * math32.Abs(wi.Z))
+	}
+	dotOM := DotVector3(wo, wm)
+	dotIM := DotVector3(wi, wm)
+	denom := dotOM + dotIM/etap
+	denom = denom * denom * math32.Abs(wi.Z) * etap * etap
+	return (1.0 - f) * d * g * math32.Abs(dotOM*dotIM) / denom
+}
+
+// PdfDielectric density of wo sampled by SampleDielectric
+func (distribution ggx) PdfDielectric(wi, wo Vector3, eta float32) float32 {
+	wm, etap, valid := halfVectorDielectric(wi, wo, eta)
+	if !valid {
+		return 0.0
+	}
+	f := FresnelDielectric(DotVector3(wi, wm), eta)
+	if 0.0 < wi.Z*wo.Z {
+		return f * distribution.PdfReflection(wi, wm)
+	}
+	dotOM := DotVector3(wo, wm)
+	denom := dotOM + DotVector3(wi, wm)/etap
+	return (1.0 - f) * distribution.DV(wi, wm) * math32.Abs(dotOM) / (denom * denom)
+}
+
+// SampleDielectric samples reflection or transmission about a visible normal according to the Fresnel reflectance
+func (distribution ggx) SampleDielectric(wi Vector3, eta, eta0, eta1, eta2 float32) (Vector3, bool) {
+	wm := distribution.SampleVisible(wi, eta0, eta1)
+	f := FresnelDielectric(DotVector3(wi, wm), eta)
+	if eta2 < f {
+		wo := SubVector3(MulVector3(2.0*DotVector3(wi, wm), wm), wi)
+		return wo, 0.0 < wi.Z*wo.Z
+	}
+	wo, valid := refractMicrofacet(wi, wm, eta)
+	return wo, valid && wi.Z*wo.Z < 0.0
+}
+
+// SampleDielectricLobe samples only reflection or only transmission about a visible normal
+func (distribution ggx) SampleDielectricLobe(wi Vector3, eta, eta0, eta1 float32, reflection bool) (Vector3, bool) {
+	wm := distribution.SampleVisible(wi, eta0, eta1)
+	if reflection {
+		wo := SubVector3(MulVector3(2.0*DotVector3(wi, wm), wm), wi)
+		return wo, 0.0 < wi.Z*wo.Z
+	}
+	wo, valid := refractMicrofacet(wi, wm, eta)
+	return wo, valid && wi.Z*wo.Z < 0.0
+}
+
+// PdfDielectricLobe density of wo sampled by SampleDielectricLobe
+func (distribution ggx) PdfDielectricLobe(wi, wo Vector3, eta float32) float32 {
+	wm, etap, valid := halfVectorDielectric(wi, wo, eta)
+	if !valid {
+		return 0.0
+	}
+	if 0.0 < wi.Z*wo.Z {
+		return distribution.PdfReflection(wi, wm)
+	}
+	dotOM := DotVector3(wo, wm)
+	denom := dotOM + DotVector3(wi, wm)/etap
+	return distribution.DV(wi, wm) * math32.Abs(dotOM) / (denom * denom)
+}
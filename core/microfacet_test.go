@@ -0,0 +1,35 @@
+package core
+import (
+	"testing"
+	"math/rand"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGGXNormalization(t *testing.T) {
+	assert := assert.New(t)
+	random := rand.New(rand.NewSource(1))
+	distributions := []ggx{{0.2, 0.2}, {0.5, 0.5}, {0.3, 0.8}}
+	for _, distribution := range distributions {
+		// Projected area of the microsurface should be one
+		const samples int = 200000
+		total := float32(0.0)
+		for i:=0; i<samples; i++ {
+			m := RandomOnHemiSphere(random.Float32(), random.Float32())
+			total += distribution.D(m) * m.Z
+		}
+		total *= 2.0 * 3.14159265 / float32(samples)
+		assert.InDeltaf(1.0, total, 0.05, "Projected area of %v should be 1 (%v)", distribution, total)
+	}
+}
+
+func TestGGXVisibleNormal(t *testing.T) {
+	assert := assert.New(t)
+	random := rand.New(rand.NewSource(2))
+	distribution := ggx{0.4, 0.4}
+	wi := NormalizeVector3(Vector3{0.5, 0.2, 0.7})
+	for i:=0; i<100; i++ {
+		wm := distribution.SampleVisible(wi, random.Float32(), random.Float32())
+		assert.Truef(Equal32(1.0, wm.Length()), "%v should be normalized", wm)
+		assert.Truef(0.0<wm.Z && 0.0<=DotVector3(wi, wm), "%v should be visible from %v", wm, wi)
+	}
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+type MipLevel struct {
+	Width  int32
+	Height int32
+	Image  []Vector3
+}
+
+// MipMap box filtered image pyramid, the level 0 is the original image
+type MipMap struct {
+	Levels []MipLevel
+	WrapU  WrapMode
+	WrapV  WrapMode
+}
+
+func NewMipMap(width, height int32, image []Vector3, wrapU, wrapV WrapMode) *MipMap {
+	levels := []MipLevel{{width, height, image}}
+	for 1 < width || 1 < height {
+		level := downsampleMipLevel(&levels[len(levels)-1], wrapU, wrapV)
+		levels = append(levels, level)
+		width = level.Width
+		height = level.Height
+	}
+	return &MipMap{levels, wrapU, wrapV}
+}
+
+func downsampleMipLevel(src *MipLevel, wrapU, wrapV WrapMode) MipLevel {
+	width := (src.Width + 1) / 2
+	height := (src.Height + 1) / 2
+	image := make([]Vector3, width*height)
+	for y := int32(0); y < height; y++ {
+		y0 := wrapTexel(2*y, src.Height, wrapV)
+		y1 := wrapTexel(2*y+1, src.Height, WrapClamp)
+		for x := int32(0); x < width; x++ {
+			x0 := wrapTexel(2*x, src.Width, wrapU)
+			x1 := wrapTexel(2*x+1, src.Width, WrapClamp)
+			c := AddVector3(src.Image[y0*src.Width+x0], src.Image[y0*src.Width+x1])
+			c = AddVector3(c, AddVector3(src.Image[y1*src.Width+x0], src.Image[y1*src.Width+x1]))
+			image[y*width+x] = MulVector3(0.25, c)
+		}
+	}
+	return MipLevel{width, height, image}
+}
+
+func (mipMap *MipMap) Texel(level, x, y int32) Vector3 {
+	image := &mipMap.Levels[level]
+	x = wrapTexel(x, image.Width, mipMap.WrapU)
+	y = wrapTexel(y, image.Height, mipMap.WrapV)
+	return image.Image[y*image.Width+x]
+}
+
+// Bilinear filters a level at (s, t) in [0 1], the origin is the top left of the image
+func (mipMap *MipMap) Bilinear(level int32, s, t float32) Vector3 {
+	image := &mipMap.Levels[level]
+	x := s*float32(image.Width) - 0.5
+	y := t*float32(image.Height) - 0.5
+	fx := math32.Floor(x)
+	fy := math32.Floor(y)
+	dx := x - fx
+	dy := y - fy
+	ix := int32(fx)
+	iy := int32(fy)
+	c0 := LerpVector3(mipMap.Texel(level, ix, iy), mipMap.Texel(level, ix+1, iy), dx)
+	c1 := LerpVector3(mipMap.Texel(level, ix, iy+1), mipMap.Texel(level, ix+1, iy+1), dx)
+	return LerpVector3(c0, c1, dy)
+}
+
+// Trilinear filters between the two levels around a footprint, width is the footprint in [0 1] of the image
+func (mipMap *MipMap) Trilinear(s, t, width float32) Vector3 {
+	last := int32(len(mipMap.Levels) - 1)
+	texels := width * float32(maxInt32(mipMap.Levels[0].Width, mipMap.Levels[0].Height))
+	if texels <= 1.0 || math32.IsNaN(texels) {
+		return mipMap.Bilinear(0, s, t)
+	}
+	level := math32.Log2(texels)
+	if float32(last) <= level {
+		return mipMap.Bilinear(last, s, t)
+	}
+	l0 := int32(level)
+	return LerpVector3(mipMap.Bilinear(l0, s, t), mipMap.Bilinear(l0+1, s, t), level-float32(l0))
+}
+
+func maxInt32(x, y int32) int32 {
+	if x < y {
+		return y
+	}
+	return x
+}
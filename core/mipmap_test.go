@@ -0,0 +1,40 @@
+package core
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMipMap(t *testing.T) {
+	assert := assert.New(t)
+	image := make([]Vector3, 4*3)
+	for i := range image {
+		v := float32(i & 1)
+		image[i] = Vector3{v, v, v}
+	}
+	mipMap := NewMipMap(4, 3, image, WrapRepeat, WrapRepeat)
+	assert.Equal(3, len(mipMap.Levels))
+	assert.Equal(int32(2), mipMap.Levels[1].Width)
+	assert.Equal(int32(2), mipMap.Levels[1].Height)
+	last := mipMap.Levels[len(mipMap.Levels)-1]
+	assert.Equal(int32(1), last.Width)
+	assert.InDeltaf(0.5, last.Image[0].X, 1.0e-5, "The top level should be the average")
+
+	// Stripes alias without filtering and average with a wide footprint
+	assert.InDeltaf(1.0, mipMap.Trilinear(3.5/4.0, 0.5, 0.0).X, 1.0e-5, "Level 0 at a texel center")
+	assert.InDeltaf(0.5, mipMap.Trilinear(3.5/4.0, 0.5, 1.0).X, 1.0e-5, "Wide footprint should be averaged")
+}
+
+func TestImageTextureFiltered(t *testing.T) {
+	assert := assert.New(t)
+	image := make([]Vector3, 8*8)
+	for i := range image {
+		v := float32(i & 1)
+		image[i] = Vector3{v, v, v}
+	}
+	texture := NewImageTexture(8, 8, image)
+	uv := Vector2{1.5/8.0, 0.5}
+	assert.InDelta(1.0, texture.EvaluateFiltered(uv, Vector2{0.25, 0.0}, Vector2{}, Vector3{}).X, 1.0e-5)
+	texture.GenerateMipMap()
+	assert.InDelta(1.0, texture.EvaluateFiltered(uv, Vector2{}, Vector2{}, Vector3{}).X, 1.0e-5)
+	assert.InDelta(0.5, texture.EvaluateFiltered(uv, Vector2{0.25, 0.0}, Vector2{}, Vector3{}).X, 1.0e-5)
+}
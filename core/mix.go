@@ -0,0 +1,80 @@
+package core
+
+// Mix stochastic blend of two materials, Weight is the probability to select Material1
+//
+// WeightTexture multiplies Weight by its first channel.
+type Mix struct {
+	Material0     Material
+	Material1     Material
+	Weight        float32
+	WeightTexture Texture
+}
+
+func NewMix(material0, material1 Material, weight float32) Mix {
+	return Mix{material0, material1, weight, nil}
+}
+
+func (mix *Mix) Resolve(hitRecord *HitRecord) Material {
+	material0 := mix.Material0.Resolve(hitRecord)
+	material1 := mix.Material1.Resolve(hitRecord)
+	if mix.WeightTexture == nil && material0 == mix.Material0 && material1 == mix.Material1 {
+		return mix
+	}
+	return &Mix{material0, material1, ModulateScalar(mix.Weight, mix.WeightTexture, hitRecord), nil}
+}
+
+func (mix *Mix) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	weight := Saturate32(mix.Weight)
+	var selected Material
+	if eta0 < weight {
+		selected = mix.Material1
+		eta0 = eta0 / weight
+	} else {
+		selected = mix.Material0
+		eta0 = (eta0 - weight) / (1.0 - weight)
+	}
+	eta0 = Saturate32(eta0)
+	if 1.0 <= eta0 {
+		eta0 = 1.0 - Epsilon32
+	}
+	materialSample := selected.Sample(wi, eta0, eta1)
+	if !materialSample.Continue {
+		return materialSample
+	}
+	// Delta directions can not be evaluated by the other, and samples without a density like of Layered
+	// keep their own weight, the selection probability cancels out
+	if materialSample.PDF <= 0.0 || selected.Pdf(wi, materialSample.Scattered) <= 0.0 {
+		return materialSample
+	}
+	pdf := mix.Pdf(wi, materialSample.Scattered)
+	if pdf <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	return MaterialSample{true, pdf, DivVector3(mix.Eval(wi, materialSample.Scattered), pdf), materialSample.Scattered}
+}
+
+func (mix *Mix) Eval(wi, wo Vector3) Vector3 {
+	weight := Saturate32(mix.Weight)
+	return LerpVector3(mix.Material0.Eval(wi, wo), mix.Material1.Eval(wi, wo), weight)
+}
+
+func (mix *Mix) Pdf(wi, wo Vector3) float32 {
+	weight := Saturate32(mix.Weight)
+	return Lerp32(mix.Material0.Pdf(wi, wo), mix.Material1.Pdf(wi, wo), weight)
+}
+
+func (mix *Mix) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return scatterBySample(mix, ray, hitRecord, attenuation, scattered)
+}
+
+func (mix *Mix) GetRoughness() float32 {
+	return Lerp32(mix.Material0.GetRoughness(), mix.Material1.GetRoughness(), Saturate32(mix.Weight))
+}
+
+func (mix *Mix) GetMetallic() float32 {
+	return Lerp32(mix.Material0.GetMetallic(), mix.Material1.GetMetallic(), Saturate32(mix.Weight))
+}
+
+func (mix *Mix) GetAlbedo() Vector3 {
+	return LerpVector3(mix.Material0.GetAlbedo(), mix.Material1.GetAlbedo(), Saturate32(mix.Weight))
+}
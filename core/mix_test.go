@@ -0,0 +1,43 @@
+package core
+import (
+	"testing"
+	"math/rand"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMixEval(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(8)
+	lambertian := NewLambertian(Vector3{0.8, 0.8, 0.8})
+	metal := NewMetal(Vector3{0.9, 0.6, 0.3}, 0.3, 1.0, 0.9)
+	mix := NewMix(&lambertian, &metal, 0.25)
+	wi := NormalizeVector3(Vector3{0.2, 0.3, 0.9})
+	for i:=0; i<100; i++ {
+		sample := mix.Sample(wi, rand.Float32(), rand.Float32())
+		if !sample.Continue {
+			continue
+		}
+		expected := LerpVector3(lambertian.Eval(wi, sample.Scattered), metal.Eval(wi, sample.Scattered), 0.25)
+		assert.Truef(EqualVector3(expected, mix.Eval(wi, sample.Scattered)), "Eval should be blended")
+		pdf := mix.Pdf(wi, sample.Scattered)
+		assert.InDeltaf(1.0, sample.PDF/pdf, 1.0e-3, "PDF of sample %v should be %v", sample.PDF, pdf)
+	}
+	assert.Truef(Equal32(Lerp32(1.0, 0.3, 0.25), mix.GetRoughness()), "Roughness should be blended")
+}
+
+func TestMixLayered(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(9)
+	base := NewLambertian(Vector3{0.8, 0.8, 0.8})
+	layered := NewLayered(&base, 0.3, 1.5)
+	lambertian := NewLambertian(Vector3{0.5, 0.5, 0.5})
+	mix := NewMix(&lambertian, &layered, 1.0)
+	wi := NormalizeVector3(Vector3{0.2, 0.3, 0.9})
+	for i:=0; i<100; i++ {
+		// The walk of Layered keeps its weight instead of a ratio of estimates
+		sample := mix.Sample(wi, rand.Float32(), rand.Float32())
+		if sample.Continue {
+			assert.Equal(float32(0.0), sample.PDF)
+		}
+	}
+}
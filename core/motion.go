@@ -0,0 +1,217 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"sort"
+)
+
+// motionSubdivisions number of samples between keyframes to bound the motion of instances
+const motionSubdivisions int = 8
+
+// MotionBounded is a moving Hittable which bounds its motion over a time interval by two boxes at the ends,
+// the linear interpolation of the boxes contains the object at all times in the interval
+type MotionBounded interface {
+	MotionBounds(time0, time1 float32) (AABB, AABB)
+}
+
+// motionBoundsOf returns the bounds at the ends of a time interval, static objects are bounded by the same box
+func motionBoundsOf(hittable Hittable, time0, time1 float32) (AABB, AABB) {
+	if moving, ok := hittable.(MotionBounded); ok {
+		return moving.MotionBounds(time0, time1)
+	}
+	box := hittable.BoundingBox()
+	return box, box
+}
+
+func lerpAABB(box0, box1 AABB, t float32) AABB {
+	return AABB{LerpVector3(box0.Min, box1.Min, t), LerpVector3(box0.Max, box1.Max, t)}
+}
+
+// fitMotionBounds bounds the motion by the boxes at the ends of the interval,
+// then grows them until the interpolation contains the boxes at the times where the motion changes
+func fitMotionBounds(boundsAt func(float32) AABB, times []float32, time0, time1 float32) (AABB, AABB) {
+	box0 := boundsAt(time0)
+	box1 := boundsAt(time1)
+	if time1 <= time0 {
+		return box0, box0
+	}
+	for _, time := range times {
+		if time <= time0 || time1 <= time {
+			continue
+		}
+		box := boundsAt(time)
+		interpolated := lerpAABB(box0, box1, (time-time0)/(time1-time0))
+		lower := maxVector3(SubVector3(interpolated.Min, box.Min), Vector3{})
+		upper := maxVector3(SubVector3(box.Max, interpolated.Max), Vector3{})
+		box0 = AABB{SubVector3(box0.Min, lower), AddVector3(box0.Max, upper)}
+		box1 = AABB{SubVector3(box1.Min, lower), AddVector3(box1.Max, upper)}
+	}
+	return box0, box1
+}
+
+// MovingSphere sphere whose center moves linearly from Center0 at Time0 to Center1 at Time1,
+// it stays at the ends out of the interval
+type MovingSphere struct {
+	Center0  Vector3
+	Center1  Vector3
+	Time0    float32
+	Time1    float32
+	Radius   float32
+	Material Material
+}
+
+func NewMovingSphere(center0, center1 Vector3, time0, time1, radius float32, material Material) MovingSphere {
+	return MovingSphere{center0, center1, time0, time1, radius, material}
+}
+
+func (sphere *MovingSphere) CenterAt(time float32) Vector3 {
+	if sphere.Time1 <= sphere.Time0 {
+		return sphere.Center0
+	}
+	t := Clamp0132((time - sphere.Time0) / (sphere.Time1 - sphere.Time0))
+	return LerpVector3(sphere.Center0, sphere.Center1, t)
+}
+
+func (sphere *MovingSphere) at(time float32) Sphere {
+	return Sphere{sphere.CenterAt(time), sphere.Radius, sphere.Material}
+}
+
+func (sphere *MovingSphere) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	s := sphere.at(ray.Time)
+	return s.Hit(ray, tmin, tmax, record)
+}
+
+func (sphere *MovingSphere) BoundingBox() AABB {
+	s0 := sphere.at(sphere.Time0)
+	s1 := sphere.at(sphere.Time1)
+	return UnionAABB(s0.BoundingBox(), s1.BoundingBox())
+}
+
+func (sphere *MovingSphere) MotionBounds(time0, time1 float32) (AABB, AABB) {
+	boundsAt := func(time float32) AABB {
+		s := sphere.at(time)
+		return s.BoundingBox()
+	}
+	// The motion is linear except where it starts and stops
+	return fitMotionBounds(boundsAt, []float32{sphere.Time0, sphere.Time1}, time0, time1)
+}
+
+// Keyframe pose of a MotionInstance at a time, which scales, rotates then translates
+type Keyframe struct {
+	Time        float32
+	Translation Vector3
+	Rotation    Quaternion
+	Scale       Vector3
+}
+
+func NewKeyframe(time float32, translation Vector3, rotation Quaternion, scale Vector3) Keyframe {
+	return Keyframe{time, translation, NormalizeQuaternion(rotation), scale}
+}
+
+func (keyframe *Keyframe) Transform() Transform {
+	rotation := keyframe.Rotation.Matrix4()
+	return ComposeTransform(Translate(keyframe.Translation), ComposeTransform(Transform{rotation, TransposeMatrix4(rotation)}, Scale(keyframe.Scale)))
+}
+
+// MotionInstance instance whose transform is animated by keyframes
+//
+// The translation and scale are interpolated linearly and the rotation by slerp, so that the object turns at a constant rate.
+// The transform is held at the first and the last keyframes out of their times.
+type MotionInstance struct {
+	Object    Hittable
+	Keyframes []Keyframe
+}
+
+// NewMotionInstance returns an instance with the keyframes sorted by time
+func NewMotionInstance(object Hittable, keyframes []Keyframe) MotionInstance {
+	sorted := append([]Keyframe{}, keyframes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time < sorted[j].Time })
+	return MotionInstance{object, sorted}
+}
+
+// KeyframeAt interpolates the keyframes at a time
+func (instance *MotionInstance) KeyframeAt(time float32) Keyframe {
+	keyframes := instance.Keyframes
+	if 0 == len(keyframes) {
+		return Keyframe{time, Vector3{}, IdentityQuaternion(), Vector3{1.0, 1.0, 1.0}}
+	}
+	if time <= keyframes[0].Time {
+		return keyframes[0]
+	}
+	last := len(keyframes) - 1
+	if keyframes[last].Time <= time {
+		return keyframes[last]
+	}
+	i := sort.Search(len(keyframes), func(i int) bool { return time < keyframes[i].Time }) - 1
+	k0 := &keyframes[i]
+	k1 := &keyframes[i+1]
+	t := (time - k0.Time) / (k1.Time - k0.Time)
+	return Keyframe{
+		time,
+		LerpVector3(k0.Translation, k1.Translation, t),
+		SlerpQuaternion(k0.Rotation, k1.Rotation, t),
+		LerpVector3(k0.Scale, k1.Scale, t)}
+}
+
+func (instance *MotionInstance) TransformAt(time float32) Transform {
+	keyframe := instance.KeyframeAt(time)
+	return keyframe.Transform()
+}
+
+func (instance *MotionInstance) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	static := Instance{instance.Object, instance.TransformAt(ray.Time)}
+	return static.Hit(ray, tmin, tmax, record)
+}
+
+func (instance *MotionInstance) BoundingBox() AABB {
+	if 0 == len(instance.Keyframes) {
+		return instance.Object.BoundingBox()
+	}
+	box0, box1 := instance.MotionBounds(instance.Keyframes[0].Time, instance.Keyframes[len(instance.Keyframes)-1].Time)
+	return UnionAABB(box0, box1)
+}
+
+// MotionBounds bounds the transformed box of the object at the keyframes and the subdivisions between them.
+// The boxes are grown by the sagitta of the largest rotation between the samples to cover the arcs.
+func (instance *MotionInstance) MotionBounds(time0, time1 float32) (AABB, AABB) {
+	box := instance.Object.BoundingBox()
+	boundsAt := func(time float32) AABB {
+		transform := instance.TransformAt(time)
+		return transform.AABB(box)
+	}
+	times := []float32{time0}
+	for i := 0; i+1 < len(instance.Keyframes); i++ {
+		k0 := instance.Keyframes[i].Time
+		k1 := instance.Keyframes[i+1].Time
+		for j := 0; j <= motionSubdivisions; j++ {
+			time := k0 + (k1-k0)*float32(j)/float32(motionSubdivisions)
+			if time0 < time && time < time1 {
+				times = append(times, time)
+			}
+		}
+	}
+	times = append(times, time1)
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	box0, box1 := fitMotionBounds(boundsAt, times, time0, time1)
+	if box.IsEmpty() {
+		return box0, box1
+	}
+
+	// The largest distance of the object from the center of the rotation
+	corner := maxVector3(Vector3{math32.Abs(box.Min.X), math32.Abs(box.Min.Y), math32.Abs(box.Min.Z)}, Vector3{math32.Abs(box.Max.X), math32.Abs(box.Max.Y), math32.Abs(box.Max.Z)})
+	radius := float32(0.0)
+	angle := float32(0.0)
+	for i := 0; i < len(times); i++ {
+		keyframe := instance.KeyframeAt(times[i])
+		radius = math32.Max(radius, corner.Length()*MaxElementVector3(Vector3{math32.Abs(keyframe.Scale.X), math32.Abs(keyframe.Scale.Y), math32.Abs(keyframe.Scale.Z)}))
+		if 0 < i {
+			previous := instance.KeyframeAt(times[i-1])
+			angle = math32.Max(angle, AngleQuaternion(previous.Rotation, keyframe.Rotation))
+		}
+	}
+	sagitta := radius * (1.0 - math32.Cos(0.5*angle))
+	margin := Vector3{sagitta, sagitta, sagitta}
+	box0 = AABB{SubVector3(box0.Min, margin), AddVector3(box0.Max, margin)}
+	box1 = AABB{SubVector3(box1.Min, margin), AddVector3(box1.Max, margin)}
+	return box0, box1
+}
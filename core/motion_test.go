@@ -0,0 +1,110 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"git.maze.io/go/math32"
+	"math/rand"
+	"testing"
+)
+
+func TestQuaternion(t *testing.T) {
+	assert := assert.New(t)
+	axis := Vector3{1.0, 2.0, 3.0}
+	q := NewQuaternionAxisAngle(axis, 0.8)
+	m0 := q.Matrix4()
+	m1 := RotateMatrix4(axis, 0.8)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			assert.InDelta(m1[i][j], m0[i][j], 1.0e-5)
+		}
+	}
+	half := SlerpQuaternion(IdentityQuaternion(), NewQuaternionAxisAngle(axis, 1.6), 0.5)
+	assert.InDelta(0.0, AngleQuaternion(half, q), 1.0e-3)
+	twice := MulQuaternion(q, q)
+	assert.InDelta(1.6, AngleQuaternion(IdentityQuaternion(), twice), 1.0e-4)
+}
+
+func TestCameraShutter(t *testing.T) {
+	assert := assert.New(t)
+	camera := NewCameraPerspectiveFov(100, 100, DegToRad32*45.0)
+	ray := camera.GenerateRay(10, 20, Sample2{0.5, 0.5}, Sample2{0.5, 0.5}, 0.5)
+	assert.Equal(float32(0.0), ray.Time)
+	camera.SetShutter(1.0, 3.0)
+	ray = camera.GenerateRay(10, 20, Sample2{0.5, 0.5}, Sample2{0.5, 0.5}, 0.25)
+	assert.InDelta(1.5, ray.Time, 1.0e-6)
+}
+
+func TestMovingSphere(t *testing.T) {
+	assert := assert.New(t)
+	sphere := NewMovingSphere(Vector3{-2.0, 0.0, 0.0}, Vector3{2.0, 0.0, 0.0}, 0.0, 1.0, 0.5, &Lambertian{Vector3{0.5, 0.5, 0.5}, nil})
+	record := HitRecord{}
+	assert.True(sphere.Hit(Ray{Vector3{-2.0, 0.0, 5.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &record))
+	assert.False(sphere.Hit(Ray{Vector3{-2.0, 0.0, 5.0}, Vector3{0.0, 0.0, -1.0}, 1.0}, 0.001, Infinity32, &record))
+	assert.True(sphere.Hit(Ray{Vector3{2.0, 0.0, 5.0}, Vector3{0.0, 0.0, -1.0}, 2.0}, 0.001, Infinity32, &record))
+
+	// The motion stops at Time1, so the bounds of a longer interval have to cover the stop
+	box0, box1 := sphere.MotionBounds(0.0, 2.0)
+	halfway := lerpAABB(box0, box1, 0.5)
+	assert.True(halfway.Max.X >= 2.5-1.0e-5)
+}
+
+func TestMotionInstance(t *testing.T) {
+	assert := assert.New(t)
+	box := NewBox(Vector3{-0.5, -0.5, -0.5}, Vector3{0.5, 0.5, 0.5}, &Lambertian{Vector3{0.5, 0.5, 0.5}, nil})
+	instance := NewMotionInstance(&box, []Keyframe{
+		NewKeyframe(1.0, Vector3{4.0, 0.0, 0.0}, NewQuaternionAxisAngle(Vector3{0.0, 1.0, 0.0}, 0.5*math32.Pi), Vector3{1.0, 1.0, 1.0}),
+		NewKeyframe(0.0, Vector3{}, IdentityQuaternion(), Vector3{1.0, 1.0, 1.0}),
+	})
+	assert.Equal(float32(0.0), instance.Keyframes[0].Time)
+	transform := instance.TransformAt(0.5)
+	p := transform.Point(Vector3{1.0, 0.0, 0.0})
+	assert.InDelta(2.0+math32.Cos(0.25*math32.Pi), p.X, 1.0e-4)
+	assert.InDelta(-math32.Sin(0.25*math32.Pi), p.Z, 1.0e-4)
+
+	record := HitRecord{}
+	assert.True(instance.Hit(Ray{Vector3{4.0, 0.0, 5.0}, Vector3{0.0, 0.0, -1.0}, 1.0}, 0.001, Infinity32, &record))
+	assert.InDelta(4.5, record.T, 1.0e-4)
+	assert.False(instance.Hit(Ray{Vector3{4.0, 0.0, 5.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &record))
+
+	// The box is rotated by 45 degrees halfway, the corner sticks out of the boxes at the keyframes
+	box0, box1 := instance.MotionBounds(0.0, 1.0)
+	halfway := lerpAABB(box0, box1, 0.5)
+	assert.True(halfway.Max.Z >= 0.5*math32.Sqrt(2.0)-1.0e-4)
+}
+
+func TestMotionBVHMatchesList(t *testing.T) {
+	assert := assert.New(t)
+	random := rand.New(rand.NewSource(2))
+	material := &Lambertian{Vector3{0.5, 0.5, 0.5}, nil}
+	list := NewHittableList()
+	hittables := []Hittable{}
+	for i := 0; i < 100; i++ {
+		center := Vector3{10.0*random.Float32() - 5.0, 10.0*random.Float32() - 5.0, 10.0*random.Float32() - 5.0}
+		velocity := Vector3{2.0*random.Float32() - 1.0, 2.0*random.Float32() - 1.0, 2.0*random.Float32() - 1.0}
+		sphere := NewMovingSphere(center, AddVector3(center, velocity), 0.0, 1.0, 0.2, material)
+		list.AddHittable(&sphere)
+		hittables = append(hittables, &sphere)
+	}
+	for i := 0; i < 20; i++ {
+		box := NewBox(Vector3{-0.3, -0.3, -0.3}, Vector3{0.3, 0.3, 0.3}, material)
+		center := Vector3{10.0*random.Float32() - 5.0, 10.0*random.Float32() - 5.0, 10.0*random.Float32() - 5.0}
+		instance := NewMotionInstance(&box, []Keyframe{
+			NewKeyframe(0.0, center, IdentityQuaternion(), Vector3{1.0, 1.0, 1.0}),
+			NewKeyframe(1.0, center, NewQuaternionAxisAngle(Vector3{1.0, 1.0, 0.0}, 2.0), Vector3{2.0, 1.0, 1.0}),
+		})
+		list.AddHittable(&instance)
+		hittables = append(hittables, &instance)
+	}
+	bvh := NewMotionBVH(hittables, nil, 0.0, 1.0)
+	for i := 0; i < 1000; i++ {
+		origin := Vector3{20.0*random.Float32() - 10.0, 20.0*random.Float32() - 10.0, 20.0*random.Float32() - 10.0}
+		direction := RandomOnSphere(random.Float32(), random.Float32())
+		ray := Ray{origin, direction, random.Float32()}
+		expected := HitRecord{}
+		actual := HitRecord{}
+		hit := list.Hit(ray, 0.001, Infinity32, &expected)
+		assert.Equal(hit, bvh.Hit(ray, 0.001, Infinity32, &actual))
+		if hit {
+			assert.InDelta(expected.T, actual.T, 1.0e-4)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// NestedDielectric is implemented by dielectrics which bound volumes nested in or touching each other
+//
+// GetIOR is the absolute index of the volume, Relative returns the material whose index is relative to outside.
+type NestedDielectric interface {
+	Material
+	GetPriority() int32
+	GetIOR() float32
+	GetAbsorption() Vector3
+	Relative(outside float32) Material
+}
+
+// MaterialWrapper is implemented by materials which modify the surface of another material, like NormalMapped
+type MaterialWrapper interface {
+	Unwrap() Material
+}
+
+// NestedVolume returns the nested dielectric under the wrappers of a material, which identifies its volume
+func NestedVolume(material Material) (NestedDielectric, bool) {
+	for {
+		if volume, ok := material.(NestedDielectric); ok {
+			return volume, true
+		}
+		wrapper, ok := material.(MaterialWrapper)
+		if !ok {
+			return nil, false
+		}
+		material = wrapper.Unwrap()
+	}
+}
+
+// DielectricStack dielectric volumes which contain the current position of a path
+//
+// The volume of the highest priority determines the index and the absorption, the later entered one wins on a tie.
+// The surfaces of lower priority volumes inside it are false intersections which rays pass through straight.
+// Charles M. Schmidt, Brian Budge, "Simple Nested Dielectrics in Ray Traced Images", Journal of Graphics Tools 2002
+type DielectricStack struct {
+	Volumes []NestedDielectric
+}
+
+func (stack *DielectricStack) find(volume NestedDielectric) int {
+	for i := len(stack.Volumes) - 1; 0 <= i; i-- {
+		if stack.Volumes[i] == volume {
+			return i
+		}
+	}
+	return -1
+}
+
+// top returns the highest priority volume except exclude, nil if none
+func (stack *DielectricStack) top(exclude NestedDielectric) NestedDielectric {
+	var top NestedDielectric
+	for _, volume := range stack.Volumes {
+		if volume == exclude {
+			continue
+		}
+		if top == nil || top.GetPriority() <= volume.GetPriority() {
+			top = volume
+		}
+	}
+	return top
+}
+
+// IOR returns the index of the current volume, 1 for the outside of all volumes
+func (stack *DielectricStack) IOR() float32 {
+	top := stack.top(nil)
+	if top == nil {
+		return 1.0
+	}
+	return top.GetIOR()
+}
+
+// Transmittance returns the Beer-Lambert transmittance of the current volume over distance
+func (stack *DielectricStack) Transmittance(distance float32) Vector3 {
+	top := stack.top(nil)
+	if top == nil {
+		return Vector3{1.0, 1.0, 1.0}
+	}
+	absorption := top.GetAbsorption()
+	return Vector3{
+		math32.Exp(-absorption.X * distance),
+		math32.Exp(-absorption.Y * distance),
+		math32.Exp(-absorption.Z * distance)}
+}
+
+// Outside returns the index on the other side of a surface of volume, false if the surface is a false intersection
+func (stack *DielectricStack) Outside(volume NestedDielectric) (float32, bool) {
+	other := stack.top(volume)
+	if other == nil {
+		return 1.0, true
+	}
+	if volume.GetPriority() < other.GetPriority() {
+		return other.GetIOR(), false
+	}
+	return other.GetIOR(), true
+}
+
+// Cross updates the stack after a ray crosses or reflects at a surface of volume, normal is the outward geometric normal
+func (stack *DielectricStack) Cross(volume NestedDielectric, direction, normal Vector3) {
+	index := stack.find(volume)
+	if DotVector3(direction, normal) < 0.0 {
+		if index < 0 {
+			stack.Volumes = append(stack.Volumes, volume)
+		}
+		return
+	}
+	if 0 <= index {
+		stack.Volumes = append(stack.Volumes[:index], stack.Volumes[index+1:]...)
+	}
+}
@@ -0,0 +1,69 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"git.maze.io/go/math32"
+	"testing"
+)
+
+func TestDielectricStack(t *testing.T) {
+	assert := assert.New(t)
+	glass := &Dielectric{Albedo: Vector3{1.0, 1.0, 1.0}, RefIndex: 1.5, Priority: 2}
+	water := &Dielectric{Albedo: Vector3{1.0, 1.0, 1.0}, RefIndex: 1.33, Priority: 1, Absorption: Vector3{0.5, 0.0, 0.0}}
+	down := Vector3{0.0, -1.0, 0.0}
+	up := Vector3{0.0, 1.0, 0.0}
+	stack := DielectricStack{}
+	assert.Equal(float32(1.0), stack.IOR())
+
+	// Enter the glass from the air
+	outside, valid := stack.Outside(glass)
+	assert.True(valid)
+	assert.Equal(float32(1.0), outside)
+	stack.Cross(glass, down, up)
+	assert.Equal(float32(1.5), stack.IOR())
+
+	// The water surface inside the glass wall is a false intersection
+	_, valid = stack.Outside(water)
+	assert.False(valid)
+	stack.Cross(water, down, up)
+	assert.Equal(float32(1.5), stack.IOR())
+
+	// Leave the glass wall into the water
+	outside, valid = stack.Outside(glass)
+	assert.True(valid)
+	assert.Equal(float32(1.33), outside)
+	stack.Cross(glass, down, down)
+	assert.Equal(float32(1.33), stack.IOR())
+	assert.InDelta(math32.Exp(-1.0), stack.Transmittance(2.0).X, 1.0e-5)
+	assert.InDelta(1.0, stack.Transmittance(2.0).Y, 1.0e-5)
+
+	// Reflection keeps the stack
+	stack.Cross(water, up, down)
+	assert.Equal(1, len(stack.Volumes))
+
+	// Wrapped surfaces are the same volume
+	mapped := NewNormalMapped(glass, nil)
+	masked := NewAlphaMasked(&mapped, nil)
+	volume, nested := NestedVolume(&masked)
+	assert.True(nested)
+	assert.Equal(NestedDielectric(glass), volume)
+	_, nested = NestedVolume(&Lambertian{Albedo: Vector3{0.5, 0.5, 0.5}})
+	assert.False(nested)
+}
+
+func TestDielectricSampleInside(t *testing.T) {
+	assert := assert.New(t)
+	dielectric := &Dielectric{Albedo: Vector3{1.0, 1.0, 1.0}, RefIndex: 1.5}
+	// Beyond the critical angle from the inside
+	wi := NormalizeVector3(Vector3{0.9, 0.0, -0.2})
+	sample := dielectric.Sample(wi, 0.5, 0.5)
+	assert.True(sample.Continue)
+	assert.Truef(sample.Scattered.Z < 0.0, "Total internal reflection should stay inside")
+
+	// Index matched interfaces pass straight
+	matched := dielectric.Relative(1.5)
+	wi = NormalizeVector3(Vector3{0.3, 0.0, 0.8})
+	for i := 0; i < 16; i++ {
+		sample = matched.Sample(wi, 0.5, 0.5)
+		assert.True(EqualVector3(wi.Minus(), sample.Scattered))
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"math/rand"
+)
+
+var noisePermutation = func() [512]int32 {
+	var permutation [512]int32
+	random := rand.New(rand.NewSource(0))
+	p := random.Perm(256)
+	for i := 0; i < 256; i++ {
+		permutation[i] = int32(p[i])
+		permutation[i+256] = int32(p[i])
+	}
+	return permutation
+}()
+
+func noiseHash(x, y, z int32) int32 {
+	return noisePermutation[noisePermutation[noisePermutation[x&255]+(y&255)]+(z&255)]
+}
+
+func noiseFade(t float32) float32 {
+	return t * t * t * (t*(t*6.0-15.0) + 10.0)
+}
+
+func noiseGradient(hash int32, x, y, z float32) float32 {
+	h := hash & 15
+	var u, v float32
+	if h < 8 {
+		u = x
+	} else {
+		u = y
+	}
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	} else {
+		v = z
+	}
+	if 0 != (h & 1) {
+		u = -u
+	}
+	if 0 != (h & 2) {
+		v = -v
+	}
+	return u + v
+}
+
+// Perlin improved noise in [-1 1]
+//
+// Ken Perlin, "Improving Noise", SIGGRAPH 2002
+func Perlin(p Vector3) float32 {
+	fx := math32.Floor(p.X)
+	fy := math32.Floor(p.Y)
+	fz := math32.Floor(p.Z)
+	ix := int32(fx)
+	iy := int32(fy)
+	iz := int32(fz)
+	x := p.X - fx
+	y := p.Y - fy
+	z := p.Z - fz
+	u := noiseFade(x)
+	v := noiseFade(y)
+	w := noiseFade(z)
+
+	x00 := Lerp32(noiseGradient(noiseHash(ix, iy, iz), x, y, z), noiseGradient(noiseHash(ix+1, iy, iz), x-1.0, y, z), u)
+	x10 := Lerp32(noiseGradient(noiseHash(ix, iy+1, iz), x, y-1.0, z), noiseGradient(noiseHash(ix+1, iy+1, iz), x-1.0, y-1.0, z), u)
+	x01 := Lerp32(noiseGradient(noiseHash(ix, iy, iz+1), x, y, z-1.0), noiseGradient(noiseHash(ix+1, iy, iz+1), x-1.0, y, z-1.0), u)
+	x11 := Lerp32(noiseGradient(noiseHash(ix, iy+1, iz+1), x, y-1.0, z-1.0), noiseGradient(noiseHash(ix+1, iy+1, iz+1), x-1.0, y-1.0, z-1.0), u)
+	return Lerp32(Lerp32(x00, x10, v), Lerp32(x01, x11, v), w)
+}
+
+// FBm fractional Brownian motion of Perlin noise, the result is normalized in [-1 1]
+func FBm(p Vector3, octaves int32, lacunarity, gain float32) float32 {
+	total := float32(0.0)
+	amplitude := float32(1.0)
+	sum := float32(0.0)
+	for i := int32(0); i < octaves; i++ {
+		total += amplitude * Perlin(p)
+		sum += amplitude
+		amplitude *= gain
+		p = MulVector3(lacunarity, p)
+	}
+	if sum <= 0.0 {
+		return 0.0
+	}
+	return total / sum
+}
+
+// Worley distance to the nearest feature point in [0 1], one feature point is placed in each cell
+//
+// Steven Worley, "A Cellular Texture Basis Function", SIGGRAPH 1996
+func Worley(p Vector3) float32 {
+	fx := math32.Floor(p.X)
+	fy := math32.Floor(p.Y)
+	fz := math32.Floor(p.Z)
+	ix := int32(fx)
+	iy := int32(fy)
+	iz := int32(fz)
+	minDistance := float32(Infinity32)
+	for k := int32(-1); k <= 1; k++ {
+		for j := int32(-1); j <= 1; j++ {
+			for i := int32(-1); i <= 1; i++ {
+				h := noiseHash(ix+i, iy+j, iz+k)
+				feature := Vector3{
+					fx + float32(i) + float32(h)/255.0,
+					fy + float32(j) + float32(noiseHash(h, iy+j, iz+k))/255.0,
+					fz + float32(k) + float32(noiseHash(h, h, iz+k))/255.0}
+				d := SubVector3(feature, p)
+				minDistance = math32.Min(minDistance, d.LengthSqr())
+			}
+		}
+	}
+	return Saturate32(math32.Sqrt(minDistance))
+}
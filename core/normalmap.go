@@ -0,0 +1,116 @@
+package core
+
+// NormalPerturber is implemented by materials which perturb the shading normal at a hit
+type NormalPerturber interface {
+	PerturbNormal(hitRecord *HitRecord)
+}
+
+// NewShadingCoordinate perturbs the shading normal of a hit by its material, then builds the shading frame
+func NewShadingCoordinate(hitRecord *HitRecord) Coordinate {
+	if perturber, ok := hitRecord.Material.(NormalPerturber); ok {
+		perturber.PerturbNormal(hitRecord)
+	}
+	return NewCoordinateTangent(hitRecord.Normal, hitRecord.Tangent)
+}
+
+// LeaksLight reports whether a direction is on the other side of the geometric surface than of the shading surface
+//
+// A perturbed shading normal can accept directions under the geometric surface, which makes light leak through it.
+func LeaksLight(hitRecord *HitRecord, world, local Vector3) bool {
+	return DotVector3(world, hitRecord.GeometricNormal)*local.Z <= 0.0
+}
+
+// NormalMapped perturbs the shading normal of a material by a tangent space normal map or a height map
+//
+// NormalTexture is encoded as 2*color-1 in the frame of (tangent, normal x tangent, normal),
+// Strength scales its tangential part. BumpTexture is a height in the first channel, scaled by BumpScale
+// and differentiated by finite differences of BumpDelta in UV.
+type NormalMapped struct {
+	Material      Material
+	NormalTexture Texture
+	Strength      float32
+	BumpTexture   Texture
+	BumpScale     float32
+	BumpDelta     float32
+}
+
+func NewNormalMapped(material Material, normalTexture Texture) NormalMapped {
+	return NormalMapped{material, normalTexture, 1.0, nil, 1.0, 1.0 / 1024.0}
+}
+
+func NewBumpMapped(material Material, bumpTexture Texture, scale float32) NormalMapped {
+	return NormalMapped{material, nil, 1.0, bumpTexture, scale, 1.0 / 1024.0}
+}
+
+func (mapped *NormalMapped) PerturbNormal(hitRecord *HitRecord) {
+	normal := hitRecord.Normal
+	coordinate := NewCoordinateTangent(normal, hitRecord.Tangent)
+	if nil != mapped.BumpTexture {
+		delta := mapped.BumpDelta
+		// The three heights share the footprint of the hit, so that the filtering does not add a false slope
+		height := func(uv Vector2) float32 {
+			return evaluateFiltered(mapped.BumpTexture, uv, hitRecord.DUVDX, hitRecord.DUVDY, hitRecord.Position).X
+		}
+		h := height(hitRecord.UV)
+		hu := height(AddVector2(hitRecord.UV, Vector2{delta, 0.0}))
+		hv := height(AddVector2(hitRecord.UV, Vector2{0.0, delta}))
+		dhdu := mapped.BumpScale * (hu - h) / delta
+		dhdv := mapped.BumpScale * (hv - h) / delta
+		normal = NormalizeVector3(coordinate.LocalToWorld(Vector3{-dhdu, -dhdv, 1.0}))
+		coordinate = NewCoordinateTangent(normal, hitRecord.Tangent)
+	}
+	if nil != mapped.NormalTexture {
+		c := EvaluateTexture(mapped.NormalTexture, hitRecord)
+		local := Vector3{mapped.Strength * (2.0*c.X - 1.0), mapped.Strength * (2.0*c.Y - 1.0), 2.0*c.Z - 1.0}
+		if local.LengthSqr() <= Epsilon32 {
+			local = Vector3{0.0, 0.0, 1.0}
+		}
+		normal = NormalizeVector3(coordinate.LocalToWorld(local))
+	}
+
+	// Keep the shading normal on the same side of the surface as the geometric normal
+	geometric := hitRecord.GeometricNormal
+	if cosine := DotVector3(normal, geometric); cosine < Epsilon32 {
+		normal = NormalizeVector3(AddVector3(normal, MulVector3(Epsilon32-cosine, geometric)))
+	}
+	hitRecord.Normal = normal
+}
+
+func (mapped *NormalMapped) Unwrap() Material {
+	return mapped.Material
+}
+
+func (mapped *NormalMapped) Resolve(hitRecord *HitRecord) Material {
+	return mapped.Material.Resolve(hitRecord)
+}
+
+func (mapped *NormalMapped) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	return mapped.Material.Sample(wi, eta0, eta1)
+}
+
+func (mapped *NormalMapped) Eval(wi, wo Vector3) Vector3 {
+	return mapped.Material.Eval(wi, wo)
+}
+
+func (mapped *NormalMapped) Pdf(wi, wo Vector3) float32 {
+	return mapped.Material.Pdf(wi, wo)
+}
+
+func (mapped *NormalMapped) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	record := *hitRecord
+	mapped.PerturbNormal(&record)
+	record.Material = mapped.Material
+	return mapped.Material.Scatter(ray, &record, attenuation, scattered)
+}
+
+func (mapped *NormalMapped) GetRoughness() float32 {
+	return mapped.Material.GetRoughness()
+}
+
+func (mapped *NormalMapped) GetMetallic() float32 {
+	return mapped.Material.GetMetallic()
+}
+
+func (mapped *NormalMapped) GetAlbedo() Vector3 {
+	return mapped.Material.GetAlbedo()
+}
@@ -0,0 +1,78 @@
+package core
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// rampTexture height increasing along U
+type rampTexture struct {
+}
+
+func (texture *rampTexture) Evaluate(uv Vector2, position Vector3) Vector3 {
+	return Vector3{uv.X, uv.X, uv.X}
+}
+
+// blurredRampTexture is a ramp whose filtered lookups are flattened and offset, like a coarse mip level
+type blurredRampTexture struct {
+	rampTexture
+}
+
+func (texture *blurredRampTexture) EvaluateFiltered(uv, duvdx, duvdy Vector2, position Vector3) Vector3 {
+	h := 0.5*uv.X + 0.25
+	return Vector3{h, h, h}
+}
+
+func newMappedHitRecord(material Material) HitRecord {
+	normal := Vector3{0.0, 0.0, 1.0}
+	return HitRecord{Normal: normal, GeometricNormal: normal, Tangent: Vector3{1.0, 0.0, 0.0}, UV: Vector2{0.5, 0.5}, Material: material}
+}
+
+func TestNormalMap(t *testing.T) {
+	assert := assert.New(t)
+	lambertian := NewLambertian(Vector3{0.5, 0.5, 0.5})
+
+	flat := NewNormalMapped(&lambertian, NewConstantTexture(Vector3{0.5, 0.5, 1.0}))
+	hitRecord := newMappedHitRecord(&flat)
+	NewShadingCoordinate(&hitRecord)
+	assert.Truef(EqualVector3(Vector3{0.0, 0.0, 1.0}, hitRecord.Normal), "Flat normal map should keep the normal %v", hitRecord.Normal)
+
+	tilted := NewNormalMapped(&lambertian, NewConstantTexture(Vector3{1.0, 0.5, 1.0}))
+	hitRecord = newMappedHitRecord(&tilted)
+	coordinate := NewShadingCoordinate(&hitRecord)
+	assert.InDeltaf(0.7071068, hitRecord.Normal.X, 1.0e-5, "Normal should lean towards the tangent")
+	assert.InDeltaf(0.0, DotVector3(coordinate.Binormal0, hitRecord.Normal), 1.0e-5, "Frame should be orthogonal")
+
+	// A normal under the surface is pulled back above the geometric normal
+	under := NewNormalMapped(&lambertian, NewConstantTexture(Vector3{1.0, 0.5, 0.0}))
+	hitRecord = newMappedHitRecord(&under)
+	NewShadingCoordinate(&hitRecord)
+	assert.Truef(0.0 < DotVector3(hitRecord.Normal, hitRecord.GeometricNormal), "Shading normal should stay above the surface")
+}
+
+func TestBumpMap(t *testing.T) {
+	assert := assert.New(t)
+	lambertian := NewLambertian(Vector3{0.5, 0.5, 0.5})
+	bumped := NewBumpMapped(&lambertian, &rampTexture{}, 1.0)
+	hitRecord := newMappedHitRecord(&bumped)
+	NewShadingCoordinate(&hitRecord)
+	assert.InDeltaf(-0.7071068, hitRecord.Normal.X, 1.0e-3, "Normal should lean against the slope")
+	assert.InDeltaf(0.0, hitRecord.Normal.Y, 1.0e-5, "No slope along V")
+
+	// All the heights are filtered alike, the offset of the filtered level adds no slope
+	blurred := NewBumpMapped(&lambertian, &blurredRampTexture{}, 1.0)
+	hitRecord = newMappedHitRecord(&blurred)
+	hitRecord.DUVDX = Vector2{0.1, 0.0}
+	NewShadingCoordinate(&hitRecord)
+	assert.InDeltaf(-0.4472136, hitRecord.Normal.X, 1.0e-3, "Normal should follow the filtered slope")
+}
+
+func TestLeaksLight(t *testing.T) {
+	assert := assert.New(t)
+	hitRecord := newMappedHitRecord(nil)
+	hitRecord.Normal = NormalizeVector3(Vector3{1.0, 0.0, 1.0})
+	coordinate := NewCoordinateTangent(hitRecord.Normal, hitRecord.Tangent)
+	below := NormalizeVector3(Vector3{1.0, 0.0, -0.2})
+	assert.Truef(LeaksLight(&hitRecord, below, coordinate.WorldToLocal(below)), "Under the geometric surface should leak")
+	above := NormalizeVector3(Vector3{0.2, 0.0, 1.0})
+	assert.Falsef(LeaksLight(&hitRecord, above, coordinate.WorldToLocal(above)), "Above both surfaces should not leak")
+}
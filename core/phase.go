@@ -0,0 +1,30 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// HenyeyGreenstein phase function of the cosine between the propagation directions before and after scattering
+//
+// Positive g scatters forward, negative g backward, zero is isotropic.
+// L. G. Henyey, J. L. Greenstein, "Diffuse radiation in the Galaxy", Astrophysical Journal 93, 1941
+func HenyeyGreenstein(cosine, g float32) float32 {
+	denominator := 1.0 + g*g - 2.0*g*cosine
+	return (1.0 - g*g) / (4.0 * math32.Pi * denominator * math32.Sqrt(math32.Max(Epsilon32, denominator)))
+}
+
+// SampleHenyeyGreenstein samples a new propagation direction around the direction
+func SampleHenyeyGreenstein(direction Vector3, g, eta0, eta1 float32) Vector3 {
+	var cosine float32
+	if math32.Abs(g) < 1.0e-3 {
+		cosine = 1.0 - 2.0*eta0
+	} else {
+		s := (1.0 - g*g) / (1.0 - g + 2.0*g*eta0)
+		cosine = (1.0 + g*g - s*s) / (2.0 * g)
+	}
+	cosine = math32.Max(-1.0, math32.Min(1.0, cosine))
+	sine := math32.Sqrt(math32.Max(0.0, 1.0-cosine*cosine))
+	phi := 2.0 * math32.Pi * eta1
+	coordinate := NewCoordinate(direction)
+	return NormalizeVector3(coordinate.LocalToWorld(Vector3{sine * math32.Cos(phi), sine * math32.Sin(phi), cosine}))
+}
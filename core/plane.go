@@ -0,0 +1,189 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// axisFrame orthonormal frame whose Z is an axis of a shape
+type axisFrame struct {
+	Tangent   Vector3
+	Bitangent Vector3
+	Axis      Vector3
+}
+
+func newAxisFrame(axis Vector3) axisFrame {
+	axis = NormalizeVector3(axis)
+	tangent, bitangent := OrthonormalBasis(axis)
+	return axisFrame{tangent, bitangent, axis}
+}
+
+func (frame *axisFrame) toLocal(v Vector3) Vector3 {
+	return Vector3{DotVector3(v, frame.Tangent), DotVector3(v, frame.Bitangent), DotVector3(v, frame.Axis)}
+}
+
+func (frame *axisFrame) toWorld(v Vector3) Vector3 {
+	return AddVector3(AddVector3(MulVector3(v.X, frame.Tangent), MulVector3(v.Y, frame.Bitangent)), MulVector3(v.Z, frame.Axis))
+}
+
+// setFlatRecord fills a hit record of a flat surface, whose normal and its derivatives are constant
+func setFlatRecord(record *HitRecord, ray Ray, t float32, normal Vector3, uv Vector2, dpdu, dpdv Vector3, material Material) {
+	record.T = t
+	record.Position = ray.PointAt(t)
+	record.Normal = normal
+	record.GeometricNormal = normal
+	record.Tangent = NormalizeVector3(dpdu)
+	record.UV = uv
+	record.DPDU = dpdu
+	record.DPDV = dpdv
+	record.DNDU = Vector3{}
+	record.DNDV = Vector3{}
+	record.Color = Vector3{1.0, 1.0, 1.0}
+	record.Material = material
+}
+
+// intersectPlane returns the parameter of the ray at the plane through point, false if parallel or out of the range
+func intersectPlane(ray Ray, point, normal Vector3, tmin, tmax float32) (float32, bool) {
+	cosine := DotVector3(normal, ray.Direction)
+	if math32.Abs(cosine) <= Epsilon32*Epsilon32 {
+		return 0.0, false
+	}
+	t := DotVector3(normal, SubVector3(point, ray.Origin)) / cosine
+	return t, tmin < t && t < tmax
+}
+
+// Plane infinite plane through Point, UV is the distance along the tangents of Normal
+type Plane struct {
+	Point    Vector3
+	Normal   Vector3
+	Material Material
+}
+
+func NewPlane(point, normal Vector3, material Material) Plane {
+	return Plane{point, NormalizeVector3(normal), material}
+}
+
+func (plane *Plane) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	t, valid := intersectPlane(ray, plane.Point, plane.Normal, tmin, tmax)
+	if !valid {
+		return false
+	}
+	frame := newAxisFrame(plane.Normal)
+	local := frame.toLocal(SubVector3(ray.PointAt(t), plane.Point))
+	setFlatRecord(record, ray, t, frame.Axis, Vector2{local.X, local.Y}, frame.Tangent, frame.Bitangent, plane.Material)
+	return alphaTest(record)
+}
+
+func (plane *Plane) BoundingBox() AABB {
+	return NewInfiniteAABB()
+}
+
+// Quad parallelogram spanned by the edges U and V from Origin, the normal is U cross V
+type Quad struct {
+	Origin   Vector3
+	U        Vector3
+	V        Vector3
+	Material Material
+}
+
+func NewQuad(origin, u, v Vector3, material Material) Quad {
+	return Quad{origin, u, v, material}
+}
+
+func (quad *Quad) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	n := CrossVector3(quad.U, quad.V)
+	nn := DotVector3(n, n)
+	if nn <= 0.0 {
+		return false
+	}
+	normal := DivVector3(n, math32.Sqrt(nn))
+	t, valid := intersectPlane(ray, quad.Origin, normal, tmin, tmax)
+	if !valid {
+		return false
+	}
+	planar := SubVector3(ray.PointAt(t), quad.Origin)
+	w := DivVector3(n, nn)
+	alpha := DotVector3(w, CrossVector3(planar, quad.V))
+	beta := DotVector3(w, CrossVector3(quad.U, planar))
+	if alpha < 0.0 || 1.0 < alpha || beta < 0.0 || 1.0 < beta {
+		return false
+	}
+	setFlatRecord(record, ray, t, normal, Vector2{alpha, beta}, quad.U, quad.V, quad.Material)
+	return alphaTest(record)
+}
+
+func (quad *Quad) BoundingBox() AABB {
+	box := ExpandAABB(AABB{quad.Origin, quad.Origin}, AddVector3(quad.Origin, quad.U))
+	box = ExpandAABB(box, AddVector3(quad.Origin, quad.V))
+	return ExpandAABB(box, AddVector3(AddVector3(quad.Origin, quad.U), quad.V))
+}
+
+func (quad *Quad) Area() float32 {
+	n := CrossVector3(quad.U, quad.V)
+	return n.Length()
+}
+
+func (quad *Quad) SampleArea(u0, u1 float32) (Vector3, Vector3) {
+	position := AddVector3(quad.Origin, AddVector3(MulVector3(u0, quad.U), MulVector3(u1, quad.V)))
+	return position, NormalizeVector3(CrossVector3(quad.U, quad.V))
+}
+
+// Disk disk facing Normal, UV is the angle around the normal and the distance from the rim normalized to [0 1]
+type Disk struct {
+	Center   Vector3
+	Normal   Vector3
+	Radius   float32
+	Material Material
+}
+
+func NewDisk(center, normal Vector3, radius float32, material Material) Disk {
+	return Disk{center, NormalizeVector3(normal), radius, material}
+}
+
+// polarRecord returns UV and the derivatives of a point in the plane of a frame by polar coordinates, v is 1 at the center
+func polarRecord(frame *axisFrame, local Vector3, radius float32) (Vector2, Vector3, Vector3) {
+	r := math32.Sqrt(local.X*local.X + local.Y*local.Y)
+	phi := math32.Atan2(local.Y, local.X)
+	if phi < 0.0 {
+		phi += 2.0 * math32.Pi
+	}
+	cs := math32.Cos(phi)
+	sn := math32.Sin(phi)
+	dpdu := frame.toWorld(Vector3{-2.0 * math32.Pi * r * sn, 2.0 * math32.Pi * r * cs, 0.0})
+	if r <= Epsilon32 {
+		dpdu = MulVector3(2.0*math32.Pi*radius, frame.Bitangent)
+	}
+	dpdv := frame.toWorld(Vector3{-radius * cs, -radius * sn, 0.0})
+	return Vector2{phi / (2.0 * math32.Pi), 1.0 - r/radius}, dpdu, dpdv
+}
+
+func (disk *Disk) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	t, valid := intersectPlane(ray, disk.Center, disk.Normal, tmin, tmax)
+	if !valid {
+		return false
+	}
+	frame := newAxisFrame(disk.Normal)
+	local := frame.toLocal(SubVector3(ray.PointAt(t), disk.Center))
+	if disk.Radius*disk.Radius < local.X*local.X+local.Y*local.Y {
+		return false
+	}
+	uv, dpdu, dpdv := polarRecord(&frame, local, disk.Radius)
+	setFlatRecord(record, ray, t, frame.Axis, uv, dpdu, dpdv, disk.Material)
+	return alphaTest(record)
+}
+
+func (disk *Disk) BoundingBox() AABB {
+	extent := capExtent(disk.Normal, disk.Radius)
+	return AABB{SubVector3(disk.Center, extent), AddVector3(disk.Center, extent)}
+}
+
+func (disk *Disk) Area() float32 {
+	return math32.Pi * disk.Radius * disk.Radius
+}
+
+func (disk *Disk) SampleArea(u0, u1 float32) (Vector3, Vector3) {
+	frame := newAxisFrame(disk.Normal)
+	r := disk.Radius * math32.Sqrt(u0)
+	phi := 2.0 * math32.Pi * u1
+	position := frame.toWorld(Vector3{r * math32.Cos(phi), r * math32.Sin(phi), 0.0})
+	return AddVector3(disk.Center, position), frame.Axis
+}
@@ -0,0 +1,415 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type plyFormat int32
+
+const (
+	plyASCII plyFormat = iota
+	plyBinaryLittleEndian
+	plyBinaryBigEndian
+)
+
+type plyType int32
+
+const (
+	plyInt8 plyType = iota
+	plyUint8
+	plyInt16
+	plyUint16
+	plyInt32
+	plyUint32
+	plyFloat32
+	plyFloat64
+)
+
+var plyTypes = map[string]plyType{
+	"char": plyInt8, "int8": plyInt8,
+	"uchar": plyUint8, "uint8": plyUint8,
+	"short": plyInt16, "int16": plyInt16,
+	"ushort": plyUint16, "uint16": plyUint16,
+	"int": plyInt32, "int32": plyInt32,
+	"uint": plyUint32, "uint32": plyUint32,
+	"float": plyFloat32, "float32": plyFloat32,
+	"double": plyFloat64, "float64": plyFloat64,
+}
+
+var plyTypeSizes = [...]int{1, 1, 2, 2, 4, 4, 4, 8}
+
+// plyMaxPreallocation bounds the capacity reserved from the counts of the header, which are not trusted
+const plyMaxPreallocation = 1 << 20
+
+// plyCapacity returns the capacity to reserve for count items
+func plyCapacity(count int) int {
+	if plyMaxPreallocation < count {
+		return plyMaxPreallocation
+	}
+	return count
+}
+
+// plyProperty is a list if List is true, then CountType is the type of its length and Type of its items
+type plyProperty struct {
+	Name      string
+	Type      plyType
+	List      bool
+	CountType plyType
+}
+
+type plyElement struct {
+	Name       string
+	Count      int
+	Properties []plyProperty
+}
+
+// plyReader reads the values of the body one by one, so that the file is never held in memory
+type plyReader struct {
+	reader  *bufio.Reader
+	format  plyFormat
+	order   binary.ByteOrder
+	buffer  [8]byte
+	scanner *bufio.Scanner
+}
+
+// LoadPLY loads a PLY file as a triangle mesh
+func LoadPLY(path string, material Material) (*Mesh, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadPLY(file, material)
+}
+
+// ReadPLY streams an ASCII or binary PLY as a triangle mesh
+//
+// The vertices need x, y and z, and can have the normals nx, ny and nz, the colors red, green and blue,
+// which are converted from sRGB, and the texture coordinates u and v, s and t or texture_u and texture_v.
+// The faces are the lists vertex_indices or vertex_index, polygons are triangulated as fans.
+// Other elements and properties are skipped.
+func ReadPLY(reader io.Reader, material Material) (*Mesh, error) {
+	ply := &plyReader{reader: bufio.NewReaderSize(reader, 1<<16)}
+	elements, err := ply.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	var positions, normals, colors []Vector3
+	var uvs []Vector2
+	var indices []int32
+	for _, element := range elements {
+		switch element.Name {
+		case "vertex":
+			positions, normals, uvs, colors, err = ply.readVertices(&element)
+		case "face":
+			indices, err = ply.readFaces(&element)
+		default:
+			err = ply.skipElement(&element)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ply element %s: %v", element.Name, err)
+		}
+	}
+	mesh, err := NewMesh(positions, normals, uvs, indices, material)
+	if err != nil {
+		return nil, err
+	}
+	if err := mesh.SetColors(colors); err != nil {
+		return nil, err
+	}
+	return mesh, nil
+}
+
+func (ply *plyReader) readHeader() ([]plyElement, error) {
+	line, err := ply.readLine()
+	if err != nil || "ply" != line {
+		return nil, errors.New("not a ply file")
+	}
+	elements := []plyElement{}
+	hasFormat := false
+	for {
+		line, err := ply.readLine()
+		if err != nil {
+			return nil, errors.New("truncated ply header")
+		}
+		fields := strings.Fields(line)
+		if 0 == len(fields) {
+			continue
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return nil, errors.New("invalid ply format")
+			}
+			switch fields[1] {
+			case "ascii":
+				ply.format = plyASCII
+			case "binary_little_endian":
+				ply.format, ply.order = plyBinaryLittleEndian, binary.LittleEndian
+			case "binary_big_endian":
+				ply.format, ply.order = plyBinaryBigEndian, binary.BigEndian
+			default:
+				return nil, fmt.Errorf("unsupported ply format %s", fields[1])
+			}
+			hasFormat = true
+		case "element":
+			if len(fields) != 3 {
+				return nil, errors.New("invalid ply element")
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("invalid ply element count %s", fields[2])
+			}
+			elements = append(elements, plyElement{Name: fields[1], Count: count})
+		case "property":
+			if 0 == len(elements) {
+				return nil, errors.New("ply property outside of an element")
+			}
+			property, err := parsePLYProperty(fields[1:])
+			if err != nil {
+				return nil, err
+			}
+			element := &elements[len(elements)-1]
+			element.Properties = append(element.Properties, property)
+		case "end_header":
+			if !hasFormat {
+				return nil, errors.New("ply header without format")
+			}
+			if plyASCII == ply.format {
+				ply.scanner = bufio.NewScanner(ply.reader)
+				ply.scanner.Split(bufio.ScanWords)
+			}
+			return elements, nil
+		}
+	}
+}
+
+func parsePLYProperty(fields []string) (plyProperty, error) {
+	if 3 == len(fields) && "list" == fields[0] {
+		return plyProperty{}, errors.New("invalid ply list property")
+	}
+	if 4 == len(fields) && "list" == fields[0] {
+		countType, ok := plyTypes[fields[1]]
+		itemType, itemOk := plyTypes[fields[2]]
+		if !ok || !itemOk {
+			return plyProperty{}, fmt.Errorf("unsupported ply list property %s", fields[3])
+		}
+		return plyProperty{fields[3], itemType, true, countType}, nil
+	}
+	if 2 != len(fields) {
+		return plyProperty{}, errors.New("invalid ply property")
+	}
+	valueType, ok := plyTypes[fields[0]]
+	if !ok {
+		return plyProperty{}, fmt.Errorf("unsupported ply type %s", fields[0])
+	}
+	return plyProperty{Name: fields[1], Type: valueType}, nil
+}
+
+func (ply *plyReader) readLine() (string, error) {
+	line, err := ply.reader.ReadString('\n')
+	if err != nil && (io.EOF != err || 0 == len(line)) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readValue reads a scalar of a type, integers are exact in float64
+func (ply *plyReader) readValue(valueType plyType) (float64, error) {
+	if plyASCII == ply.format {
+		if !ply.scanner.Scan() {
+			if err := ply.scanner.Err(); err != nil {
+				return 0.0, err
+			}
+			return 0.0, io.ErrUnexpectedEOF
+		}
+		return strconv.ParseFloat(ply.scanner.Text(), 64)
+	}
+	data := ply.buffer[:plyTypeSizes[valueType]]
+	if _, err := io.ReadFull(ply.reader, data); err != nil {
+		return 0.0, err
+	}
+	switch valueType {
+	case plyInt8:
+		return float64(int8(data[0])), nil
+	case plyUint8:
+		return float64(data[0]), nil
+	case plyInt16:
+		return float64(int16(ply.order.Uint16(data))), nil
+	case plyUint16:
+		return float64(ply.order.Uint16(data)), nil
+	case plyInt32:
+		return float64(int32(ply.order.Uint32(data))), nil
+	case plyUint32:
+		return float64(ply.order.Uint32(data)), nil
+	case plyFloat32:
+		return float64(math.Float32frombits(ply.order.Uint32(data))), nil
+	default:
+		return math.Float64frombits(ply.order.Uint64(data)), nil
+	}
+}
+
+// readProperty reads a scalar, or the items of a list which are appended to items
+func (ply *plyReader) readProperty(property *plyProperty, value *float64, items []float64) ([]float64, error) {
+	if !property.List {
+		var err error
+		*value, err = ply.readValue(property.Type)
+		return items, err
+	}
+	count, err := ply.readValue(property.CountType)
+	if err != nil {
+		return items, err
+	}
+	if count < 0 {
+		return items, errors.New("negative list length")
+	}
+	for i := 0; i < int(count); i++ {
+		item, err := ply.readValue(property.Type)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (ply *plyReader) skipElement(element *plyElement) error {
+	var value float64
+	items := []float64{}
+	for i := 0; i < element.Count; i++ {
+		for j := range element.Properties {
+			var err error
+			if items, err = ply.readProperty(&element.Properties[j], &value, items[:0]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// plyColorScale normalizes the integer colors to [0 1]
+func plyColorScale(valueType plyType) float64 {
+	switch valueType {
+	case plyUint8, plyInt8:
+		return 1.0 / 255.0
+	case plyUint16, plyInt16:
+		return 1.0 / 65535.0
+	case plyUint32, plyInt32:
+		return 1.0 / 4294967295.0
+	}
+	return 1.0
+}
+
+func (ply *plyReader) readVertices(element *plyElement) ([]Vector3, []Vector3, []Vector2, []Vector3, error) {
+	// The slot of each attribute in the values of a vertex, -1 if missing
+	slots := map[string]int{}
+	for i, property := range element.Properties {
+		if !property.List {
+			slots[property.Name] = i
+		}
+	}
+	slot := func(names ...string) int {
+		for _, name := range names {
+			if i, ok := slots[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	x, y, z := slot("x"), slot("y"), slot("z")
+	if x < 0 || y < 0 || z < 0 {
+		return nil, nil, nil, nil, errors.New("vertices without positions")
+	}
+	nx, ny, nz := slot("nx"), slot("ny"), slot("nz")
+	red, green, blue := slot("red", "r", "diffuse_red"), slot("green", "g", "diffuse_green"), slot("blue", "b", "diffuse_blue")
+	u, v := slot("u", "s", "texture_u", "texture_s"), slot("v", "t", "texture_v", "texture_t")
+
+	capacity := plyCapacity(element.Count)
+	positions := make([]Vector3, 0, capacity)
+	var normals, colors []Vector3
+	var uvs []Vector2
+	if 0 <= nx && 0 <= ny && 0 <= nz {
+		normals = make([]Vector3, 0, capacity)
+	}
+	var colorScale Vector3
+	if 0 <= red && 0 <= green && 0 <= blue {
+		colors = make([]Vector3, 0, capacity)
+		colorScale = Vector3{
+			float32(plyColorScale(element.Properties[red].Type)),
+			float32(plyColorScale(element.Properties[green].Type)),
+			float32(plyColorScale(element.Properties[blue].Type)),
+		}
+	}
+	if 0 <= u && 0 <= v {
+		uvs = make([]Vector2, 0, capacity)
+	}
+
+	values := make([]float64, len(element.Properties))
+	items := []float64{}
+	for i := 0; i < element.Count; i++ {
+		for j := range element.Properties {
+			var err error
+			if items, err = ply.readProperty(&element.Properties[j], &values[j], items[:0]); err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("%d of %d vertices read: %v", i, element.Count, err)
+			}
+		}
+		positions = append(positions, Vector3{float32(values[x]), float32(values[y]), float32(values[z])})
+		if nil != normals {
+			normals = append(normals, Vector3{float32(values[nx]), float32(values[ny]), float32(values[nz])})
+		}
+		if nil != colors {
+			colors = append(colors, Vector3{
+				sRGBToLinear(Saturate32(colorScale.X * float32(values[red]))),
+				sRGBToLinear(Saturate32(colorScale.Y * float32(values[green]))),
+				sRGBToLinear(Saturate32(colorScale.Z * float32(values[blue]))),
+			})
+		}
+		if nil != uvs {
+			uvs = append(uvs, Vector2{float32(values[u]), float32(values[v])})
+		}
+	}
+	return positions, normals, uvs, colors, nil
+}
+
+func (ply *plyReader) readFaces(element *plyElement) ([]int32, error) {
+	list := -1
+	for i, property := range element.Properties {
+		if property.List && ("vertex_indices" == property.Name || "vertex_index" == property.Name) {
+			list = i
+		}
+	}
+	if list < 0 {
+		return nil, errors.New("faces without vertex indices")
+	}
+
+	indices := make([]int32, 0, 3*plyCapacity(element.Count))
+	var value float64
+	polygon := []float64{}
+	items := []float64{}
+	for i := 0; i < element.Count; i++ {
+		for j := range element.Properties {
+			var err error
+			if j == list {
+				polygon, err = ply.readProperty(&element.Properties[j], &value, polygon[:0])
+			} else {
+				items, err = ply.readProperty(&element.Properties[j], &value, items[:0])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%d of %d faces read: %v", i, element.Count, err)
+			}
+		}
+		for k := 2; k < len(polygon); k++ {
+			indices = append(indices, int32(polygon[0]), int32(polygon[k-1]), int32(polygon[k]))
+		}
+	}
+	return indices, nil
+}
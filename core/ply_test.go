@@ -0,0 +1,120 @@
+package core
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const testPLYHeader = `element vertex 4
+property float x
+property float y
+property float z
+property float nx
+property float ny
+property float nz
+property uchar red
+property uchar green
+property uchar blue
+property float u
+property float v
+element material 1
+property list uchar int ids
+element face 1
+property uchar flags
+property list uchar int vertex_indices
+end_header
+`
+
+func checkPLYQuad(assert *assert.Assertions, mesh *Mesh) {
+	assert.Equal(int32(2), mesh.NumTriangles())
+	assert.Equal(4, len(mesh.Normals))
+	assert.Equal(4, len(mesh.UVs))
+	assert.Equal(4, len(mesh.Colors))
+	assert.InDelta(4.0, mesh.Area(), 1.0e-5)
+
+	record := HitRecord{}
+	assert.True(mesh.Hit(Ray{Vector3{0.5, -0.5, 2.0}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &record))
+	assert.InDelta(2.0, record.T, 1.0e-5)
+	assert.InDelta(0.75, record.UV.X, 1.0e-5)
+	assert.InDelta(0.25, record.UV.Y, 1.0e-5)
+	assert.InDelta(1.0, record.Normal.Z, 1.0e-5)
+	// Red at the bottom, blue at the top, converted from sRGB
+	assert.InDelta(0.75, record.Color.X, 1.0e-5)
+	assert.InDelta(0.25, record.Color.Z, 1.0e-5)
+	assert.InDelta(0.0, record.Color.Y, 1.0e-5)
+	assert.Equal(record.Color, EvaluateTexture(&VertexColorTexture{}, &record))
+}
+
+func writeTestPLYBinary(buffer *bytes.Buffer, order binary.ByteOrder) {
+	positions := [][3]float32{{-1.0, -1.0, 0.0}, {1.0, -1.0, 0.0}, {1.0, 1.0, 0.0}, {-1.0, 1.0, 0.0}}
+	colors := [][3]uint8{{255, 0, 0}, {255, 0, 0}, {0, 0, 255}, {0, 0, 255}}
+	uvs := [][2]float32{{0.0, 0.0}, {1.0, 0.0}, {1.0, 1.0}, {0.0, 1.0}}
+	for i := range positions {
+		binary.Write(buffer, order, positions[i])
+		binary.Write(buffer, order, [3]float32{0.0, 0.0, 1.0})
+		binary.Write(buffer, order, colors[i])
+		binary.Write(buffer, order, uvs[i])
+	}
+	binary.Write(buffer, order, uint8(2))
+	binary.Write(buffer, order, [2]int32{7, 8})
+	binary.Write(buffer, order, [2]uint8{0, 4})
+	binary.Write(buffer, order, [4]int32{0, 1, 2, 3})
+}
+
+func TestReadPLYASCII(t *testing.T) {
+	assert := assert.New(t)
+	ply := "ply\r\nformat ascii 1.0\ncomment quad\n" + testPLYHeader + `-1 -1 0 0 0 1 255 0 0 0 0
+1 -1 0 0 0 1 255 0 0 1 0
+1 1 0 0 0 1 0 0 255 1 1
+-1 1 0 0 0 1 0 0 255 0 1
+3 1 2 3
+0 4 0 1 2 3
+`
+	mesh, err := ReadPLY(strings.NewReader(ply), nil)
+	assert.Nil(err)
+	if nil == mesh {
+		return
+	}
+	checkPLYQuad(assert, mesh)
+}
+
+func TestReadPLYBinary(t *testing.T) {
+	assert := assert.New(t)
+	for _, format := range []struct {
+		Name  string
+		Order binary.ByteOrder
+	}{{"binary_little_endian", binary.LittleEndian}, {"binary_big_endian", binary.BigEndian}} {
+		var buffer bytes.Buffer
+		buffer.WriteString("ply\nformat " + format.Name + " 1.0\n" + testPLYHeader)
+		writeTestPLYBinary(&buffer, format.Order)
+		mesh, err := ReadPLY(&buffer, nil)
+		assert.Nil(err, format.Name)
+		if nil == mesh {
+			continue
+		}
+		checkPLYQuad(assert, mesh)
+	}
+}
+
+func TestReadPLYErrors(t *testing.T) {
+	assert := assert.New(t)
+	_, err := ReadPLY(strings.NewReader("obj\n"), nil)
+	assert.NotNil(err)
+	_, err = ReadPLY(strings.NewReader("ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n0\n"), nil)
+	assert.NotNil(err)
+	_, err = ReadPLY(strings.NewReader("ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\nend_header\n0 0 0\n1 0 0\n"), nil)
+	assert.NotNil(err)
+	_, err = ReadPLY(strings.NewReader("ply\nformat binary_middle_endian 1.0\nend_header\n"), nil)
+	assert.NotNil(err)
+	// A huge count in the header fails on the missing data instead of reserving it
+	_, err = ReadPLY(strings.NewReader("ply\nformat binary_little_endian 1.0\nelement vertex 2000000000\nproperty float x\nproperty float y\nproperty float z\nend_header\n"), nil)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "0 of 2000000000 vertices read")
+	}
+	_, err = ReadPLY(strings.NewReader("ply\nformat ascii 1.0\nelement face 2\nproperty list uchar int vertex_indices\nend_header\n3 0 1 2\n"), nil)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "1 of 2 faces read")
+	}
+}
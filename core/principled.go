@@ -0,0 +1,297 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"math/rand"
+)
+
+// Principled
+//
+// Brent Burley, "Physically Based Shading at Disney", SIGGRAPH 2012 Course: Practical Physically Based Shading in Film and Game Production
+// Brent Burley, "Extending the Disney BRDF to a BSDF with Integrated Subsurface Scattering", SIGGRAPH 2015 Course: Physically Based Shading in Theory and Practice
+//
+// The sheen is the Charlie sheen of SheenRoughness if it is positive, otherwise the Schlick sheen of Disney.
+type Principled struct {
+	BaseColor      Vector3
+	Metallic       float32
+	Roughness      float32
+	Specular       float32
+	SpecularTint   float32
+	Sheen          float32
+	SheenTint      float32
+	SheenRoughness float32
+	Clearcoat      float32
+	ClearcoatGloss float32
+	Transmission   float32
+	IOR            float32
+	Anisotropic    float32
+
+	BaseColorTexture Texture
+	MetallicTexture  Texture
+	RoughnessTexture Texture
+}
+
+func NewPrincipled(baseColor Vector3, metallic, roughness float32) Principled {
+	return Principled{
+		BaseColor:      baseColor,
+		Metallic:       metallic,
+		Roughness:      roughness,
+		Specular:       0.5,
+		ClearcoatGloss: 1.0,
+		IOR:            1.5,
+	}
+}
+
+func (principled *Principled) Resolve(hitRecord *HitRecord) Material {
+	if principled.BaseColorTexture == nil && principled.MetallicTexture == nil && principled.RoughnessTexture == nil {
+		return principled
+	}
+	resolved := *principled
+	resolved.BaseColor = ModulateColor(principled.BaseColor, principled.BaseColorTexture, hitRecord)
+	resolved.Metallic = ModulateScalar(principled.Metallic, principled.MetallicTexture, hitRecord)
+	resolved.Roughness = ModulateScalar(principled.Roughness, principled.RoughnessTexture, hitRecord)
+	resolved.BaseColorTexture = nil
+	resolved.MetallicTexture = nil
+	resolved.RoughnessTexture = nil
+	return &resolved
+}
+
+const (
+	principledDiffuse = iota
+	principledSpecular
+	principledClearcoat
+	principledTransmission
+	principledLobes
+)
+
+func schlickWeight(cosine float32) float32 {
+	m := Saturate32(1.0 - cosine)
+	m2 := m * m
+	return m2 * m2 * m
+}
+
+// gtr1 Generalized-Trowbridge-Reitz distribution with gamma=1 for the clearcoat
+func gtr1(cosine, alpha float32) float32 {
+	if 1.0 <= alpha {
+		return 1.0 / math32.Pi
+	}
+	alpha2 := alpha * alpha
+	t := 1.0 + (alpha2-1.0)*cosine*cosine
+	return (alpha2 - 1.0) / (math32.Pi * math32.Log(alpha2) * t)
+}
+
+func (principled *Principled) tint() Vector3 {
+	luminance := Luminance(principled.BaseColor)
+	if luminance <= Epsilon32 {
+		return Vector3{1.0, 1.0, 1.0}
+	}
+	return DivVector3(principled.BaseColor, luminance)
+}
+
+func (principled *Principled) specularColor() Vector3 {
+	white := Vector3{1.0, 1.0, 1.0}
+	dielectric := MulVector3(0.08*principled.Specular, LerpVector3(white, principled.tint(), principled.SpecularTint))
+	return LerpVector3(dielectric, principled.BaseColor, principled.Metallic)
+}
+
+func (principled *Principled) sheenColor() Vector3 {
+	return LerpVector3(Vector3{1.0, 1.0, 1.0}, principled.tint(), principled.SheenTint)
+}
+
+func (principled *Principled) distribution() ggx {
+	return newGGX(principled.Roughness, principled.Anisotropic)
+}
+
+func (principled *Principled) clearcoatAlpha() float32 {
+	return Lerp32(0.1, 0.001, principled.ClearcoatGloss)
+}
+
+func (principled *Principled) transmissionWeight() float32 {
+	return (1.0 - principled.Metallic) * principled.Transmission
+}
+
+// probabilities returns the probabilities to select each lobe on the outside
+func (principled *Principled) probabilities() [principledLobes]float32 {
+	var p [principledLobes]float32
+	transmission := principled.transmissionWeight()
+	p[principledDiffuse] = (1.0 - principled.Metallic) * (1.0 - principled.Transmission) * Luminance(principled.BaseColor)
+	p[principledSpecular] = (1.0 - transmission) * Lerp32(Luminance(principled.specularColor()), 1.0, 0.5)
+	p[principledClearcoat] = 0.25 * principled.Clearcoat
+	p[principledTransmission] = transmission
+	total := float32(0.0)
+	for i := 0; i < principledLobes; i++ {
+		total += p[i]
+	}
+	if total <= Epsilon32 {
+		return [principledLobes]float32{}
+	}
+	for i := 0; i < principledLobes; i++ {
+		p[i] /= total
+	}
+	return p
+}
+
+func (principled *Principled) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	if EqualZero32(wi.Z) {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	var wo Vector3
+	valid := true
+	if wi.Z < 0.0 {
+		// Inside, only the dielectric interface remains
+		if principled.Transmission <= 0.0 {
+			return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+		}
+		wo, valid = principled.distribution().SampleDielectric(wi, principled.IOR, eta0, eta1, rand.Float32())
+	} else {
+		p := principled.probabilities()
+		lobe := 0
+		for ; lobe < principledLobes-1; lobe++ {
+			if eta0 < p[lobe] {
+				break
+			}
+			eta0 -= p[lobe]
+		}
+		if p[lobe] <= 0.0 {
+			return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+		}
+		eta0 = math32.Min(eta0/p[lobe], 1.0-Epsilon32)
+		switch lobe {
+		case principledDiffuse:
+			wo = RandomOnCosineHemiSphere(eta0, eta1)
+		case principledSpecular:
+			wm := principled.distribution().SampleVisible(wi, eta0, eta1)
+			wo = Reflect(wi.Minus(), wm)
+		case principledClearcoat:
+			alpha2 := principled.clearcoatAlpha() * principled.clearcoatAlpha()
+			cosTheta := math32.Sqrt(math32.Max(0.0, (1.0-math32.Pow(alpha2, 1.0-eta0))/(1.0-alpha2)))
+			sinTheta := math32.Sqrt(math32.Max(0.0, 1.0-cosTheta*cosTheta))
+			phi := 2.0 * math32.Pi * eta1
+			wm := Vector3{sinTheta * math32.Cos(phi), sinTheta * math32.Sin(phi), cosTheta}
+			wo = Reflect(wi.Minus(), wm)
+		case principledTransmission:
+			wo, valid = principled.distribution().SampleDielectric(wi, principled.IOR, eta0, eta1, rand.Float32())
+		}
+	}
+	if !valid {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	pdf := principled.Pdf(wi, wo)
+	if pdf <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	return MaterialSample{true, pdf, DivVector3(principled.Eval(wi, wo), pdf), wo}
+}
+
+func (principled *Principled) Eval(wi, wo Vector3) Vector3 {
+	if wi.Z < 0.0 {
+		if principled.Transmission <= 0.0 {
+			return Vector3{}
+		}
+		f := principled.distribution().EvalDielectric(wi, wo, principled.IOR)
+		return Vector3{f, f, f}
+	}
+	if wi.Z <= Epsilon32 || EqualZero32(wo.Z) {
+		return Vector3{}
+	}
+	transmission := principled.transmissionWeight()
+	if wo.Z < 0.0 {
+		f := transmission * principled.distribution().EvalDielectric(wi, wo, principled.IOR)
+		return MulVector3(f, principled.BaseColor)
+	}
+
+	wm := NormalizeVector3(AddVector3(wi, wo))
+	cosD := DotVector3(wo, wm)
+	fh := schlickWeight(cosD)
+	result := Vector3{}
+
+	// Diffuse and sheen
+	diffuseWeight := (1.0 - principled.Metallic) * (1.0 - principled.Transmission)
+	if 0.0 < diffuseWeight {
+		fl := schlickWeight(wo.Z)
+		fv := schlickWeight(wi.Z)
+		fd90 := 0.5 + 2.0*cosD*cosD*principled.Roughness
+		fd := Lerp32(1.0, fd90, fl) * Lerp32(1.0, fd90, fv)
+		diffuse := MulVector3(fd/math32.Pi, principled.BaseColor)
+		sheen := MulVector3(fh*principled.Sheen, principled.sheenColor())
+		if 0.0 < principled.SheenRoughness {
+			sheen = MulVector3(principled.Sheen*charlieSheen(wi, wo, principled.SheenRoughness), principled.sheenColor())
+		}
+		result = AddVector3(result, MulVector3(diffuseWeight*wo.Z, AddVector3(diffuse, sheen)))
+	}
+
+	// Specular reflection
+	distribution := principled.distribution()
+	specular := LerpVector3(principled.specularColor(), Vector3{1.0, 1.0, 1.0}, fh)
+	d := distribution.D(wm)
+	g := distribution.G2(wi, wo)
+	result = AddVector3(result, MulVector3((1.0-transmission)*d*g/(4.0*wi.Z), specular))
+
+	// Clearcoat
+	if 0.0 < principled.Clearcoat {
+		coat := ggx{0.25, 0.25}
+		dr := gtr1(wm.Z, principled.clearcoatAlpha())
+		fr := Lerp32(0.04, 1.0, fh)
+		gr := coat.G1(wi) * coat.G1(wo)
+		f := 0.25 * principled.Clearcoat * dr * fr * gr / (4.0 * wi.Z)
+		result = AddVector3(result, Vector3{f, f, f})
+	}
+
+	// Reflection part of the transmission lobe
+	if 0.0 < transmission {
+		f := transmission * distribution.EvalDielectric(wi, wo, principled.IOR)
+		result = AddVector3(result, Vector3{f, f, f})
+	}
+	return result
+}
+
+func (principled *Principled) Pdf(wi, wo Vector3) float32 {
+	if wi.Z < 0.0 {
+		if principled.Transmission <= 0.0 {
+			return 0.0
+		}
+		return principled.distribution().PdfDielectric(wi, wo, principled.IOR)
+	}
+	if wi.Z <= Epsilon32 || EqualZero32(wo.Z) {
+		return 0.0
+	}
+	p := principled.probabilities()
+	distribution := principled.distribution()
+	pdf := p[principledTransmission] * distribution.PdfDielectric(wi, wo, principled.IOR)
+	if wo.Z < 0.0 {
+		return pdf
+	}
+	wm := NormalizeVector3(AddVector3(wi, wo))
+	pdf += p[principledDiffuse] * wo.Z / math32.Pi
+	pdf += p[principledSpecular] * distribution.PdfReflection(wi, wm)
+	dotOM := DotVector3(wo, wm)
+	if Epsilon32 < dotOM {
+		pdf += p[principledClearcoat] * gtr1(wm.Z, principled.clearcoatAlpha()) * wm.Z / (4.0 * dotOM)
+	}
+	return pdf
+}
+
+func (principled *Principled) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return scatterBySample(principled, ray, hitRecord, attenuation, scattered)
+}
+
+func (principled *Principled) GetRoughness() float32 {
+	return principled.Roughness
+}
+
+func (principled *Principled) GetMetallic() float32 {
+	return principled.Metallic
+}
+
+func (principled *Principled) GetAlbedo() Vector3 {
+	return principled.BaseColor
+}
+
+// GetSheen returns the Charlie sheen lobe, it is black with the Schlick sheen
+func (principled *Principled) GetSheen() (Vector3, float32) {
+	if principled.SheenRoughness <= 0.0 {
+		return Vector3{}, 0.0
+	}
+	weight := (1.0 - principled.Metallic) * (1.0 - principled.Transmission) * principled.Sheen
+	return MulVector3(weight, principled.sheenColor()), principled.SheenRoughness
+}
@@ -0,0 +1,45 @@
+package core
+import (
+	"testing"
+	"math/rand"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipledSampleMatchesEval(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(3)
+	material := NewPrincipled(Vector3{0.8, 0.5, 0.2}, 0.3, 0.4)
+	material.Clearcoat = 0.5
+	material.Sheen = 0.5
+	material.Transmission = 0.5
+	material.Anisotropic = 0.5
+	wi := NormalizeVector3(Vector3{0.3, -0.2, 0.8})
+	for i:=0; i<1000; i++ {
+		sample := material.Sample(wi, rand.Float32(), rand.Float32())
+		if !sample.Continue {
+			continue
+		}
+		pdf := material.Pdf(wi, sample.Scattered)
+		assert.InDeltaf(1.0, sample.PDF/pdf, 1.0e-3, "PDF of sample %v should be %v", sample.PDF, pdf)
+		weight := DivVector3(material.Eval(wi, sample.Scattered), pdf)
+		assert.Truef(EqualVector3(weight, sample.Weight), "Weight of sample %v should be %v", sample.Weight, weight)
+	}
+}
+
+func TestPrincipledEnergy(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(4)
+	material := NewPrincipled(Vector3{1.0, 1.0, 1.0}, 0.0, 0.5)
+	material.Transmission = 0.5
+	wi := NormalizeVector3(Vector3{0.4, 0.0, 0.6})
+	const samples int = 20000
+	total := Vector3{}
+	for i:=0; i<samples; i++ {
+		sample := material.Sample(wi, rand.Float32(), rand.Float32())
+		if sample.Continue {
+			total = AddVector3(total, sample.Weight)
+		}
+	}
+	albedo := DivVector3(total, float32(samples))
+	assert.Truef(albedo.X <= 1.05 && 0.5 < albedo.X, "Albedo %v should not exceed one", albedo)
+}
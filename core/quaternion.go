@@ -0,0 +1,87 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// Quaternion rotation quaternion X i + Y j + Z k + W
+type Quaternion struct {
+	X float32
+	Y float32
+	Z float32
+	W float32
+}
+
+func IdentityQuaternion() Quaternion {
+	return Quaternion{0.0, 0.0, 0.0, 1.0}
+}
+
+// NewQuaternionAxisAngle rotation around an axis by an angle in radians, same as RotateMatrix4
+func NewQuaternionAxisAngle(axis Vector3, angle float32) Quaternion {
+	a := NormalizeVector3(axis)
+	sn := math32.Sin(0.5 * angle)
+	return Quaternion{a.X * sn, a.Y * sn, a.Z * sn, math32.Cos(0.5 * angle)}
+}
+
+func DotQuaternion(q0, q1 Quaternion) float32 {
+	return q0.X*q1.X + q0.Y*q1.Y + q0.Z*q1.Z + q0.W*q1.W
+}
+
+func NormalizeQuaternion(q Quaternion) Quaternion {
+	l := math32.Sqrt(DotQuaternion(q, q))
+	if l <= 0.0 {
+		return IdentityQuaternion()
+	}
+	return Quaternion{q.X / l, q.Y / l, q.Z / l, q.W / l}
+}
+
+// MulQuaternion returns q0 q1, which rotates by q1 first
+func MulQuaternion(q0, q1 Quaternion) Quaternion {
+	return Quaternion{
+		q0.W*q1.X + q0.X*q1.W + q0.Y*q1.Z - q0.Z*q1.Y,
+		q0.W*q1.Y - q0.X*q1.Z + q0.Y*q1.W + q0.Z*q1.X,
+		q0.W*q1.Z + q0.X*q1.Y - q0.Y*q1.X + q0.Z*q1.W,
+		q0.W*q1.W - q0.X*q1.X - q0.Y*q1.Y - q0.Z*q1.Z}
+}
+
+// SlerpQuaternion spherical linear interpolation along the shorter arc
+//
+// Ken Shoemake, "Animating Rotation with Quaternion Curves", SIGGRAPH 1985
+func SlerpQuaternion(q0, q1 Quaternion, t float32) Quaternion {
+	cs := DotQuaternion(q0, q1)
+	if cs < 0.0 {
+		cs = -cs
+		q1 = Quaternion{-q1.X, -q1.Y, -q1.Z, -q1.W}
+	}
+	if 0.9995 < cs {
+		// Nearly parallel, fall back to normalized linear interpolation
+		return NormalizeQuaternion(Quaternion{
+			q0.X + t*(q1.X-q0.X),
+			q0.Y + t*(q1.Y-q0.Y),
+			q0.Z + t*(q1.Z-q0.Z),
+			q0.W + t*(q1.W-q0.W)})
+	}
+	theta := math32.Acos(cs)
+	sn := math32.Sin(theta)
+	w0 := math32.Sin((1.0-t)*theta) / sn
+	w1 := math32.Sin(t*theta) / sn
+	return Quaternion{w0*q0.X + w1*q1.X, w0*q0.Y + w1*q1.Y, w0*q0.Z + w1*q1.Z, w0*q0.W + w1*q1.W}
+}
+
+// AngleQuaternion returns the angle of the rotation from q0 to q1
+func AngleQuaternion(q0, q1 Quaternion) float32 {
+	cs := math32.Min(math32.Abs(DotQuaternion(q0, q1)), 1.0)
+	return 2.0 * math32.Acos(cs)
+}
+
+// Matrix4 returns the rotation matrix of a unit quaternion
+func (q *Quaternion) Matrix4() Matrix4 {
+	xx, yy, zz := q.X*q.X, q.Y*q.Y, q.Z*q.Z
+	xy, xz, yz := q.X*q.Y, q.X*q.Z, q.Y*q.Z
+	wx, wy, wz := q.W*q.X, q.W*q.Y, q.W*q.Z
+	return Matrix4{
+		{1.0 - 2.0*(yy+zz), 2.0 * (xy - wz), 2.0 * (xz + wy), 0.0},
+		{2.0 * (xy + wz), 1.0 - 2.0*(xx+zz), 2.0 * (yz - wx), 0.0},
+		{2.0 * (xz - wy), 2.0 * (yz + wx), 1.0 - 2.0*(xx+yy), 0.0},
+		{0.0, 0.0, 0.0, 1.0}}
+}
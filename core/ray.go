@@ -1,11 +1,40 @@
 package core
 
+import (
+	"git.maze.io/go/math32"
+)
+
+// Ray half line from Origin, Time is the instant within the shutter interval of the camera at which it samples the scene
 type Ray struct {
 	Origin    Vector3
 	Direction Vector3
+	Time      float32
 }
 
 func (ray *Ray) PointAt(t float32) Vector3 {
 	return AddVector3(ray.Origin, MulVector3(t, ray.Direction))
 }
 
+
+// RayDifferential ray with the offset rays for the neighbor pixels in x and y on the screen
+//
+// Homan Igehy, "Tracing Ray Differentials", SIGGRAPH 1999
+type RayDifferential struct {
+	Ray
+	HasDifferentials bool
+	RxOrigin    Vector3
+	RyOrigin    Vector3
+	RxDirection Vector3
+	RyDirection Vector3
+}
+
+// Spread returns the larger angle between the ray and its offset rays
+func (ray *RayDifferential) Spread() float32 {
+	if !ray.HasDifferentials {
+		return 0.0
+	}
+	direction := NormalizeVector3(ray.Direction)
+	cx := DotVector3(direction, NormalizeVector3(ray.RxDirection))
+	cy := DotVector3(direction, NormalizeVector3(ray.RyDirection))
+	return math32.Acos(math32.Max(-1.0, math32.Min(1.0, math32.Min(cx, cy))))
+}
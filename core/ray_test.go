@@ -6,7 +6,7 @@ import (
 
 func TestRayPointAt(t *testing.T){
 	assert := assert.New(t)
-	ray := Ray{Vector3{0.0, 0.0, 0.0}, NormalizeVector3(Vector3{1.0, 1.0, 1.0})}
+	ray := Ray{Vector3{0.0, 0.0, 0.0}, NormalizeVector3(Vector3{1.0, 1.0, 1.0}), 0.0}
 	time := float32(2.0)
 	point := ray.PointAt(time)
 	expected := Vector3{1.1547005, 1.1547005, 1.1547005}
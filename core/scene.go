@@ -0,0 +1,194 @@
+package core
+
+import (
+	"strings"
+)
+
+// Visibility flags of the kinds of rays which see a surface
+type Visibility uint32
+
+const (
+	VisibleCamera     Visibility = 1 << iota // primary rays from the camera
+	VisibleShadow                            // shadow rays toward lights
+	VisibleReflection                        // rays scattered by surfaces and media
+	VisibleAll        Visibility = VisibleCamera | VisibleShadow | VisibleReflection
+)
+
+// World is a Hittable which can hide surfaces from the kinds of rays
+type World interface {
+	Hittable
+	HitVisible(ray Ray, tmin float32, tmax float32, visibility Visibility, record *HitRecord) bool
+}
+
+// materialOverride replaces the material of the hits of an object
+//
+// The object tests the mask of Material instead of its own, so that the hits it rejects are searched past.
+type materialOverride struct {
+	Object   Hittable
+	Material Material
+}
+
+func (override *materialOverride) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord) bool {
+	previous := record.alphaMaterial
+	record.alphaMaterial = override.Material
+	hit := override.Object.Hit(ray, tmin, tmax, record)
+	record.alphaMaterial = previous
+	if !hit {
+		return false
+	}
+	record.Material = override.Material
+	return true
+}
+
+func (override *materialOverride) BoundingBox() AABB {
+	return override.Object.BoundingBox()
+}
+
+func (override *materialOverride) MotionBounds(time0, time1 float32) (AABB, AABB) {
+	return motionBoundsOf(override.Object, time0, time1)
+}
+
+// SceneNode named node of a scene graph
+//
+// Transform is relative to the parent. Material, if not nil, replaces the materials of the objects
+// in the subtree down to the next node with its own Material. Visibility is inherited,
+// a surface is visible to a kind of ray only if all of its ancestors are.
+type SceneNode struct {
+	Name       string
+	Transform  Transform
+	Object     Hittable
+	Material   Material
+	Visibility Visibility
+	Children   []*SceneNode
+	parent     *SceneNode
+}
+
+// NewSceneNode returns a node with the identity transform, which is visible to all rays
+func NewSceneNode(name string, object Hittable) *SceneNode {
+	return &SceneNode{Name: name, Transform: IdentityTransform(), Object: object, Visibility: VisibleAll}
+}
+
+func (node *SceneNode) Parent() *SceneNode {
+	return node.parent
+}
+
+// AddChild appends a child, which is detached from its previous parent, and returns it
+func (node *SceneNode) AddChild(child *SceneNode) *SceneNode {
+	if nil != child.parent {
+		child.parent.RemoveChild(child)
+	}
+	child.parent = node
+	node.Children = append(node.Children, child)
+	return child
+}
+
+// RemoveChild detaches a child, false if it is not a child of the node
+func (node *SceneNode) RemoveChild(child *SceneNode) bool {
+	for i, c := range node.Children {
+		if c == child {
+			node.Children = append(node.Children[:i], node.Children[i+1:]...)
+			child.parent = nil
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the first node of the subtree with the name in depth first order, nil if none
+func (node *SceneNode) Find(name string) *SceneNode {
+	if node.Name == name {
+		return node
+	}
+	for _, child := range node.Children {
+		if found := child.Find(name); nil != found {
+			return found
+		}
+	}
+	return nil
+}
+
+// Lookup follows a path of child names separated by '/' from the node, nil if any is missing
+func (node *SceneNode) Lookup(path string) *SceneNode {
+	current := node
+	for _, name := range strings.Split(path, "/") {
+		if 0 == len(name) {
+			continue
+		}
+		var next *SceneNode
+		for _, child := range current.Children {
+			if child.Name == name {
+				next = child
+				break
+			}
+		}
+		if nil == next {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// Path returns the names from the root to the node excluding the root, so that root.Lookup(node.Path()) is the node
+func (node *SceneNode) Path() string {
+	names := []string{}
+	for n := node; nil != n.parent; n = n.parent {
+		names = append([]string{n.Name}, names...)
+	}
+	return strings.Join(names, "/")
+}
+
+// WorldTransform returns the transform from the node to the root
+func (node *SceneNode) WorldTransform() Transform {
+	transform := node.Transform
+	for n := node.parent; nil != n; n = n.parent {
+		transform = ComposeTransform(n.Transform, transform)
+	}
+	return transform
+}
+
+// Flatten collects the objects of the subtree with their accumulated transforms, materials and visibilities into a BVH
+func (node *SceneNode) Flatten() *BVH {
+	hittables, visibilities := node.collect()
+	return NewBVH(hittables, visibilities)
+}
+
+// FlattenMotion flattens the subtree into a motion BVH for the shutter interval of a camera
+func (node *SceneNode) FlattenMotion(time0, time1 float32) *BVH {
+	hittables, visibilities := node.collect()
+	return NewMotionBVH(hittables, visibilities, time0, time1)
+}
+
+func (node *SceneNode) collect() ([]Hittable, []Visibility) {
+	hittables := []Hittable{}
+	visibilities := []Visibility{}
+	var flatten func(n *SceneNode, parent Transform, material Material, visibility Visibility)
+	flatten = func(n *SceneNode, parent Transform, material Material, visibility Visibility) {
+		transform := ComposeTransform(parent, n.Transform)
+		if nil != n.Material {
+			material = n.Material
+		}
+		visibility &= n.Visibility
+		if nil != n.Object && 0 != visibility {
+			object := n.Object
+			if nil != material {
+				object = &materialOverride{object, material}
+			}
+			if transform.Matrix != IdentityMatrix4() {
+				instance := NewInstance(object, transform)
+				object = &instance
+			}
+			hittables = append(hittables, object)
+			visibilities = append(visibilities, visibility)
+		}
+		for _, child := range n.Children {
+			flatten(child, transform, material, visibility)
+		}
+	}
+	parent := IdentityTransform()
+	if nil != node.parent {
+		parent = node.parent.WorldTransform()
+	}
+	flatten(node, parent, nil, VisibleAll)
+	return hittables, visibilities
+}
@@ -0,0 +1,133 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestBVHMatchesList(t *testing.T) {
+	assert := assert.New(t)
+	random := rand.New(rand.NewSource(1))
+	list := NewHittableList()
+	hittables := []Hittable{}
+	for i := 0; i < 200; i++ {
+		center := Vector3{10.0*random.Float32() - 5.0, 10.0*random.Float32() - 5.0, 10.0*random.Float32() - 5.0}
+		sphere := &Sphere{center, 0.1 + 0.3*random.Float32(), &Lambertian{Vector3{0.5, 0.5, 0.5}, nil}}
+		list.AddHittable(sphere)
+		hittables = append(hittables, sphere)
+	}
+	plane := NewPlane(Vector3{0.0, -6.0, 0.0}, Vector3{0.0, 1.0, 0.0}, &Lambertian{Vector3{0.5, 0.5, 0.5}, nil})
+	list.AddHittable(&plane)
+	hittables = append(hittables, &plane)
+	bvh := NewBVH(hittables, nil)
+	for i := 0; i < 500; i++ {
+		origin := Vector3{20.0*random.Float32() - 10.0, 20.0*random.Float32() - 10.0, 20.0*random.Float32() - 10.0}
+		direction := RandomOnSphere(random.Float32(), random.Float32())
+		ray := Ray{origin, direction, 0.0}
+		expected := HitRecord{}
+		actual := HitRecord{}
+		hit := list.Hit(ray, 0.001, Infinity32, &expected)
+		assert.Equal(hit, bvh.Hit(ray, 0.001, Infinity32, &actual))
+		if hit {
+			assert.InDelta(expected.T, actual.T, 1.0e-5)
+		}
+	}
+	box := bvh.BoundingBox()
+	assert.True(Infinity32 <= box.Max.X)
+}
+
+func TestAddHittablesAppends(t *testing.T) {
+	assert := assert.New(t)
+	near := &Sphere{Vector3{0.0, 0.0, -2.0}, 0.5, &Lambertian{Vector3{1.0, 0.0, 0.0}, nil}}
+	far := &Sphere{Vector3{0.0, 0.0, -2.0}, 0.5, &Lambertian{Vector3{0.0, 1.0, 0.0}, nil}}
+	list := NewHittableList()
+	list.AddHittable(near)
+	list.AddHittables([]Hittable{far})
+	assert.Equal(Hittable(near), list.hittables[0])
+	assert.Equal(Hittable(far), list.hittables[1])
+}
+
+func TestSceneNodeLookup(t *testing.T) {
+	assert := assert.New(t)
+	root := NewSceneNode("root", nil)
+	group := root.AddChild(NewSceneNode("group", nil))
+	leaf := group.AddChild(NewSceneNode("leaf", nil))
+	other := root.AddChild(NewSceneNode("other", nil))
+
+	assert.Equal(leaf, root.Find("leaf"))
+	assert.Nil(root.Find("missing"))
+	assert.Equal(leaf, root.Lookup("group/leaf"))
+	assert.Equal(leaf, root.Lookup("/group/leaf/"))
+	assert.Nil(root.Lookup("other/leaf"))
+	assert.Equal("group/leaf", leaf.Path())
+	assert.Equal(leaf, root.Lookup(leaf.Path()))
+
+	other.AddChild(leaf)
+	assert.Equal(0, len(group.Children))
+	assert.Equal(other, leaf.Parent())
+	assert.Equal("other/leaf", leaf.Path())
+	assert.True(other.RemoveChild(leaf))
+	assert.False(other.RemoveChild(leaf))
+	assert.Nil(leaf.Parent())
+}
+
+func TestSceneNodeFlatten(t *testing.T) {
+	assert := assert.New(t)
+	red := &Lambertian{Vector3{1.0, 0.0, 0.0}, nil}
+	green := &Lambertian{Vector3{0.0, 1.0, 0.0}, nil}
+	root := NewSceneNode("root", nil)
+	group := root.AddChild(NewSceneNode("group", nil))
+	group.Transform = Translate(Vector3{0.0, 0.0, -5.0})
+	group.Material = green
+	ball := group.AddChild(NewSceneNode("ball", &Sphere{Vector3{}, 1.0, red}))
+	ball.Transform = Translate(Vector3{1.0, 0.0, 0.0})
+
+	world := ball.WorldTransform()
+	center := world.Point(Vector3{})
+	assert.InDelta(1.0, center.X, 1.0e-5)
+	assert.InDelta(-5.0, center.Z, 1.0e-5)
+
+	bvh := root.Flatten()
+	record := HitRecord{}
+	ray := Ray{Vector3{1.0, 0.0, 0.0}, Vector3{0.0, 0.0, -1.0}, 0.0}
+	assert.True(bvh.Hit(ray, 0.001, Infinity32, &record))
+	assert.InDelta(4.0, record.T, 1.0e-4)
+	assert.Equal(Material(green), record.Material)
+	assert.False(bvh.Hit(Ray{Vector3{}, Vector3{0.0, 0.0, -1.0}, 0.0}, 0.001, Infinity32, &record))
+
+	// The visibility of a group limits its children
+	group.Visibility = VisibleCamera | VisibleReflection
+	bvh = root.Flatten()
+	assert.True(bvh.HitVisible(ray, 0.001, Infinity32, VisibleCamera, &record))
+	assert.False(bvh.HitVisible(ray, 0.001, Infinity32, VisibleShadow, &record))
+	ball.Visibility = VisibleShadow
+	bvh = root.Flatten()
+	assert.False(bvh.Hit(ray, 0.001, Infinity32, &record))
+}
+
+func TestSceneNodeAlphaOverride(t *testing.T) {
+	assert := assert.New(t)
+	red := &Lambertian{Vector3{1.0, 0.0, 0.0}, nil}
+	green := &Lambertian{Vector3{0.0, 1.0, 0.0}, nil}
+	clear := NewAlphaMasked(red, nil)
+	clear.Opacity = 0.0
+	root := NewSceneNode("root", nil)
+	ball := root.AddChild(NewSceneNode("ball", &Sphere{Vector3{0.0, 0.0, -5.0}, 1.0, &clear}))
+	back := root.AddChild(NewSceneNode("back", &Sphere{Vector3{0.0, 0.0, -10.0}, 1.0, red}))
+	ray := Ray{Vector3{}, Vector3{0.0, 0.0, -1.0}, 0.0}
+	record := HitRecord{}
+
+	// The mask of the replaced material does not apply
+	ball.Material = green
+	assert.True(root.Flatten().Hit(ray, 0.001, Infinity32, &record))
+	assert.InDelta(4.0, record.T, 1.0e-4)
+	assert.Equal(Material(green), record.Material)
+
+	// The mask of the node cuts the object out, the ray goes on to the next one
+	ball.Material = &clear
+	ball.Object = &Sphere{Vector3{0.0, 0.0, -5.0}, 1.0, red}
+	assert.True(root.Flatten().Hit(ray, 0.001, Infinity32, &record))
+	assert.InDelta(9.0, record.T, 1.0e-4)
+	back.Material = &clear
+	assert.False(root.Flatten().Hit(ray, 0.001, Infinity32, &record))
+}
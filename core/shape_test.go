@@ -0,0 +1,87 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+// checkAreaSampling shoots rays at the sampled points from outside, they should hit there with the sampled normal
+func checkAreaSampling(assert *assert.Assertions, name string, shape Hittable) {
+	sampler := shape.(AreaSampler)
+	box := shape.BoundingBox()
+	var record HitRecord
+	for i := 0; i < 256; i++ {
+		position, normal := sampler.SampleArea(rand.Float32(), rand.Float32())
+		assert.InDeltaf(1.0, normal.Length(), 1.0e-4, "%v normal should be unit", name)
+		for _, p := range [3]float32{position.X - box.Min.X, position.Y - box.Min.Y, position.Z - box.Min.Z} {
+			assert.Truef(-1.0e-4 <= p, "%v sample should be in the bounds", name)
+		}
+		ray := Ray{AddVector3(position, MulVector3(1.0e-2, normal)), normal.Minus(), 0.0}
+		if !assert.Truef(shape.Hit(ray, 0.0, 1.0, &record), "%v sample %v should be hit", name, position) {
+			continue
+		}
+		assert.InDeltaf(1.0e-2, record.T, 1.0e-3, "%v", name)
+		assert.InDeltaf(1.0, DotVector3(normal, record.Normal), 1.0e-3, "%v normal should match", name)
+		// The partial derivatives are right handed around the normal
+		cross := CrossVector3(record.DPDU, record.DPDV)
+		if 1.0e-6 < cross.Length() {
+			assert.Truef(0.0 < DotVector3(cross, record.Normal), "%v derivatives should be right handed", name)
+		}
+		assert.Truef(0.0 <= record.UV.X && record.UV.X <= 1.0 && 0.0 <= record.UV.Y && record.UV.Y <= 1.0, "%v UV %v", name, record.UV)
+	}
+}
+
+func TestShapeAreaSampling(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(1)
+	quad := NewQuad(Vector3{1.0, 0.0, 0.0}, Vector3{2.0, 0.0, 0.0}, Vector3{0.0, 0.0, -1.0}, nil)
+	disk := NewDisk(Vector3{0.0, 1.0, 0.0}, Vector3{1.0, 1.0, 0.0}, 0.5, nil)
+	box := NewOrientedBox(Vector3{0.0, 0.0, 1.0}, Vector3{0.5, 1.0, 1.5}, Vector3{1.0, 1.0, 0.0}, Vector3{0.0, 0.0, 1.0}, nil)
+	cylinder := NewCylinder(Vector3{0.0, -1.0, 0.0}, Vector3{0.0, 1.0, 1.0}, 0.5, 2.0, nil)
+	cone := NewCone(Vector3{1.0, 0.0, 0.0}, Vector3{1.0, 0.0, 0.0}, 1.0, 1.5, nil)
+	sphere := Sphere{Vector3{0.0, 0.0, 0.0}, 1.0, nil}
+	shapes := map[string]Hittable{"quad": &quad, "disk": &disk, "box": &box, "cylinder": &cylinder, "cone": &cone, "sphere": &sphere}
+	for name, shape := range shapes {
+		checkAreaSampling(assert, name, shape)
+	}
+}
+
+func TestShapeArea(t *testing.T) {
+	assert := assert.New(t)
+	box := NewBox(Vector3{0.0, 0.0, 0.0}, Vector3{1.0, 2.0, 3.0}, nil)
+	assert.InDelta(22.0, box.Area(), 1.0e-4)
+	bounds := box.BoundingBox()
+	assert.True(EqualVector3(Vector3{1.0, 2.0, 3.0}, bounds.Max))
+	cylinder := NewCylinder(Vector3{}, Vector3{0.0, 0.0, 1.0}, 1.0, 2.0, nil)
+	assert.InDelta(6.0*3.14159265, cylinder.Area(), 1.0e-4)
+	cone := NewCone(Vector3{}, Vector3{0.0, 0.0, 1.0}, 3.0, 4.0, nil)
+	assert.InDelta(24.0*3.14159265, cone.Area(), 1.0e-4)
+}
+
+func TestPlane(t *testing.T) {
+	assert := assert.New(t)
+	plane := NewPlane(Vector3{0.0, -1.0, 0.0}, Vector3{0.0, 1.0, 0.0}, nil)
+	var record HitRecord
+	ray := Ray{Vector3{3.0, 1.0, 2.0}, NormalizeVector3(Vector3{1.0, -1.0, 0.0}), 0.0}
+	assert.True(plane.Hit(ray, 0.0, 100.0, &record))
+	assert.InDelta(-1.0, record.Position.Y, 1.0e-5)
+	assert.True(EqualVector3(Vector3{0.0, 1.0, 0.0}, record.Normal))
+	ray = Ray{Vector3{3.0, 1.0, 2.0}, Vector3{1.0, 0.0, 0.0}, 0.0}
+	assert.False(plane.Hit(ray, 0.0, 100.0, &record))
+	bounds := plane.BoundingBox()
+	assert.False(bounds.IsEmpty())
+}
+
+func TestQuadricInside(t *testing.T) {
+	assert := assert.New(t)
+	cylinder := NewCylinder(Vector3{}, Vector3{0.0, 1.0, 0.0}, 1.0, 2.0, nil)
+	var record HitRecord
+	// From the inside the far side is hit with the outward normal
+	ray := Ray{Vector3{0.0, 1.0, 0.0}, Vector3{1.0, 0.0, 0.0}, 0.0}
+	assert.True(cylinder.Hit(ray, 0.0, 10.0, &record))
+	assert.InDelta(1.0, record.T, 1.0e-5)
+	assert.InDelta(1.0, record.Normal.X, 1.0e-5)
+	ray = Ray{Vector3{0.0, 1.0, 0.0}, Vector3{0.0, 1.0, 0.0}, 0.0}
+	assert.True(cylinder.Hit(ray, 0.0, 10.0, &record))
+	assert.InDelta(1.0, record.Normal.Y, 1.0e-5)
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// charlieAlpha maps the perceptual roughness of sheen to alpha
+func charlieAlpha(roughness float32) float32 {
+	return math32.Max(0.001, roughness*roughness)
+}
+
+// CharlieD Charlie sheen distribution of the cosine of the microfacet normal
+//
+// Alejandro Conty Estevez, Christopher Kulla, "Production Friendly Microfacet Sheen BRDF", 2017
+func CharlieD(cosine, roughness float32) float32 {
+	invAlpha := 1.0 / charlieAlpha(roughness)
+	sin2 := math32.Max(0.0, 1.0-cosine*cosine)
+	return (2.0 + invAlpha) * math32.Pow(sin2, 0.5*invAlpha) / (2.0 * math32.Pi)
+}
+
+// SheenVisibility visibility term of the sheen, which replaces G/(4 cos cos)
+//
+// David Neubelt, Matt Pettineo, "Crafting a Next-Gen Material Pipeline for The Order: 1886", SIGGRAPH 2013
+func SheenVisibility(cosI, cosO float32) float32 {
+	return 1.0 / (4.0 * (cosI + cosO - cosI*cosO))
+}
+
+// charlieSheen BRDF of a white sheen
+func charlieSheen(wi, wo Vector3, roughness float32) float32 {
+	wm := NormalizeVector3(AddVector3(wi, wo))
+	return CharlieD(wm.Z, roughness) * SheenVisibility(wi.Z, wo.Z)
+}
+
+// SheenMaterial is implemented by materials with a sheen lobe, for the split-sum preview
+type SheenMaterial interface {
+	GetSheen() (Vector3, float32)
+}
+
+// Sheen Charlie sheen lobe for velvet and cloth, sampled by the cosine weighted hemisphere
+type Sheen struct {
+	Color        Vector3
+	Roughness    float32
+	ColorTexture Texture
+}
+
+func NewSheen(color Vector3, roughness float32) Sheen {
+	return Sheen{color, roughness, nil}
+}
+
+func (sheen *Sheen) Resolve(hitRecord *HitRecord) Material {
+	if sheen.ColorTexture == nil {
+		return sheen
+	}
+	return &Sheen{ModulateColor(sheen.Color, sheen.ColorTexture, hitRecord), sheen.Roughness, nil}
+}
+
+func (sheen *Sheen) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	if wi.Z <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	wo := RandomOnCosineHemiSphere(eta0, eta1)
+	pdf := sheen.Pdf(wi, wo)
+	if pdf <= Epsilon32 {
+		return MaterialSample{false, 0.0, Vector3{}, Vector3{}}
+	}
+	return MaterialSample{true, pdf, DivVector3(sheen.Eval(wi, wo), pdf), wo}
+}
+
+func (sheen *Sheen) Eval(wi, wo Vector3) Vector3 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return Vector3{}
+	}
+	return MulVector3(charlieSheen(wi, wo, sheen.Roughness)*wo.Z, sheen.Color)
+}
+
+func (sheen *Sheen) Pdf(wi, wo Vector3) float32 {
+	if wi.Z <= Epsilon32 || wo.Z <= Epsilon32 {
+		return 0.0
+	}
+	return wo.Z / math32.Pi
+}
+
+func (sheen *Sheen) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return scatterBySample(sheen, ray, hitRecord, attenuation, scattered)
+}
+
+func (sheen *Sheen) GetRoughness() float32 {
+	return sheen.Roughness
+}
+
+func (sheen *Sheen) GetMetallic() float32 {
+	return 0.0
+}
+
+// GetAlbedo returns black, the sheen is previewed through GetSheen
+func (sheen *Sheen) GetAlbedo() Vector3 {
+	return Vector3{}
+}
+
+func (sheen *Sheen) GetSheen() (Vector3, float32) {
+	return sheen.Color, sheen.Roughness
+}
+
+// SheenAlbedo directional albedo of a white sheen estimated by the cosine weighted samples
+func SheenAlbedo(cosine, roughness float32, samples int32) float32 {
+	cosine = math32.Max(Epsilon32, cosine)
+	wi := Vector3{math32.Sqrt(math32.Max(0.0, 1.0-cosine*cosine)), 0.0, cosine}
+	r2 := NewSamplerR2(0)
+	total := float32(0.0)
+	for s := int32(0); s < samples; s++ {
+		sample := r2.Generate2(s)
+		wo := RandomOnCosineHemiSphere(sample.X, sample.Y)
+		if wo.Z <= Epsilon32 {
+			continue
+		}
+		total += math32.Pi * charlieSheen(wi, wo, roughness)
+	}
+	return total / float32(samples)
+}
+
+// GenSheenLUT tabulates SheenAlbedo, the roughness along x and the cosine of the view along y
+func GenSheenLUT(width, height int32) SphereMap {
+	const samples int32 = 1024
+	image := make([]Vector3, width*height)
+	invw := 1.0/float32(width-1)
+	invh := 1.0/float32(height-1)
+	for i := int32(0); i < height; i++ {
+		NV := invh * float32(i)
+		for j := int32(0); j < width; j++ {
+			roughness := invw * float32(j)
+			albedo := SheenAlbedo(NV, roughness, samples)
+			image[i*width+j] = Vector3{albedo, albedo, albedo}
+		}
+	}
+	return SphereMap{width, height, image, nil}
+}
+
+// SampleSheenLUT looks up the table of GenSheenLUT
+func SampleSheenLUT(roughness, NV float32, lut *SphereMap) float32 {
+	x := Saturate32(roughness) * float32(lut.Width-1)
+	y := Saturate32(NV) * float32(lut.Height-1)
+	return lut.Pixel(x, y).X
+}
@@ -0,0 +1,44 @@
+package core
+import (
+	"testing"
+	"math/rand"
+	"git.maze.io/go/math32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCharlieNormalized(t *testing.T) {
+	assert := assert.New(t)
+	const N = 4000
+	for _, roughness := range []float32{0.3, 0.6, 1.0} {
+		// Projected area of the microfacets is one
+		total := float32(0.0)
+		for i := 0; i < N; i++ {
+			cosine := (float32(i) + 0.5) / N
+			total += 2.0 * math32.Pi * CharlieD(cosine, roughness) * cosine / N
+		}
+		assert.InDeltaf(1.0, total, 1.0e-2, "Charlie distribution of %v should be normalized", roughness)
+	}
+}
+
+func TestSheen(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(9)
+	sheen := NewSheen(Vector3{0.8, 0.4, 0.2}, 0.5)
+	wi := NormalizeVector3(Vector3{0.4, 0.1, 0.6})
+	for i := 0; i < 100; i++ {
+		sample := sheen.Sample(wi, rand.Float32(), rand.Float32())
+		if !sample.Continue {
+			continue
+		}
+		expected := DivVector3(sheen.Eval(wi, sample.Scattered), sheen.Pdf(wi, sample.Scattered))
+		assert.Truef(EqualVector3(expected, sample.Weight), "Weight %v should be %v", sample.Weight, expected)
+	}
+
+	for _, cosine := range []float32{0.1, 0.5, 1.0} {
+		albedo := SheenAlbedo(cosine, 0.5, 1024)
+		assert.Truef(0.0 < albedo && albedo <= 1.0, "Albedo %v at %v should be in (0 1]", albedo, cosine)
+	}
+
+	lut := GenSheenLUT(5, 5)
+	assert.InDelta(SheenAlbedo(0.5, 0.25, 1024), SampleSheenLUT(0.25, 0.5, &lut), 1.0e-5)
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// SpectralSamples the number of wavelengths carried by a path
+const SpectralSamples int = 4
+
+type SampledSpectrum [SpectralSamples]float32
+
+func NewSampledSpectrum(value float32) SampledSpectrum {
+	var spectrum SampledSpectrum
+	for i := range spectrum {
+		spectrum[i] = value
+	}
+	return spectrum
+}
+
+func AddSampledSpectrum(x0, x1 SampledSpectrum) SampledSpectrum {
+	for i := range x0 {
+		x0[i] += x1[i]
+	}
+	return x0
+}
+
+func MulSampledSpectrum(x0, x1 SampledSpectrum) SampledSpectrum {
+	for i := range x0 {
+		x0[i] *= x1[i]
+	}
+	return x0
+}
+
+func ScaleSampledSpectrum(x0 float32, x1 SampledSpectrum) SampledSpectrum {
+	for i := range x1 {
+		x1[i] *= x0
+	}
+	return x1
+}
+
+func MaxElementSampledSpectrum(x SampledSpectrum) float32 {
+	m := x[0]
+	for i := 1; i < SpectralSamples; i++ {
+		m = math32.Max(m, x[i])
+	}
+	return m
+}
+
+func (x *SampledSpectrum) IsZero() bool {
+	for i := range x {
+		if x[i] != 0.0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SampledWavelengths wavelengths of a path in nanometers and their pdfs
+//
+// The first one is the hero wavelength, the others are rotated by equal offsets in the visible range.
+// Alexander Wilkie, Sehera Nawaz, Marc Droske, Andrea Weidlich, Johannes Hanika, "Hero Wavelength Spectral Sampling", EGSR 2014
+type SampledWavelengths struct {
+	Lambda [SpectralSamples]float32
+	Pdf    [SpectralSamples]float32
+}
+
+func SampleWavelengths(u float32) SampledWavelengths {
+	var wavelengths SampledWavelengths
+	span := CIELambdaMax - CIELambdaMin
+	for i := 0; i < SpectralSamples; i++ {
+		x := u + float32(i)/float32(SpectralSamples)
+		x -= math32.Floor(x)
+		wavelengths.Lambda[i] = CIELambdaMin + x*span
+		wavelengths.Pdf[i] = 1.0 / span
+	}
+	return wavelengths
+}
+
+// TerminateSecondary keeps only the hero wavelength, for the events which scatter each wavelength in a different direction
+func (wavelengths *SampledWavelengths) TerminateSecondary() {
+	if wavelengths.SecondaryTerminated() {
+		return
+	}
+	for i := 1; i < SpectralSamples; i++ {
+		wavelengths.Pdf[i] = 0.0
+	}
+	wavelengths.Pdf[0] /= float32(SpectralSamples)
+}
+
+func (wavelengths *SampledWavelengths) SecondaryTerminated() bool {
+	for i := 1; i < SpectralSamples; i++ {
+		if 0.0 < wavelengths.Pdf[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ToXYZ estimates CIE XYZ of a spectrum sampled at the wavelengths
+func (wavelengths *SampledWavelengths) ToXYZ(spectrum SampledSpectrum) Vector3 {
+	xyz := Vector3{}
+	for i := 0; i < SpectralSamples; i++ {
+		if wavelengths.Pdf[i] <= 0.0 {
+			continue
+		}
+		cmf := CIEColorMatching(wavelengths.Lambda[i])
+		xyz = AddVector3(xyz, MulVector3(spectrum[i]/wavelengths.Pdf[i], cmf))
+	}
+	return DivVector3(xyz, float32(SpectralSamples))
+}
+
+// cieWhiteRGB linear sRGB of the constant unit spectrum over the visible range
+var cieWhiteRGB = func() Vector3 {
+	white := Vector3{}
+	for lambda := CIELambdaMin + 0.5; lambda < CIELambdaMax; lambda += 1.0 {
+		white = AddVector3(white, CIEColorMatching(lambda))
+	}
+	return XYZToLinearSRGB(white)
+}()
+
+// SpectralXYZToRGB converts XYZ estimated by ToXYZ to linear sRGB with the same normalization as ReflectanceToRGB
+func SpectralXYZToRGB(xyz Vector3) Vector3 {
+	rgb := XYZToLinearSRGB(xyz)
+	return Vector3{rgb.X / cieWhiteRGB.X, rgb.Y / cieWhiteRGB.Y, rgb.Z / cieWhiteRGB.Z}
+}
+
+// Basis spectra of RGBToSpectrum in 10 bins from 380 to 720 nanometers
+var (
+	smitsWhite   = [10]float32{1.0000, 1.0000, 0.9999, 0.9993, 0.9992, 0.9998, 1.0000, 1.0000, 1.0000, 1.0000}
+	smitsCyan    = [10]float32{0.9710, 0.9426, 1.0007, 1.0007, 1.0007, 1.0007, 0.1564, 0.0000, 0.0000, 0.0000}
+	smitsMagenta = [10]float32{1.0000, 1.0000, 0.9685, 0.2229, 0.0000, 0.0458, 0.8369, 1.0000, 1.0000, 0.9959}
+	smitsYellow  = [10]float32{0.0001, 0.0000, 0.1088, 0.6651, 1.0000, 1.0000, 0.9996, 0.9586, 0.9685, 0.9840}
+	smitsRed     = [10]float32{0.1012, 0.0515, 0.0000, 0.0000, 0.0000, 0.0000, 0.8325, 1.0149, 1.0149, 1.0149}
+	smitsGreen   = [10]float32{0.0000, 0.0000, 0.0273, 0.7937, 1.0000, 0.9418, 0.1719, 0.0000, 0.0000, 0.0025}
+	smitsBlue    = [10]float32{1.0000, 1.0000, 0.8916, 0.3323, 0.0000, 0.0000, 0.0003, 0.0369, 0.0483, 0.0496}
+)
+
+func smitsBasis(basis *[10]float32, lambda float32) float32 {
+	x := (lambda-380.0)/34.0 - 0.5
+	if x <= 0.0 {
+		return basis[0]
+	}
+	if 9.0 <= x {
+		return basis[9]
+	}
+	i := int(x)
+	return Lerp32(basis[i], basis[i+1], x-float32(i))
+}
+
+// RGBToSpectrum upsamples linear sRGB to a smooth spectrum and evaluates it at a wavelength
+//
+// The basis fits reflectances in [0 1] and scales with the components. It is only piecewise linear,
+// so the spectrum of a product of colors differs from the product of their spectra.
+// Brian Smits, "An RGB-to-Spectrum Conversion for Reflectances", Journal of Graphics Tools 1999
+func RGBToSpectrum(rgb Vector3, lambda float32) float32 {
+	r, g, b := rgb.X, rgb.Y, rgb.Z
+	basis := func(spectrum *[10]float32) float32 {
+		return smitsBasis(spectrum, lambda)
+	}
+	if r <= g && r <= b {
+		if g <= b {
+			return r*basis(&smitsWhite) + (g-r)*basis(&smitsCyan) + (b-g)*basis(&smitsBlue)
+		}
+		return r*basis(&smitsWhite) + (b-r)*basis(&smitsCyan) + (g-b)*basis(&smitsGreen)
+	}
+	if g <= r && g <= b {
+		if r <= b {
+			return g*basis(&smitsWhite) + (r-g)*basis(&smitsMagenta) + (b-r)*basis(&smitsBlue)
+		}
+		return g*basis(&smitsWhite) + (b-g)*basis(&smitsMagenta) + (r-b)*basis(&smitsRed)
+	}
+	if r <= g {
+		return b*basis(&smitsWhite) + (r-b)*basis(&smitsYellow) + (g-r)*basis(&smitsGreen)
+	}
+	return b*basis(&smitsWhite) + (g-b)*basis(&smitsYellow) + (r-g)*basis(&smitsRed)
+}
+
+// UpsampleRGB evaluates RGBToSpectrum at the wavelengths
+func UpsampleRGB(rgb Vector3, wavelengths *SampledWavelengths) SampledSpectrum {
+	var spectrum SampledSpectrum
+	for i := 0; i < SpectralSamples; i++ {
+		spectrum[i] = RGBToSpectrum(rgb, wavelengths.Lambda[i])
+	}
+	return spectrum
+}
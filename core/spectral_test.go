@@ -0,0 +1,57 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRGBToSpectrum(t *testing.T) {
+	assert := assert.New(t)
+	colors := []Vector3{{1.0, 1.0, 1.0}, {1.0, 0.0, 0.0}, {0.0, 1.0, 0.0}, {0.0, 0.0, 1.0}, {0.2, 0.5, 0.8}}
+	for _, color := range colors {
+		rgb := ReflectanceToRGB(400, func(lambda float32) float32 {
+			return RGBToSpectrum(color, lambda)
+		})
+		assert.InDeltaf(color.X, rgb.X, 0.05, "%v", color)
+		assert.InDeltaf(color.Y, rgb.Y, 0.05, "%v", color)
+		assert.InDeltaf(color.Z, rgb.Z, 0.05, "%v", color)
+	}
+}
+
+func TestSpectralEstimate(t *testing.T) {
+	assert := assert.New(t)
+	const samples = 10000
+	color := Vector3{0.2, 0.5, 0.8}
+	sum := Vector3{}
+	hero := Vector3{}
+	for i := 0; i < samples; i++ {
+		wavelengths := SampleWavelengths((float32(i) + 0.5) / samples)
+		sum = AddVector3(sum, SpectralXYZToRGB(wavelengths.ToXYZ(UpsampleRGB(color, &wavelengths))))
+		wavelengths.TerminateSecondary()
+		hero = AddVector3(hero, SpectralXYZToRGB(wavelengths.ToXYZ(UpsampleRGB(color, &wavelengths))))
+	}
+	sum = DivVector3(sum, samples)
+	hero = DivVector3(hero, samples)
+	assert.InDelta(color.X, sum.X, 0.02)
+	assert.InDelta(color.Y, sum.Y, 0.02)
+	assert.InDelta(color.Z, sum.Z, 0.02)
+	assert.InDeltaf(color.X, hero.X, 0.02, "The hero only estimate should keep the mean")
+	assert.InDeltaf(color.Y, hero.Y, 0.02, "The hero only estimate should keep the mean")
+	assert.InDeltaf(color.Z, hero.Z, 0.02, "The hero only estimate should keep the mean")
+}
+
+func TestDispersion(t *testing.T) {
+	assert := assert.New(t)
+	assert.InDeltaf(1.5168, NewSellmeierBK7().IOR(587.6), 1.0e-3, "BK7 at the d line")
+	assert.InDeltaf(2.417, NewSellmeierDiamond().IOR(589.0), 1.0e-2, "Diamond at the sodium line")
+	cauchy := &Cauchy{1.5046, 0.0042}
+	assert.Truef(cauchy.IOR(450.0) > cauchy.IOR(650.0), "Blue should bend more")
+
+	dielectric := &Dielectric{Albedo: Vector3{1.0, 1.0, 1.0}, RefIndex: 1.5}
+	_, disperses := dielectric.AtWavelength(500.0)
+	assert.False(disperses)
+	dielectric.Dispersion = cauchy
+	material, disperses := dielectric.AtWavelength(500.0)
+	assert.True(disperses)
+	assert.InDelta(cauchy.IOR(500.0), material.(*Dielectric).RefIndex, 1.0e-6)
+	assert.Equal(float32(1.5), dielectric.RefIndex)
+}
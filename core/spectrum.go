@@ -0,0 +1,57 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+const (
+	CIELambdaMin float32 = 380.0
+	CIELambdaMax float32 = 780.0
+)
+
+func cieLobe(lambda, mu, sigma0, sigma1 float32) float32 {
+	var t float32
+	if lambda < mu {
+		t = (lambda - mu) / sigma0
+	} else {
+		t = (lambda - mu) / sigma1
+	}
+	return math32.Exp(-0.5 * t * t)
+}
+
+// CIEColorMatching CIE 1931 2 degree color matching functions x, y and z at a wavelength in nanometers
+//
+// Chris Wyman, Peter-Pike Sloan, Peter Shirley, "Simple Analytic Approximations to the CIE XYZ Color Matching Functions", JCGT 2013
+func CIEColorMatching(lambda float32) Vector3 {
+	x := 1.056*cieLobe(lambda, 599.8, 37.9, 31.0) + 0.362*cieLobe(lambda, 442.0, 16.0, 26.7) - 0.065*cieLobe(lambda, 501.1, 20.4, 26.2)
+	y := 0.821*cieLobe(lambda, 568.8, 46.9, 40.5) + 0.286*cieLobe(lambda, 530.9, 16.3, 31.1)
+	z := 1.217*cieLobe(lambda, 437.0, 11.8, 36.0) + 0.681*cieLobe(lambda, 459.0, 26.0, 13.8)
+	return Vector3{x, y, z}
+}
+
+// XYZToLinearSRGB converts CIE XYZ to linear sRGB (Rec.709 primaries, D65 white)
+func XYZToLinearSRGB(xyz Vector3) Vector3 {
+	return Vector3{
+		3.2404542*xyz.X - 1.5371385*xyz.Y - 0.4985314*xyz.Z,
+		-0.9692660*xyz.X + 1.8760108*xyz.Y + 0.0415560*xyz.Z,
+		0.0556434*xyz.X - 0.2040259*xyz.Y + 1.0572252*xyz.Z}
+}
+
+// ReflectanceToRGB integrates a reflectance spectrum against the color matching functions at the midpoints of samples
+//
+// The result is normalized per channel, so that a constant reflectance maps to the same value in all channels,
+// and clamped to [0 1] since saturated spectra fall out of the gamut of sRGB.
+func ReflectanceToRGB(samples int32, reflectance func(lambda float32) float32) Vector3 {
+	step := (CIELambdaMax - CIELambdaMin) / float32(samples)
+	xyz := Vector3{}
+	white := Vector3{}
+	for i := int32(0); i < samples; i++ {
+		lambda := CIELambdaMin + (float32(i)+0.5)*step
+		cmf := CIEColorMatching(lambda)
+		xyz = AddVector3(xyz, MulVector3(reflectance(lambda), cmf))
+		white = AddVector3(white, cmf)
+	}
+	rgb := XYZToLinearSRGB(xyz)
+	whiteRGB := XYZToLinearSRGB(white)
+	return SaturateVector3(Vector3{rgb.X / whiteRGB.X, rgb.Y / whiteRGB.Y, rgb.Z / whiteRGB.Z})
+}
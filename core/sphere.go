@@ -24,20 +24,89 @@ func (sphere *Sphere) Hit(ray Ray, tmin float32, tmax float32, record *HitRecord
 	discriminant = math32.Sqrt(discriminant)
 	t := (-b - discriminant)*inva
 	if tmin < t && t < tmax {
-		record.T = t
-		record.Position = ray.PointAt(t)
-		record.Normal = DivVector3(SubVector3(record.Position, sphere.Center), sphere.Radius)
-		record.Material = sphere.Material
-		return true
+		sphere.setRecord(ray, t, record)
+		if alphaTest(record) {
+			return true
+		}
 	}
 	t = (-b + discriminant)*inva
 	if tmin < t && t < tmax {
-		record.T = t
-		record.Position = ray.PointAt(t)
-		record.Normal = DivVector3(SubVector3(record.Position, sphere.Center), sphere.Radius)
-		record.Material = sphere.Material
-		return true
+		sphere.setRecord(ray, t, record)
+		return alphaTest(record)
 	}
 	return false
 }
 
+// Intervals returns the chord of the ray through the sphere
+func (sphere *Sphere) Intervals(ray Ray, intervals []Interval) []Interval {
+	oc := SubVector3(ray.Origin, sphere.Center)
+	a := DotVector3(ray.Direction, ray.Direction)
+	b := DotVector3(oc, ray.Direction)
+	c := DotVector3(oc, oc) - sphere.Radius*sphere.Radius
+	discriminant := b*b - a*c
+	if discriminant <= 0.0 {
+		return intervals
+	}
+	discriminant = math32.Sqrt(discriminant)
+	interval := Interval{}
+	sphere.setRecord(ray, (-b-discriminant)/a, &interval.Entry)
+	sphere.setRecord(ray, (-b+discriminant)/a, &interval.Exit)
+	return append(intervals, interval)
+}
+
+func (sphere *Sphere) BoundingBox() AABB {
+	radius := math32.Abs(sphere.Radius)
+	extent := Vector3{radius, radius, radius}
+	return AABB{SubVector3(sphere.Center, extent), AddVector3(sphere.Center, extent)}
+}
+
+func (sphere *Sphere) Area() float32 {
+	return 4.0 * math32.Pi * sphere.Radius * sphere.Radius
+}
+
+func (sphere *Sphere) SampleArea(u0, u1 float32) (Vector3, Vector3) {
+	normal := RandomOnSphere(u0, u1)
+	return AddVector3(sphere.Center, MulVector3(sphere.Radius, normal)), normal
+}
+
+func (sphere *Sphere) setRecord(ray Ray, t float32, record *HitRecord) {
+	record.T = t
+	record.Position = ray.PointAt(t)
+	record.Normal = DivVector3(SubVector3(record.Position, sphere.Center), sphere.Radius)
+	record.GeometricNormal = record.Normal
+	record.Tangent = sphereTangent(record.Normal)
+	record.UV = sphereUV(record.Normal)
+	record.DPDU, record.DPDV = sphereDerivatives(record.Normal, sphere.Radius)
+	record.DNDU = DivVector3(record.DPDU, sphere.Radius)
+	record.DNDV = DivVector3(record.DPDV, sphere.Radius)
+	record.Color = Vector3{1.0, 1.0, 1.0}
+	record.Material = sphere.Material
+}
+
+// sphereTangent direction of increasing longitude around the Y axis
+func sphereTangent(normal Vector3) Vector3 {
+	tangent := Vector3{normal.Z, 0.0, -normal.X}
+	if tangent.LengthSqr() <= Epsilon32 {
+		tangent, _ = OrthonormalBasis(normal)
+		return tangent
+	}
+	return NormalizeVector3(tangent)
+}
+
+// sphereUV longitude around the Y axis and latitude from the bottom
+func sphereUV(normal Vector3) Vector2 {
+	theta := math32.Acos(math32.Max(-1.0, math32.Min(1.0, -normal.Y)))
+	phi := math32.Atan2(-normal.Z, normal.X) + math32.Pi
+	return Vector2{phi / (2.0 * math32.Pi), theta / math32.Pi}
+}
+
+// sphereDerivatives partial derivatives of the position by the UV of sphereUV
+func sphereDerivatives(normal Vector3, radius float32) (Vector3, Vector3) {
+	dpdu := MulVector3(2.0*math32.Pi*radius, Vector3{normal.Z, 0.0, -normal.X})
+	sinTheta := math32.Sqrt(normal.X*normal.X + normal.Z*normal.Z)
+	if sinTheta <= Epsilon32 {
+		return dpdu, Vector3{}
+	}
+	dndtheta := Vector3{-normal.Y * normal.X / sinTheta, sinTheta, -normal.Y * normal.Z / sinTheta}
+	return dpdu, MulVector3(math32.Pi*radius, dndtheta)
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"math/rand"
+)
+
+const subsurfaceMaxSteps int = 256
+
+// RandomWalker is implemented by materials which transport light inside their closed volumes
+//
+// RandomWalk walks from the ray entering the volume until it reaches the boundary,
+// then returns the ray towards the boundary from the last scattering position and the throughput weight.
+// The boundary is a surface of the material, the walk passes through the other surfaces inside the volume.
+type RandomWalker interface {
+	RandomWalk(world World, ray Ray) (Ray, Vector3, bool)
+}
+
+// Subsurface dielectric boundary over a homogeneous scattering volume, which is traced by random walks
+//
+// MeanFreePath is the average distance between the collisions for each channel,
+// Albedo is the single scattering albedo and Anisotropy is g of the Henyey-Greenstein phase function.
+// Pixar, "Path Traced Subsurface Scattering using Anisotropic Phase Functions and Non-Exponential Free Flights", Technical memo 17-07
+type Subsurface struct {
+	Albedo       Vector3
+	MeanFreePath Vector3
+	Anisotropy   float32
+	IOR          float32
+	Roughness    float32
+}
+
+func NewSubsurface(albedo, meanFreePath Vector3, ior float32) Subsurface {
+	return Subsurface{albedo, meanFreePath, 0.0, ior, 0.0}
+}
+
+func (subsurface *Subsurface) boundary() RoughDielectric {
+	return RoughDielectric{Albedo: Vector3{1.0, 1.0, 1.0}, Roughness: subsurface.Roughness, RefIndex: subsurface.IOR}
+}
+
+func (subsurface *Subsurface) extinction() Vector3 {
+	inverse := func(x float32) float32 {
+		return 1.0 / math32.Max(Epsilon32, x)
+	}
+	return Vector3{inverse(subsurface.MeanFreePath.X), inverse(subsurface.MeanFreePath.Y), inverse(subsurface.MeanFreePath.Z)}
+}
+
+// medium returns the volume as a homogeneous medium
+func (subsurface *Subsurface) medium() HomogeneousMedium {
+	sigmaT := subsurface.extinction()
+	sigmaS := HadamardDotVector3(sigmaT, subsurface.Albedo)
+	return NewHomogeneousMedium(SubVector3(sigmaT, sigmaS), sigmaS, subsurface.Anisotropy)
+}
+
+// bounds reports whether a material, or the one it wraps, is the subsurface
+func (subsurface *Subsurface) bounds(material Material) bool {
+	for {
+		if material == Material(subsurface) {
+			return true
+		}
+		wrapper, ok := material.(MaterialWrapper)
+		if !ok {
+			return false
+		}
+		material = wrapper.Unwrap()
+	}
+}
+
+// boundaryHit finds the next surface of the volume visible to scattered rays, past the surfaces of other materials
+func (subsurface *Subsurface) boundaryHit(world World, ray Ray, hitRecord *HitRecord) bool {
+	tmin := float32(0.001)
+	for step := 0; step < subsurfaceMaxSteps; step++ {
+		if !world.HitVisible(ray, tmin, Infinity32, VisibleReflection, hitRecord) {
+			return false
+		}
+		if subsurface.bounds(hitRecord.Material) {
+			return true
+		}
+		tmin = hitRecord.T
+	}
+	return false
+}
+
+func (subsurface *Subsurface) RandomWalk(world World, ray Ray) (Ray, Vector3, bool) {
+	medium := subsurface.medium()
+	weight := Vector3{1.0, 1.0, 1.0}
+	hitRecord := HitRecord{}
+	for step := 0; step < subsurfaceMaxSteps; step++ {
+		if !subsurface.boundaryHit(world, ray, &hitRecord) {
+			// The volume is not closed
+			return ray, Vector3{}, false
+		}
+		t, sampleWeight, scattered := medium.Sample(ray, hitRecord.T)
+		weight = HadamardDotVector3(weight, sampleWeight)
+		if !scattered {
+			return ray, weight, true
+		}
+
+		survival, alive := russianRoulette(step, weight)
+		if !alive {
+			return ray, Vector3{}, false
+		}
+		weight = DivVector3(weight, survival)
+		direction, _ := medium.Phase.Sample(NormalizeVector3(ray.Direction), rand.Float32(), rand.Float32())
+		ray = Ray{ray.PointAt(t), direction, ray.Time}
+	}
+	return ray, Vector3{}, false
+}
+
+func (subsurface *Subsurface) Resolve(hitRecord *HitRecord) Material {
+	return subsurface
+}
+
+func (subsurface *Subsurface) Sample(wi Vector3, eta0, eta1 float32) MaterialSample {
+	boundary := subsurface.boundary()
+	return boundary.Sample(wi, eta0, eta1)
+}
+
+func (subsurface *Subsurface) Eval(wi, wo Vector3) Vector3 {
+	boundary := subsurface.boundary()
+	return boundary.Eval(wi, wo)
+}
+
+func (subsurface *Subsurface) Pdf(wi, wo Vector3) float32 {
+	boundary := subsurface.boundary()
+	return boundary.Pdf(wi, wo)
+}
+
+// Scatter handles only the boundary, the volume needs RandomWalk with the scene
+func (subsurface *Subsurface) Scatter(ray *Ray, hitRecord *HitRecord, attenuation *Vector3, scattered *Ray) bool {
+	return scatterBySample(subsurface, ray, hitRecord, attenuation, scattered)
+}
+
+func (subsurface *Subsurface) GetRoughness() float32 {
+	return subsurface.Roughness
+}
+
+func (subsurface *Subsurface) GetMetallic() float32 {
+	return 0.0
+}
+
+func (subsurface *Subsurface) GetAlbedo() Vector3 {
+	return subsurface.Albedo
+}
@@ -0,0 +1,96 @@
+package core
+import (
+	"testing"
+	"math/rand"
+	"git.maze.io/go/math32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHenyeyGreenstein(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(3)
+	for _, g := range []float32{-0.5, 0.0, 0.7} {
+		// Integrates to one over the sphere
+		const N = 10000
+		integral := float32(0.0)
+		for i := 0; i < N; i++ {
+			cosine := -1.0 + 2.0*(float32(i)+0.5)/N
+			integral += 2.0 * math32.Pi * HenyeyGreenstein(cosine, g) * 2.0 / N
+		}
+		assert.InDeltaf(1.0, integral, 1.0e-2, "Phase function of g=%v should be normalized", g)
+
+		// Mean cosine is g
+		direction := NormalizeVector3(Vector3{0.3, -0.2, 0.9})
+		mean := float32(0.0)
+		for i := 0; i < 20000; i++ {
+			mean += DotVector3(direction, SampleHenyeyGreenstein(direction, g, rand.Float32(), rand.Float32()))
+		}
+		assert.InDeltaf(g, mean/20000.0, 2.0e-2, "Mean cosine should be %v", g)
+	}
+}
+
+func TestSubsurfaceRandomWalk(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(4)
+	subsurface := Subsurface{Vector3{1.0, 1.0, 1.0}, Vector3{0.3, 0.3, 0.3}, 0.3, 1.4, 0.0}
+	sphere := Sphere{Vector3{0.0, 0.0, 0.0}, 1.0, &subsurface}
+	world := NewHittableList()
+	world.AddHittable(&sphere)
+
+	const N = 4000
+	total := Vector3{}
+	for i := 0; i < N; i++ {
+		ray := Ray{Vector3{0.0, 0.0, 1.0}, Vector3{0.0, 0.0, -1.0}, 0.0}
+		exit, weight, alive := subsurface.RandomWalk(&world, ray)
+		if !alive {
+			continue
+		}
+		var hitRecord HitRecord
+		assert.True(sphere.Hit(exit, 0.001, Infinity32, &hitRecord), "Exit ray should reach the boundary")
+		total = AddVector3(total, weight)
+	}
+	// Without absorption all of the energy reaches the boundary
+	mean := DivVector3(total, N)
+	assert.InDelta(1.0, mean.X, 0.05)
+	assert.InDelta(1.0, mean.Y, 0.05)
+	assert.InDelta(1.0, mean.Z, 0.05)
+
+	subsurface.Albedo = Vector3{0.5, 0.5, 0.5}
+	total = Vector3{}
+	for i := 0; i < N; i++ {
+		ray := Ray{Vector3{0.0, 0.0, 1.0}, Vector3{0.0, 0.0, -1.0}, 0.0}
+		_, weight, alive := subsurface.RandomWalk(&world, ray)
+		if alive {
+			total = AddVector3(total, weight)
+		}
+	}
+	assert.Less(total.X/N, float32(0.5), "Absorption should remove the energy")
+}
+
+func TestSubsurfaceRandomWalkInclusions(t *testing.T) {
+	assert := assert.New(t)
+	rand.Seed(5)
+	subsurface := Subsurface{Vector3{1.0, 1.0, 1.0}, Vector3{0.05, 0.05, 0.05}, 0.0, 1.4, 0.0}
+	lambertian := NewLambertian(Vector3{0.5, 0.5, 0.5})
+	sphere := Sphere{Vector3{0.0, 0.0, 0.0}, 1.0, &subsurface}
+	world := NewHittableList()
+	world.AddHittable(&sphere)
+	// A surface of another material inside the volume does not end the walk
+	world.AddHittable(&Sphere{Vector3{0.0, 0.0, 0.0}, 0.9, &lambertian})
+
+	const N = 1000
+	deep := 0
+	for i := 0; i < N; i++ {
+		ray := Ray{Vector3{0.0, 0.0, 1.0}, Vector3{0.0, 0.0, -1.0}, 0.0}
+		exit, _, alive := subsurface.RandomWalk(&world, ray)
+		if !alive {
+			continue
+		}
+		if exit.Origin.Length() < 0.85 {
+			deep++
+		}
+		hitRecord := HitRecord{}
+		assert.True(sphere.Hit(exit, 0.001, Infinity32, &hitRecord), "Exit ray should reach the boundary")
+	}
+	assert.Less(10, deep, "Walks should cross the inner surface")
+}
@@ -0,0 +1,335 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"github.com/Opioid/rgbe"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Texture is evaluated at the UV and the position of a hit
+type Texture interface {
+	Evaluate(uv Vector2, position Vector3) Vector3
+}
+
+// FilteredTexture is evaluated over the footprint given by the screen space derivatives of UV
+type FilteredTexture interface {
+	EvaluateFiltered(uv, duvdx, duvdy Vector2, position Vector3) Vector3
+}
+
+func evaluateFiltered(texture Texture, uv, duvdx, duvdy Vector2, position Vector3) Vector3 {
+	if filtered, ok := texture.(FilteredTexture); ok {
+		return filtered.EvaluateFiltered(uv, duvdx, duvdy, position)
+	}
+	return texture.Evaluate(uv, position)
+}
+
+// HitTexture is evaluated from the whole hit record rather than the UV, like vertex colors
+type HitTexture interface {
+	EvaluateHit(hitRecord *HitRecord) Vector3
+}
+
+// EvaluateTexture evaluates a texture at a hit, filtered by the derivatives of UV if the texture supports
+func EvaluateTexture(texture Texture, hitRecord *HitRecord) Vector3 {
+	if hit, ok := texture.(HitTexture); ok {
+		return hit.EvaluateHit(hitRecord)
+	}
+	return evaluateFiltered(texture, hitRecord.UV, hitRecord.DUVDX, hitRecord.DUVDY, hitRecord.Position)
+}
+
+// ModulateColor multiplies a constant color by an optional texture
+func ModulateColor(value Vector3, texture Texture, hitRecord *HitRecord) Vector3 {
+	if texture == nil {
+		return value
+	}
+	return HadamardDotVector3(value, EvaluateTexture(texture, hitRecord))
+}
+
+// ModulateScalar multiplies a constant by the first channel of an optional texture
+func ModulateScalar(value float32, texture Texture, hitRecord *HitRecord) float32 {
+	if texture == nil {
+		return value
+	}
+	return value * EvaluateTexture(texture, hitRecord).X
+}
+
+type ConstantTexture struct {
+	Value Vector3
+}
+
+func NewConstantTexture(value Vector3) *ConstantTexture {
+	return &ConstantTexture{value}
+}
+
+func (texture *ConstantTexture) Evaluate(uv Vector2, position Vector3) Vector3 {
+	return texture.Value
+}
+
+// VertexColorTexture is the interpolated vertex color of a hit, white elsewhere in UV space
+type VertexColorTexture struct{}
+
+func (texture *VertexColorTexture) Evaluate(uv Vector2, position Vector3) Vector3 {
+	return Vector3{1.0, 1.0, 1.0}
+}
+
+func (texture *VertexColorTexture) EvaluateHit(hitRecord *HitRecord) Vector3 {
+	return hitRecord.Color
+}
+
+// ChannelTexture broadcasts one channel of a texture, like roughness in the green channel
+type ChannelTexture struct {
+	Texture Texture
+	Channel int32
+}
+
+func (texture *ChannelTexture) Evaluate(uv Vector2, position Vector3) Vector3 {
+	return texture.channel(texture.Texture.Evaluate(uv, position))
+}
+
+func (texture *ChannelTexture) EvaluateFiltered(uv, duvdx, duvdy Vector2, position Vector3) Vector3 {
+	return texture.channel(evaluateFiltered(texture.Texture, uv, duvdx, duvdy, position))
+}
+
+func (texture *ChannelTexture) channel(value Vector3) Vector3 {
+	var x float32
+	switch texture.Channel {
+	case 0:
+		x = value.X
+	case 1:
+		x = value.Y
+	default:
+		x = value.Z
+	}
+	return Vector3{x, x, x}
+}
+
+// CheckerTexture alternates two textures on a grid in the UV space
+type CheckerTexture struct {
+	Even      Texture
+	Odd       Texture
+	Frequency float32
+}
+
+func (texture *CheckerTexture) Evaluate(uv Vector2, position Vector3) Vector3 {
+	x := int32(math32.Floor(uv.X * texture.Frequency))
+	y := int32(math32.Floor(uv.Y * texture.Frequency))
+	if 0 == ((x + y) & 0x01) {
+		return texture.Even.Evaluate(uv, position)
+	}
+	return texture.Odd.Evaluate(uv, position)
+}
+
+type WrapMode int32
+
+const (
+	WrapRepeat WrapMode = iota
+	WrapClamp
+	WrapMirror
+)
+
+func wrapTexel(x, size int32, mode WrapMode) int32 {
+	switch mode {
+	case WrapClamp:
+		if x < 0 {
+			return 0
+		} else if size <= x {
+			return size - 1
+		}
+		return x
+	case WrapMirror:
+		period := 2 * size
+		x = x % period
+		if x < 0 {
+			x += period
+		}
+		if size <= x {
+			x = period - 1 - x
+		}
+		return x
+	default:
+		x = x % size
+		if x < 0 {
+			x += size
+		}
+		return x
+	}
+}
+
+// ImageTexture bilinearly filtered image in the linear working space, the origin of UV is the bottom left
+//
+// It is filtered trilinearly by the footprint after GenerateMipMap. Alpha is optional, nil means opaque.
+// Images in other color spaces should be converted by ConvertColorSpace.
+type ImageTexture struct {
+	Width  int32
+	Height int32
+	Image  []Vector3
+	WrapU  WrapMode
+	WrapV  WrapMode
+	MipMap *MipMap
+	Alpha  []float32
+}
+
+func NewImageTexture(width, height int32, image []Vector3) *ImageTexture {
+	return &ImageTexture{width, height, image, WrapRepeat, WrapRepeat, nil, nil}
+}
+
+// GenerateMipMap builds the pyramid, it should be called again after the image or the wrap modes are modified
+func (texture *ImageTexture) GenerateMipMap() {
+	texture.MipMap = NewMipMap(texture.Width, texture.Height, texture.Image, texture.WrapU, texture.WrapV)
+}
+
+// LoadImageTexture loads PNG, JPEG or Radiance HDR
+//
+// LDR images are converted from sRGB to linear if srgb is true, HDR images are always linear.
+func LoadImageTexture(path string, srgb bool) (*ImageTexture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(path)) == ".hdr" {
+		width, height, data, err := rgbe.Decode(file)
+		if err != nil {
+			return nil, err
+		}
+		pixels := make([]Vector3, width*height)
+		for i := range pixels {
+			pixels[i] = Vector3{data[i*3+0], data[i*3+1], data[i*3+2]}
+		}
+		return NewImageTexture(int32(width), int32(height), pixels), nil
+	}
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	return NewImageTextureFromImage(img, srgb), nil
+}
+
+// NewImageTextureFromImage converts a decoded image, the alpha channel is kept if the image is not opaque
+func NewImageTextureFromImage(img image.Image, srgb bool) *ImageTexture {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+	pixels := make([]Vector3, width*height)
+	alpha := make([]float32, width*height)
+	opaque := true
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			c := Vector3{float32(r) / 65535.0, float32(g) / 65535.0, float32(b) / 65535.0}
+			// Colors are premultiplied by alpha
+			if 0 < a && a < 65535 {
+				c = MulVector3(65535.0/float32(a), c)
+			}
+			if srgb {
+				c = Vector3{sRGBToLinear(c.X), sRGBToLinear(c.Y), sRGBToLinear(c.Z)}
+			}
+			pixels[y*width+x] = c
+			alpha[y*width+x] = float32(a) / 65535.0
+			opaque = opaque && a == 65535
+		}
+	}
+	texture := NewImageTexture(int32(width), int32(height), pixels)
+	if !opaque {
+		texture.Alpha = alpha
+	}
+	return texture
+}
+
+func (texture *ImageTexture) Texel(x, y int32) Vector3 {
+	x = wrapTexel(x, texture.Width, texture.WrapU)
+	y = wrapTexel(y, texture.Height, texture.WrapV)
+	return texture.Image[y*texture.Width+x]
+}
+
+func (texture *ImageTexture) bilinear(uv Vector2) (int32, int32, float32, float32) {
+	x := uv.X*float32(texture.Width) - 0.5
+	y := (1.0-uv.Y)*float32(texture.Height) - 0.5
+	fx := math32.Floor(x)
+	fy := math32.Floor(y)
+	return int32(fx), int32(fy), x - fx, y - fy
+}
+
+// EvaluateAlpha bilinearly filters the alpha channel
+func (texture *ImageTexture) EvaluateAlpha(uv Vector2) float32 {
+	if nil == texture.Alpha {
+		return 1.0
+	}
+	ix, iy, dx, dy := texture.bilinear(uv)
+	alpha := func(x, y int32) float32 {
+		x = wrapTexel(x, texture.Width, texture.WrapU)
+		y = wrapTexel(y, texture.Height, texture.WrapV)
+		return texture.Alpha[y*texture.Width+x]
+	}
+	a0 := Lerp32(alpha(ix, iy), alpha(ix+1, iy), dx)
+	a1 := Lerp32(alpha(ix, iy+1), alpha(ix+1, iy+1), dx)
+	return Lerp32(a0, a1, dy)
+}
+
+func (texture *ImageTexture) Evaluate(uv Vector2, position Vector3) Vector3 {
+	ix, iy, dx, dy := texture.bilinear(uv)
+	c0 := LerpVector3(texture.Texel(ix, iy), texture.Texel(ix+1, iy), dx)
+	c1 := LerpVector3(texture.Texel(ix, iy+1), texture.Texel(ix+1, iy+1), dx)
+	return LerpVector3(c0, c1, dy)
+}
+
+func (texture *ImageTexture) EvaluateFiltered(uv, duvdx, duvdy Vector2, position Vector3) Vector3 {
+	if nil == texture.MipMap {
+		return texture.Evaluate(uv, position)
+	}
+	width := 2.0 * math32.Max(
+		math32.Max(math32.Abs(duvdx.X), math32.Abs(duvdx.Y)),
+		math32.Max(math32.Abs(duvdy.X), math32.Abs(duvdy.Y)))
+	return texture.MipMap.Trilinear(uv.X, 1.0-uv.Y, width)
+}
+
+// AlphaChannelTexture broadcasts the alpha channel of an image
+type AlphaChannelTexture struct {
+	Texture *ImageTexture
+}
+
+func (texture *AlphaChannelTexture) Evaluate(uv Vector2, position Vector3) Vector3 {
+	alpha := texture.Texture.EvaluateAlpha(uv)
+	return Vector3{alpha, alpha, alpha}
+}
+
+type NoiseType int32
+
+const (
+	NoisePerlin NoiseType = iota
+	NoiseFBm
+	NoiseWorley
+)
+
+// NoiseTexture blends two colors by a solid noise of the position
+type NoiseTexture struct {
+	Noise   NoiseType
+	Scale   float32
+	Octaves int32
+	Color0  Vector3
+	Color1  Vector3
+}
+
+func NewNoiseTexture(noise NoiseType, scale float32, color0, color1 Vector3) *NoiseTexture {
+	return &NoiseTexture{noise, scale, 6, color0, color1}
+}
+
+func (texture *NoiseTexture) Evaluate(uv Vector2, position Vector3) Vector3 {
+	p := MulVector3(texture.Scale, position)
+	var t float32
+	switch texture.Noise {
+	case NoiseFBm:
+		t = 0.5 * (FBm(p, texture.Octaves, 2.0, 0.5) + 1.0)
+	case NoiseWorley:
+		t = Worley(p)
+	default:
+		t = 0.5 * (Perlin(p) + 1.0)
+	}
+	return LerpVector3(texture.Color0, texture.Color1, Saturate32(t))
+}
@@ -0,0 +1,57 @@
+package core
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckerTexture(t *testing.T) {
+	assert := assert.New(t)
+	white := NewConstantTexture(Vector3{1.0, 1.0, 1.0})
+	black := NewConstantTexture(Vector3{})
+	checker := CheckerTexture{white, black, 4.0}
+	assert.Truef(EqualVector3(white.Value, checker.Evaluate(Vector2{0.1, 0.1}, Vector3{})), "(0,0) should be even")
+	assert.Truef(EqualVector3(black.Value, checker.Evaluate(Vector2{0.3, 0.1}, Vector3{})), "(1,0) should be odd")
+	assert.Truef(EqualVector3(white.Value, checker.Evaluate(Vector2{0.3, 0.3}, Vector3{})), "(1,1) should be even")
+}
+
+func TestWrapTexel(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(int32(3), wrapTexel(-1, 4, WrapRepeat))
+	assert.Equal(int32(1), wrapTexel(5, 4, WrapRepeat))
+	assert.Equal(int32(0), wrapTexel(-1, 4, WrapClamp))
+	assert.Equal(int32(3), wrapTexel(5, 4, WrapClamp))
+	assert.Equal(int32(0), wrapTexel(-1, 4, WrapMirror))
+	assert.Equal(int32(2), wrapTexel(5, 4, WrapMirror))
+}
+
+func TestImageTexture(t *testing.T) {
+	assert := assert.New(t)
+	// Top row is black, bottom row is white
+	image := []Vector3{{0.0, 0.0, 0.0}, {0.0, 0.0, 0.0}, {1.0, 1.0, 1.0}, {1.0, 1.0, 1.0}}
+	texture := NewImageTexture(2, 2, image)
+	texture.WrapV = WrapClamp
+	assert.InDeltaf(1.0, texture.Evaluate(Vector2{0.25, 0.25}, Vector3{}).X, 1.0e-5, "Texel center at the bottom")
+	assert.InDeltaf(0.0, texture.Evaluate(Vector2{0.25, 0.75}, Vector3{}).X, 1.0e-5, "Texel center at the top")
+	assert.InDeltaf(0.5, texture.Evaluate(Vector2{0.25, 0.5}, Vector3{}).X, 1.0e-5, "Bilinear between the rows")
+}
+
+func TestResolveTexture(t *testing.T) {
+	assert := assert.New(t)
+	lambertian := NewLambertian(Vector3{0.5, 0.5, 0.5})
+	assert.Truef(&lambertian == lambertian.Resolve(&HitRecord{}), "No texture should return itself")
+	lambertian.AlbedoTexture = NewConstantTexture(Vector3{1.0, 0.5, 0.0})
+	resolved := lambertian.Resolve(&HitRecord{})
+	assert.Truef(EqualVector3(Vector3{0.5, 0.25, 0.0}, resolved.GetAlbedo()), "Albedo should be modulated")
+}
+
+func TestSphereUV(t *testing.T) {
+	assert := assert.New(t)
+	sphere := Sphere{Vector3{0.0, 0.0, 0.0}, 1.0, nil}
+	var hitRecord HitRecord
+	ray := Ray{Vector3{0.0, 2.0, 0.0}, Vector3{0.0, -1.0, 0.0}, 0.0}
+	assert.True(sphere.Hit(ray, 0.0, 10.0, &hitRecord))
+	assert.InDeltaf(1.0, hitRecord.UV.Y, 1.0e-5, "Top of the sphere should be v=1")
+	ray = Ray{Vector3{0.0, -2.0, 0.0}, Vector3{0.0, 1.0, 0.0}, 0.0}
+	assert.True(sphere.Hit(ray, 0.0, 10.0, &hitRecord))
+	assert.InDeltaf(0.0, hitRecord.UV.Y, 1.0e-5, "Bottom of the sphere should be v=0")
+}
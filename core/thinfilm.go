@@ -0,0 +1,61 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+	"math/cmplx"
+)
+
+const thinFilmSamples int32 = 32
+
+// fresnelAmplitude amplitude reflection coefficients of s and p polarization from n1 to n2, and the cosine in n2
+func fresnelAmplitude(n1, n2, cos1 complex128) (complex128, complex128, complex128) {
+	sin2 := (n1 / n2) * (n1 / n2) * (1.0 - cos1*cos1)
+	cos2 := cmplx.Sqrt(1.0 - sin2)
+	rs := (n1*cos1 - n2*cos2) / (n1*cos1 + n2*cos2)
+	rp := (n2*cos1 - n1*cos2) / (n2*cos1 + n1*cos2)
+	return rs, rp, cos2
+}
+
+// airyReflectance reflectance of a film of the thickness in nanometers on a substrate, from the outside of IOR 1
+func airyReflectance(cosine, thickness, filmIOR, lambda float32, substrate complex128) float32 {
+	film := complex(float64(filmIOR), 0.0)
+	cos1 := complex(float64(Saturate32(cosine)), 0.0)
+	r12s, r12p, cosFilm := fresnelAmplitude(1.0, film, cos1)
+	r23s, r23p, _ := fresnelAmplitude(film, substrate, cosFilm)
+	delta := complex(float64(4.0*math32.Pi*thickness/lambda), 0.0) * film * cosFilm
+	phase := cmplx.Exp(complex(0.0, 1.0) * delta)
+	airy := func(r12, r23 complex128) float64 {
+		r := (r12 + r23*phase) / (1.0 + r12*r23*phase)
+		return real(r)*real(r) + imag(r)*imag(r)
+	}
+	return float32(0.5 * (airy(r12s, r23s) + airy(r12p, r23p)))
+}
+
+// ThinFilmReflectance reflectance of a thin film in RGB
+//
+// The Airy reflectance is evaluated at wavelengths over the visible range then converted to RGB.
+// thickness is in nanometers, substrate returns the complex IOR of the substrate at a wavelength.
+func ThinFilmReflectance(cosine, thickness, filmIOR float32, substrate func(lambda float32) complex128) Vector3 {
+	return ReflectanceToRGB(thinFilmSamples, func(lambda float32) float32 {
+		return airyReflectance(cosine, thickness, filmIOR, lambda, substrate(lambda))
+	})
+}
+
+// InterpolateComplexIOR interpolates the IOR sampled at the wavelengths of red (650nm), green (550nm) and blue (450nm)
+func InterpolateComplexIOR(ior ComplexIOR, lambda float32) complex128 {
+	var t float32
+	var eta, k float32
+	switch {
+	case lambda <= 450.0:
+		eta, k = ior.Eta.Z, ior.K.Z
+	case lambda <= 550.0:
+		t = (lambda - 450.0) / 100.0
+		eta, k = Lerp32(ior.Eta.Z, ior.Eta.Y, t), Lerp32(ior.K.Z, ior.K.Y, t)
+	case lambda <= 650.0:
+		t = (lambda - 550.0) / 100.0
+		eta, k = Lerp32(ior.Eta.Y, ior.Eta.X, t), Lerp32(ior.K.Y, ior.K.X, t)
+	default:
+		eta, k = ior.Eta.X, ior.K.X
+	}
+	return complex(float64(eta), float64(k))
+}
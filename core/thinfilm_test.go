@@ -0,0 +1,59 @@
+package core
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReflectanceToRGB(t *testing.T) {
+	assert := assert.New(t)
+	cmf := CIEColorMatching(555.0)
+	assert.InDeltaf(1.0, cmf.Y, 0.02, "Luminous efficiency peaks around 555nm")
+	rgb := ReflectanceToRGB(32, func(lambda float32) float32 {
+		return 0.5
+	})
+	assert.InDelta(0.5, rgb.X, 1.0e-4)
+	assert.InDelta(0.5, rgb.Y, 1.0e-4)
+	assert.InDelta(0.5, rgb.Z, 1.0e-4)
+
+	// A saturated spectrum is out of the gamut and clamped
+	rgb = ReflectanceToRGB(32, func(lambda float32) float32 {
+		if 500.0 <= lambda && lambda <= 520.0 {
+			return 1.0
+		}
+		return 0.0
+	})
+	for _, c := range []float32{rgb.X, rgb.Y, rgb.Z} {
+		assert.Truef(0.0 <= c && c <= 1.0, "%v should be in [0 1]", rgb)
+	}
+	_, weight := selectFilm(Vector3{1.2, 0.5, -0.1}, 0.99)
+	assert.True(0.0 <= weight.X && 0.0 <= weight.Y && 0.0 <= weight.Z)
+}
+
+func TestThinFilmReflectance(t *testing.T) {
+	assert := assert.New(t)
+	glass := func(lambda float32) complex128 {
+		return complex(1.5, 0.0)
+	}
+	for _, cosine := range []float32{1.0, 0.7, 0.2} {
+		// A film of zero thickness is the bare interface
+		r := ThinFilmReflectance(cosine, 0.0, 1.33, glass)
+		expected := FresnelDielectric(cosine, 1.5)
+		assert.InDeltaf(expected, r.Y, 1.0e-4, "Zero thickness at %v", cosine)
+
+		// A film matching the outside is also the bare interface
+		gold := ConductorPresets["Au"]
+		r = ThinFilmReflectance(cosine, 300.0, 1.0, func(lambda float32) complex128 {
+			return complex(float64(gold.Eta.X), float64(gold.K.X))
+		})
+		expected = FresnelConductor(cosine, gold).X
+		assert.InDeltaf(expected, r.X, 1.0e-3, "Index matched film at %v", cosine)
+	}
+
+	// Interference colors change with the thickness
+	r0 := ThinFilmReflectance(1.0, 300.0, 1.33, glass)
+	r1 := ThinFilmReflectance(1.0, 450.0, 1.33, glass)
+	assert.Falsef(EqualVector3(r0, r1), "%v and %v should differ", r0, r1)
+	for _, r := range []Vector3{r0, r1} {
+		assert.True(0.0 <= r.X && r.X <= 1.0 && 0.0 <= r.Y && r.Y <= 1.0 && 0.0 <= r.Z && r.Z <= 1.0, "%v", r)
+	}
+}
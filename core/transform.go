@@ -0,0 +1,92 @@
+package core
+
+import (
+	"git.maze.io/go/math32"
+)
+
+// Transform affine transform with its inverse
+type Transform struct {
+	Matrix  Matrix4
+	Inverse Matrix4
+}
+
+// NewTransform returns the transform of an invertible matrix, false if singular
+func NewTransform(m Matrix4) (Transform, bool) {
+	inverse, valid := InverseMatrix4(m)
+	return Transform{m, inverse}, valid
+}
+
+func IdentityTransform() Transform {
+	return Transform{IdentityMatrix4(), IdentityMatrix4()}
+}
+
+func Translate(t Vector3) Transform {
+	return Transform{TranslateMatrix4(t), TranslateMatrix4(MulVector3(-1.0, t))}
+}
+
+func Scale(s Vector3) Transform {
+	return Transform{ScaleMatrix4(s), ScaleMatrix4(Vector3{1.0 / s.X, 1.0 / s.Y, 1.0 / s.Z})}
+}
+
+func Rotate(axis Vector3, angle float32) Transform {
+	m := RotateMatrix4(axis, angle)
+	return Transform{m, TransposeMatrix4(m)}
+}
+
+// ComposeTransform returns t0 t1, which applies t1 first
+func ComposeTransform(t0, t1 Transform) Transform {
+	return Transform{MulMatrix4(t0.Matrix, t1.Matrix), MulMatrix4(t1.Inverse, t0.Inverse)}
+}
+
+func (transform *Transform) Invert() Transform {
+	return Transform{transform.Inverse, transform.Matrix}
+}
+
+func (transform *Transform) Point(p Vector3) Vector3 {
+	return transform.Matrix.TransformPoint(p)
+}
+
+func (transform *Transform) Vector(v Vector3) Vector3 {
+	return transform.Matrix.TransformVector(v)
+}
+
+// Normal transforms a normal by the inverse transpose, the result is not normalized
+func (transform *Transform) Normal(n Vector3) Vector3 {
+	m := &transform.Inverse
+	return Vector3{
+		m[0][0]*n.X + m[1][0]*n.Y + m[2][0]*n.Z,
+		m[0][1]*n.X + m[1][1]*n.Y + m[2][1]*n.Z,
+		m[0][2]*n.X + m[1][2]*n.Y + m[2][2]*n.Z}
+}
+
+// Ray transforms a ray, the direction is not normalized so that the parameters of the ray are kept
+func (transform *Transform) Ray(ray Ray) Ray {
+	return Ray{transform.Point(ray.Origin), transform.Vector(ray.Direction), ray.Time}
+}
+
+// AABB bounds the transformed corners of a box, unbounded boxes stay infinite
+func (transform *Transform) AABB(box AABB) AABB {
+	if box.IsEmpty() {
+		return box
+	}
+	for _, x := range [6]float32{box.Min.X, box.Min.Y, box.Min.Z, box.Max.X, box.Max.Y, box.Max.Z} {
+		if math32.IsInf(x, 0) {
+			return NewInfiniteAABB()
+		}
+	}
+	result := NewEmptyAABB()
+	for i := 0; i < 8; i++ {
+		corner := box.Min
+		if 0 != (i & 1) {
+			corner.X = box.Max.X
+		}
+		if 0 != (i & 2) {
+			corner.Y = box.Max.Y
+		}
+		if 0 != (i & 4) {
+			corner.Z = box.Max.Z
+		}
+		result = ExpandAABB(result, transform.Point(corner))
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package core
+import (
+	"github.com/stretchr/testify/assert"
+	"git.maze.io/go/math32"
+	"testing"
+)
+
+func TestInverseMatrix4(t *testing.T) {
+	assert := assert.New(t)
+	m := MulMatrix4(TranslateMatrix4(Vector3{1.0, 2.0, 3.0}), MulMatrix4(RotateMatrix4(Vector3{1.0, 1.0, 0.0}, 0.7), ScaleMatrix4(Vector3{2.0, 0.5, 3.0})))
+	inverse, valid := InverseMatrix4(m)
+	assert.True(valid)
+	product := MulMatrix4(m, inverse)
+	identity := IdentityMatrix4()
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			assert.InDelta(identity[i][j], product[i][j], 1.0e-5)
+		}
+	}
+	_, valid = InverseMatrix4(ScaleMatrix4(Vector3{1.0, 0.0, 1.0}))
+	assert.False(valid)
+}
+
+func TestTransform(t *testing.T) {
+	assert := assert.New(t)
+	transform := ComposeTransform(Translate(Vector3{1.0, 0.0, 0.0}), Rotate(Vector3{0.0, 0.0, 1.0}, 0.5*math32.Pi))
+	p := transform.Point(Vector3{1.0, 0.0, 0.0})
+	assert.InDelta(1.0, p.X, 1.0e-5)
+	assert.InDelta(1.0, p.Y, 1.0e-5)
+	inverse := transform.Invert()
+	q := inverse.Point(p)
+	assert.InDelta(1.0, q.X, 1.0e-5)
+	assert.InDelta(0.0, q.Y, 1.0e-5)
+	v := transform.Vector(Vector3{1.0, 0.0, 0.0})
+	assert.InDeltaf(0.0, v.X, 1.0e-5, "Vectors should not be translated")
+
+	box := transform.AABB(AABB{Vector3{0.0, 0.0, 0.0}, Vector3{2.0, 1.0, 1.0}})
+	assert.InDelta(0.0, box.Min.X, 1.0e-5)
+	assert.InDelta(2.0, box.Max.Y, 1.0e-5)
+	infinite := transform.AABB(NewInfiniteAABB())
+	assert.False(infinite.IsEmpty())
+}
+
+func TestInstanceEllipsoid(t *testing.T) {
+	assert := assert.New(t)
+	sphere := Sphere{Vector3{}, 1.0, nil}
+	transform := ComposeTransform(Translate(Vector3{0.0, 0.0, -5.0}), Scale(Vector3{2.0, 1.0, 1.0}))
+	instance := NewInstance(&sphere, transform)
+	var record HitRecord
+	ray := Ray{Vector3{}, Vector3{0.0, 0.0, -1.0}, 0.0}
+	assert.True(instance.Hit(ray, 0.0, 100.0, &record))
+	assert.InDelta(4.0, record.T, 1.0e-5)
+	assert.InDelta(1.0, record.Normal.Z, 1.0e-5)
+
+	// x^2/4 + y^2 = 1 has the normal of (x/4, y)
+	x := math32.Sqrt(2.0)
+	y := math32.Sqrt(0.5)
+	ray = Ray{Vector3{10.0, y, -5.0}, Vector3{-1.0, 0.0, 0.0}, 0.0}
+	assert.True(instance.Hit(ray, 0.0, 100.0, &record))
+	assert.InDelta(10.0-x, record.T, 1.0e-4)
+	expected := NormalizeVector3(Vector3{x / 4.0, y, 0.0})
+	assert.InDelta(expected.X, record.Normal.X, 1.0e-3)
+	assert.InDelta(expected.Y, record.Normal.Y, 1.0e-3)
+	assert.InDelta(0.0, DotVector3(record.Normal, record.DPDU), 1.0e-3)
+
+	box := instance.BoundingBox()
+	assert.InDelta(-2.0, box.Min.X, 1.0e-5)
+	assert.InDelta(-6.0, box.Min.Z, 1.0e-5)
+}
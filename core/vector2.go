@@ -0,0 +1,17 @@
+package core
+
+type Vector2 struct {
+	X, Y float32
+}
+
+func AddVector2(x0, x1 Vector2) Vector2 {
+	return Vector2{x0.X + x1.X, x0.Y + x1.Y}
+}
+
+func SubVector2(x0, x1 Vector2) Vector2 {
+	return Vector2{x0.X - x1.X, x0.Y - x1.Y}
+}
+
+func MulVector2(x0 float32, x1 Vector2) Vector2 {
+	return Vector2{x0 * x1.X, x0 * x1.Y}
+}
@@ -159,3 +159,7 @@ func Refract(refracted *Vector3, x, n Vector3, niOverNt float32) bool {
 	}
 	return false
 }
+
+func MaxElementVector3(x Vector3) float32 {
+	return math32.Max(x.X, math32.Max(x.Y, x.Z))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"git.maze.io/go/math32"
 	"image"
@@ -31,47 +32,277 @@ func color32ToRGBA(c Color32) color.RGBA {
 	return color.RGBA{r, g, b, a}
 }
 
-func radiance(ray Ray, world *HittableList, maxDepth int32, envMap *SphereMap) Color32 {
+// directLighting sums the light arriving at a position from the lights, eval returns the scattering toward a light and the medium on that side
+func directLighting(world World, lights []Light, position Vector3, time float32, outside Medium, eval func(direction Vector3) (Vector3, Medium)) Vector3 {
 	li := Vector3{}
-	throughput := Vector3{1.0, 1.0, 1.0}
+	for _, light := range lights {
+		sample := light.SampleLight(position, rand.Float32(), rand.Float32())
+		if sample.Radiance.IsZero() {
+			continue
+		}
+		f, medium := eval(sample.Direction)
+		if f.IsZero() {
+			continue
+		}
+		transmittance := Transmittance(world, Ray{position, sample.Direction, time}, sample.Distance-0.001, medium, outside)
+		li = AddVector3(HadamardDotVector3(f, HadamardDotVector3(transmittance, sample.Radiance)), li)
+	}
+	return li
+}
+
+// pathRadiance carries the throughput of a path and accumulates its contributions,
+// either in RGB or at the wavelengths of hero wavelength sampling
+//
+// A spectral path upsamples each weight and each radiance it gathers at its wavelengths,
+// so that albedos and the environment are evaluated per wavelength like the index of refraction.
+type pathRadiance struct {
+	spectral           bool
+	wavelengths        SampledWavelengths
+	throughput         Vector3
+	spectralThroughput SampledSpectrum
+	rgb                Vector3
+	spectrum           SampledSpectrum
+}
+
+func newPathRadiance(spectral bool) pathRadiance {
+	path := pathRadiance{spectral: spectral, throughput: Vector3{1.0, 1.0, 1.0}}
+	if spectral {
+		path.wavelengths = SampleWavelengths(rand.Float32())
+		path.spectralThroughput = NewSampledSpectrum(1.0)
+	}
+	return path
+}
+
+// scale multiplies the throughput by the weight of a scattering or of a transmittance
+func (path *pathRadiance) scale(weight Vector3) {
+	if path.spectral {
+		path.spectralThroughput = MulSampledSpectrum(path.spectralThroughput, UpsampleRGB(weight, &path.wavelengths))
+		return
+	}
+	path.throughput = HadamardDotVector3(path.throughput, weight)
+}
+
+// absorbed reports whether the throughput is zero
+func (path *pathRadiance) absorbed() bool {
+	if path.spectral {
+		return path.spectralThroughput.IsZero()
+	}
+	return path.throughput.IsZero()
+}
+
+// roulette terminates the path with the probability of its throughput, at most 0.9, and reweights it if it survives
+func (path *pathRadiance) roulette() bool {
+	var continueProbability float32
+	if path.spectral {
+		continueProbability = math32.Min(MaxElementSampledSpectrum(path.spectralThroughput), 0.9)
+	} else {
+		continueProbability = math32.Min(path.throughput.Length(), 0.9)
+	}
+	if continueProbability <= rand.Float32() {
+		return false
+	}
+	path.spectralThroughput = ScaleSampledSpectrum(1.0/continueProbability, path.spectralThroughput)
+	path.throughput = DivVector3(path.throughput, continueProbability)
+	return true
+}
+
+// add adds the radiance arriving along the path weighted by its throughput
+func (path *pathRadiance) add(radiance Vector3) {
+	if path.spectral {
+		contribution := MulSampledSpectrum(path.spectralThroughput, UpsampleRGB(radiance, &path.wavelengths))
+		path.spectrum = AddSampledSpectrum(path.spectrum, contribution)
+		return
+	}
+	path.rgb = AddVector3(path.rgb, HadamardDotVector3(path.throughput, radiance))
+}
+
+// disperse returns the material at the hero wavelength if it is dispersive
+func (path *pathRadiance) disperse(material Material) Material {
+	if !path.spectral {
+		return material
+	}
+	if dispersive, ok := material.(DispersiveMaterial); ok {
+		if dispersed, disperses := dispersive.AtWavelength(path.wavelengths.Lambda[0]); disperses {
+			path.wavelengths.TerminateSecondary()
+			return dispersed
+		}
+	}
+	return material
+}
+
+// result returns linear sRGB, converted from the estimated XYZ for a spectral path
+func (path *pathRadiance) result() Color32 {
+	rgb := path.rgb
+	if path.spectral {
+		rgb = SpectralXYZToRGB(path.wavelengths.ToXYZ(path.spectrum))
+	}
+	return Color32{rgb.X, rgb.Y, rgb.Z, 1.0}
+}
+
+// radiance traces a path in the scene, outside is the medium which fills the whole scene and can be nil
+//
+// A spectral path samples hero wavelengths, so that dispersive materials refract each wavelength differently.
+func radiance(ray RayDifferential, world World, lights []Light, maxDepth int32, envMap *SphereMap, outside Medium, spectral bool) Color32 {
+	path := newPathRadiance(spectral)
 	hitRecord := HitRecord{}
+	current := outside
+	stack := DielectricStack{}
+	// The pdf of the last direction sampled by a phase function, which is weighted against the shadow rays to the environment
+	phasePdf := float32(0.0)
+	environmentPdf := float32(1.0 / (4.0 * math32.Pi))
+	visibility := VisibleCamera
 	for depth := int32(0); depth < maxDepth; depth++ {
-		if !world.Hit(ray, 0.001, Infinity32, &hitRecord) {
-			unitDirection := NormalizeVector3(ray.Direction)
-			//t := 0.5 * (unitDirection.Y + 1.0)
-			//v := AddVector3(MulVector3(1.0-t, Vector3{1.0, 1.0, 1.0}), MulVector3(t, Vector3{0.5, 0.7, 1.0}))
-			v := envMap.Sample(unitDirection)
-			li = AddVector3(HadamardDotVector3(throughput, v), li)
-			break
-		}
-		coordinate := NewCoordinate(hitRecord.Normal)
-		wow := ray.Direction.Minus()
-		wo := coordinate.WorldToLocal(wow)
-		materialSample := hitRecord.Material.Sample(wo, rand.Float32(), rand.Float32())
-		if materialSample.Weight.IsZero() {
-			ray.Origin = AddVector3(MulVector3(Epsilon32, ray.Direction), hitRecord.Position)
-		} else {
-			wiw := coordinate.LocalToWorld(materialSample.Scattered)
-			throughput = HadamardDotVector3(throughput, materialSample.Weight)
-
-			ray.Origin = hitRecord.Position
-			ray.Direction = wiw
+		hit := world.HitVisible(ray.Ray, 0.001, Infinity32, visibility, &hitRecord)
+		scattered := false
+		if nil != current {
+			tmax := Infinity32
+			if hit {
+				tmax = hitRecord.T
+			}
+			var t float32
+			var weight Vector3
+			t, weight, scattered = current.Sample(ray.Ray, tmax)
+			path.scale(weight)
+			if path.absorbed() {
+				break
+			}
+			if scattered {
+				position := ray.PointAt(t)
+				direction := NormalizeVector3(ray.Direction)
+				phase := current.GetPhase()
+
+				// Shadow ray to the environment
+				lightDirection := RandomOnSphere(rand.Float32(), rand.Float32())
+				transmittance := Transmittance(world, Ray{position, lightDirection, ray.Time}, Infinity32, current, outside)
+				if !transmittance.IsZero() {
+					pdf := phase.Eval(direction, lightDirection)
+					misWeight := PowerHeuristic(environmentPdf, pdf)
+					v := envMap.Sample(lightDirection)
+					path.add(MulVector3(pdf*misWeight/environmentPdf, HadamardDotVector3(transmittance, v)))
+				}
+				direct := directLighting(world, lights, position, ray.Time, outside, func(lightDirection Vector3) (Vector3, Medium) {
+					p := phase.Eval(direction, lightDirection)
+					return Vector3{p, p, p}, current
+				})
+				path.add(direct)
+
+				next, pdf := phase.Sample(direction, rand.Float32(), rand.Float32())
+				phasePdf = pdf
+				ray = RayDifferential{Ray: Ray{position, next, ray.Time}}
+				visibility = VisibleReflection
+			}
 		}
-		//Russian roulette
-		if 6 <= depth {
-			continueProbability := math32.Min(throughput.Length(), 0.9)
-			if continueProbability <= rand.Float32() {
+		if !scattered {
+			if !hit {
+				unitDirection := NormalizeVector3(ray.Direction)
+				//t := 0.5 * (unitDirection.Y + 1.0)
+				//v := AddVector3(MulVector3(1.0-t, Vector3{1.0, 1.0, 1.0}), MulVector3(t, Vector3{0.5, 0.7, 1.0}))
+				v := envMap.SampleFiltered(unitDirection, ray.Spread())
+				if 0.0 < phasePdf {
+					v = MulVector3(PowerHeuristic(phasePdf, environmentPdf), v)
+				}
+				path.add(v)
 				break
 			}
-			throughput = DivVector3(throughput, continueProbability)
+			path.scale(stack.Transmittance(hitRecord.T*ray.Direction.Length()))
+			volume, nested := NestedVolume(hitRecord.Material)
+			var volumeOutside float32
+			if nested {
+				var valid bool
+				volumeOutside, valid = stack.Outside(volume)
+				if !valid {
+					// False intersection with a lower priority volume, which blocks shadow rays
+					phasePdf = 0.0
+					stack.Cross(volume, ray.Direction, hitRecord.GeometricNormal)
+					ray.Origin = hitRecord.Position
+					continue
+				}
+			}
+			hitRecord.ComputeDifferentials(&ray)
+			coordinate := NewShadingCoordinate(&hitRecord)
+			wow := ray.Direction.Minus()
+			wo := coordinate.WorldToLocal(wow)
+			if LeaksLight(&hitRecord, wow, wo) {
+				break
+			}
+			material := hitRecord.Material.Resolve(&hitRecord)
+			if emitter, ok := material.(Emitter); ok {
+				path.add(emitter.Emitted(wo))
+				// An emitting surface which also scatters continues with its material
+				wrapper, wraps := material.(MaterialWrapper)
+				if !wraps {
+					break
+				}
+				material = wrapper.Unwrap()
+			}
+			// The shadow rays to the environment cross the same surfaces, only scattering ends the weighting against them
+			if _, passes := PassThrough(material); !passes {
+				phasePdf = 0.0
+			}
+			material = path.disperse(material)
+			if resolved, ok := material.(NestedDielectric); ok && nested {
+				material = resolved.Relative(volumeOutside)
+			}
+			direct := directLighting(world, lights, hitRecord.Position, ray.Time, outside, func(lightDirection Vector3) (Vector3, Medium) {
+				local := coordinate.WorldToLocal(lightDirection)
+				if LeaksLight(&hitRecord, lightDirection, local) {
+					return Vector3{}, nil
+				}
+				return material.Eval(wo, local), NextMedium(&hitRecord, lightDirection, current, outside)
+			})
+			path.add(direct)
+			if weight, passes := PassThrough(material); passes {
+				path.scale(weight)
+				if path.absorbed() {
+					break
+				}
+				current = NextMedium(&hitRecord, ray.Direction, current, outside)
+				if nested {
+					stack.Cross(volume, ray.Direction, hitRecord.GeometricNormal)
+				}
+				ray.Origin = AddVector3(MulVector3(Epsilon32, ray.Direction), hitRecord.Position)
+			} else {
+				materialSample := material.Sample(wo, rand.Float32(), rand.Float32())
+				// The material absorbed the path
+				if !materialSample.Continue || materialSample.Weight.IsZero() {
+					break
+				}
+				wiw := coordinate.LocalToWorld(materialSample.Scattered)
+				if LeaksLight(&hitRecord, wiw, materialSample.Scattered) {
+					break
+				}
+				path.scale(materialSample.Weight)
+
+				specular := material.Pdf(wo, materialSample.Scattered) <= 0.0
+				current = NextMedium(&hitRecord, wiw, current, outside)
+				if nested {
+					stack.Cross(volume, wiw, hitRecord.GeometricNormal)
+				}
+				ray = hitRecord.SpawnRay(&ray, wiw, specular, RelativeIOR(material))
+				visibility = VisibleReflection
+				if walker, ok := material.(RandomWalker); ok && DotVector3(wiw, hitRecord.GeometricNormal) < 0.0 {
+					exit, weight, alive := walker.RandomWalk(world, ray.Ray)
+					if !alive {
+						break
+					}
+					path.scale(weight)
+					ray = RayDifferential{Ray: exit}
+				}
+			}
+		}
+		//Russian roulette
+		if 6 <= depth && !path.roulette() {
+			break
 		}
 	}
-	return Color32{li.X, li.Y, li.Z, 1.0}
+	return path.result()
 }
 
-func generateScene() HittableList {
-	world := NewHittableList()
-	//world.AddHittable(&Sphere{Vector3{0.0, -1000.0, 0.0}, 1000.0, &Lambertian{Vector3{0.5, 0.5, 0.5}}})
+// generateScene returns the scene graph of a group of small spheres and a large one
+func generateScene() *SceneNode {
+	root := NewSceneNode("scene", nil)
+	world := root.AddChild(NewSceneNode("spheres", nil))
+	//root.AddChild(NewSceneNode("ground", &Plane{Vector3{0.0, 0.0, 0.0}, Vector3{0.0, 1.0, 0.0}, &Lambertian{Vector3{0.5, 0.5, 0.5}, nil}}))
 
 	for a := -11; a < 11; a++ {
 		for b := -11; b < 11; b++ {
@@ -84,7 +315,7 @@ func generateScene() HittableList {
 			color := Vector3{rand.Float32(), rand.Float32(), rand.Float32()}
 			roughness := rand.Float32()*0.9 + 0.01
 			metallic := rand.Float32()*0.9 + 0.01
-			world.AddHittable(&Sphere{center, 0.2, &Metal{color, roughness, metallic, 0.9}})
+			world.AddChild(NewSceneNode(fmt.Sprintf("sphere_%v_%v", a, b), &Sphere{center, 0.2, &Metal{Albedo: color, RoughnessX: roughness, RoughnessY: roughness, Metallic: metallic, RefIndex: 0.9}}))
 /*
 			selection := rand.Float32()
 			if selection < 0.4 {
@@ -100,16 +331,19 @@ func generateScene() HittableList {
 	}
 	//world.AddHittable(&Sphere{Vector3{0.0, 1.0, 0.0}, 1.0, &Dielectric{Vector3{1.0, 1.0, 1.0}, 1.5}})
 	//world.AddHittable(&Sphere{Vector3{-4.0, 1.0, 0.0}, 1.0, &Lambertian{Vector3{0.4, 0.2, 0.1}}})
-	world.AddHittable(&Sphere{Vector3{4.0, 1.0, 0.0}, 1.0, &Metal{Vector3{0.7, 0.6, 0.5}, 0.05, 0.5, 0.9}})
-	return world
+	root.AddChild(NewSceneNode("large", &Sphere{Vector3{4.0, 1.0, 0.0}, 1.0, &Metal{Albedo: Vector3{0.7, 0.6, 0.5}, RoughnessX: 0.05, RoughnessY: 0.05, Metallic: 0.5, RefIndex: 0.9}}))
+	return root
 }
 
-func render(name string, width, height, spp, maxDepth int32, world *HittableList) {
+// render renders the scene by path tracing, outside is the medium which fills the scene and can be nil,
+// whiteBalance is the color temperature in Kelvin to neutralize, zero disables it
+func render(name string, width, height, spp, maxDepth int32, world World, lights []Light, outside Medium, spectral bool, whiteBalance float32, encoding *OutputEncoding) {
 	fmt.Printf("start render %v ...\n", name)
 	start := time.Now()
 
 	var envMap SphereMap
 	envMap.Load("uffizi_probe.hdr")
+	envMap.GenerateMipMap()
 
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
 	rand.Seed(time.Now().UnixNano())
@@ -125,6 +359,8 @@ func render(name string, width, height, spp, maxDepth int32, world *HittableList
 	gauss0 := float32(1.0/math32.Sqrt(2.0*math32.Pi*sigma*sigma))
 	gauss1 := float32(-1.0/(2.0*sigma*sigma))
 
+	balance := NewWhiteBalance(whiteBalance)
+
 	camera := NewCameraPerspectiveLens(uint32(width), uint32(height), DegToRad32*45.0, 0.01)
 	camera.LookAt(Vector3{9.0, 1.2, 2.5}, Vector3{0.0, 0.0, 0.0}, Vector3{0.0, 1.0, 0.0})
 	for y := int32(0); y < height; y++ {
@@ -132,8 +368,8 @@ func render(name string, width, height, spp, maxDepth int32, world *HittableList
 			acc := Color32{}
 			weight := float32(0.0)
 			for s := int32(0); s < spp; s++ {
-				ray := camera.GenerateRay(uint32(x), uint32(y), screenSamples[s], lensSamples[s])
-				c := radiance(ray, world, maxDepth, &envMap)
+				ray := camera.GenerateRay(uint32(x), uint32(y), screenSamples[s], lensSamples[s], random.Float32())
+				c := radiance(ray, world, lights, maxDepth, &envMap, outside, spectral)
 				dx := 2.0 * screenSamples[s].X - 1.0
 				dy := 2.0 * screenSamples[s].Y - 1.0
 				w := gauss0 * math32.Exp(gauss1*(dx*dx + dy*dy))
@@ -145,7 +381,11 @@ func render(name string, width, height, spp, maxDepth int32, world *HittableList
 			if Epsilon32<weight {
 				acc = MulColor32(1.0/weight, acc)
 			}
-			acc = LinearToSRGB(acc)
+			if 0.0 < whiteBalance {
+				balanced := balance.Apply(Vector3{acc.R, acc.G, acc.B})
+				acc = Color32{balanced.X, balanced.Y, balanced.Z, acc.A}
+			}
+			acc = encoding.Encode(acc)
 			img.Set(int(x), int(height-y-1), color32ToRGBA(acc))
 		}
 	}
@@ -234,14 +474,15 @@ func FresnelSchlick(cosTheta float32, F0 Vector3) Vector3 {
 	return AddVector3(F0, MulVector3(math32.Pow(Clamp0132(1-cosTheta), 5.0), F1))
 }
 
-func radiance_direct(ray Ray, world *HittableList, envMap, irradianceMap, brdfMap *SphereMap, specularMaps []SphereMap, useAsIrradiance bool) Color32 {
+func radiance_direct(ray Ray, world World, envMap, irradianceMap, brdfMap, sheenMap *SphereMap, specularMaps []SphereMap, useAsIrradiance bool) Color32 {
 	li := Vector3{}
 	hitRecord := HitRecord{}
-	if !world.Hit(ray, 0.001, Infinity32, &hitRecord) {
+	if !world.HitVisible(ray, 0.001, Infinity32, VisibleCamera, &hitRecord) {
 		unitDirection := NormalizeVector3(ray.Direction)
 		li = envMap.Sample(unitDirection)
 		return Color32{li.X, li.Y, li.Z, 1.0}
 	}
+	coordinate := NewShadingCoordinate(&hitRecord)
 	L := Vector3{0.0, 1.0, 0.0}
 	V := NormalizeVector3(SubVector3(ray.Origin, hitRecord.Position))
 	N := hitRecord.Normal
@@ -253,9 +494,19 @@ func radiance_direct(ray Ray, world *HittableList, envMap, irradianceMap, brdfMa
 	HV := math32.Max(DotVector3(H, V), 0.0)
 	NH := math32.Max(DotVector3(N, H), 0.0)
 
-	roughness := hitRecord.Material.GetRoughness()
-	metallic := hitRecord.Material.GetMetallic()
-	albedo := hitRecord.Material.GetAlbedo()
+	material := hitRecord.Material.Resolve(&hitRecord)
+	var emitted Vector3
+	if emitter, ok := material.(Emitter); ok {
+		emitted = emitter.Emitted(coordinate.WorldToLocal(V))
+		wrapper, wraps := material.(MaterialWrapper)
+		if !wraps {
+			return Color32{emitted.X, emitted.Y, emitted.Z, 1.0}
+		}
+		material = wrapper.Unwrap()
+	}
+	roughness := material.GetRoughness()
+	metallic := material.GetMetallic()
+	albedo := material.GetAlbedo()
 
 	F0 := FresnelF0(albedo, metallic)
 
@@ -285,13 +536,20 @@ func radiance_direct(ray Ray, world *HittableList, envMap, irradianceMap, brdfMa
 
 	ambientS := HadamardDotVector3(AddVector3(MulVector3(BRDF.X, envF), Vector3{BRDF.Y, BRDF.Y, BRDF.Y}), reflection)
 	ambient := AddVector3(HadamardDotVector3(aD, ambientD), ambientS)
+	if sheenMaterial, ok := material.(SheenMaterial); ok {
+		sheenColor, sheenRoughness := sheenMaterial.GetSheen()
+		sheenReflection := SampleSpecularEnvMap(sheenRoughness, R, specularMaps)
+		sheenAlbedo := SampleSheenLUT(sheenRoughness, NV, sheenMap)
+		ambient = AddVector3(ambient, MulVector3(sheenAlbedo, HadamardDotVector3(sheenColor, sheenReflection)))
+	}
 	Lo = AddVector3(Lo, MulVector3(0.9, ambient))
+	Lo = AddVector3(Lo, emitted)
 	//Lo = AddVector3(ambientS, MulVector3(0.0, Lo))
 
 	return Color32{Lo.X, Lo.Y, Lo.Z, 1.0}
 }
 
-func render_direct(name string, width, height int32, world *HittableList, useAsIrradiance bool) {
+func render_direct(name string, width, height int32, world World, useAsIrradiance bool, encoding *OutputEncoding) {
 	fmt.Printf("start render %v ...\n", name)
 	start := time.Now()
 
@@ -307,6 +565,7 @@ func render_direct(name string, width, height int32, world *HittableList, useAsI
 
 	brdfMap := envMap.GenBRDF(256, 256)
 	//brdfMap.SavePng("brdf.png")
+	sheenMap := GenSheenLUT(32, 32)
 
 	img := image.NewRGBA(image.Rectangle{image.Point{0, 0}, image.Point{int(width), int(height)}})
 
@@ -315,9 +574,9 @@ func render_direct(name string, width, height int32, world *HittableList, useAsI
 	sample := Sample2{0.0, 0.0}
 	for y := int32(0); y < height; y++ {
 		for x := int32(0); x < width; x++ {
-			ray := camera.GenerateRay(uint32(x), uint32(y), sample, sample)
-			c := radiance_direct(ray, world, &envMap, &irradianceMap, &brdfMap, specularMaps, useAsIrradiance)
-			c = LinearToSRGB(c)
+			ray := camera.GenerateRay(uint32(x), uint32(y), sample, sample, 0.5)
+			c := radiance_direct(ray.Ray, world, &envMap, &irradianceMap, &brdfMap, &sheenMap, specularMaps, useAsIrradiance)
+			c = encoding.Encode(c)
 			img.Set(int(x), int(height-y-1), color32ToRGBA(c))
 		}
 	}
@@ -336,13 +595,25 @@ func render_direct(name string, width, height int32, world *HittableList, useAsI
 }
 
 func main() {
-	world := generateScene()
+	spectral := flag.Bool("spectral", false, "render with hero wavelength sampling, dispersive materials need it")
+	fog := flag.Float64("fog", 0.0, "density of the fog filling the scene, zero for vacuum")
+	flag.Parse()
+
+	world := generateScene().Flatten()
 	var width int32 = 400
 	var height int32 = 300
 	var numSamples int32 = 512
 	var maxDepth int32 = 16
-	render("out_path.png", width, height, numSamples, maxDepth, &world)
-	render_direct("out_ibl.png", width, height, &world, false)
-	render_direct("out_ibl_pseudo.png", width, height, &world, true)
+	// The medium which fills the whole scene, like fog, nil for vacuum
+	var outside Medium
+	if 0.0 < *fog {
+		density := float32(*fog)
+		medium := NewHomogeneousMedium(Vector3{}, Vector3{density, density, density}, 0.0)
+		outside = &medium
+	}
+	encoding := NewOutputEncoding(ColorSpaceRec709, TransferSRGB)
+	render("out_path.png", width, height, numSamples, maxDepth, world, nil, outside, *spectral, 0.0, &encoding)
+	render_direct("out_ibl.png", width, height, world, false, &encoding)
+	render_direct("out_ibl_pseudo.png", width, height, world, true, &encoding)
 }
 